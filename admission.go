@@ -0,0 +1,408 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// podSecurityEnforceLabel is the namespace label with the pod security level pods are rejected by
+const podSecurityEnforceLabel = "pod-security.kubernetes.io/enforce"
+
+// baselineCapabilities are the capabilities the baseline pod security level allows to be added
+var baselineCapabilities = []string{
+	"AUDIT_WRITE", "CHOWN", "DAC_OVERRIDE", "FOWNER", "FSETID", "KILL", "MKNOD",
+	"NET_BIND_SERVICE", "SETFCAP", "SETGID", "SETPCAP", "SETUID", "SYS_CHROOT",
+}
+
+// restrictedVolumes are the volume types the restricted pod security level allows
+var restrictedVolumes = []string{
+	"configMap", "csi", "downwardAPI", "emptyDir", "ephemeral",
+	"persistentVolumeClaim", "projected", "secret",
+}
+
+// namespaceList is the part of a list of namespaces used to simulate admission
+type namespaceList struct {
+	Items []struct {
+		Metadata struct {
+			Name   string            `yaml:"name"`
+			Labels map[string]string `yaml:"labels"`
+		} `yaml:"metadata"`
+	} `yaml:"items"`
+}
+
+// labelSelector selects pods or namespaces by their labels
+type labelSelector struct {
+	MatchLabels      map[string]string `yaml:"matchLabels"`
+	MatchExpressions []nodeRequirement `yaml:"matchExpressions"`
+}
+
+// networkPeer is a source or destination of a network policy rule
+type networkPeer struct {
+	PodSelector       *labelSelector `yaml:"podSelector"`
+	NamespaceSelector *labelSelector `yaml:"namespaceSelector"`
+	IPBlock           interface{}    `yaml:"ipBlock"`
+}
+
+// networkPolicy is the part of a network policy used to simulate reachability
+type networkPolicy struct {
+	Metadata struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+	Spec struct {
+		PodSelector labelSelector `yaml:"podSelector"`
+		PolicyTypes []string      `yaml:"policyTypes"`
+		Ingress     []struct {
+			From []networkPeer `yaml:"from"`
+		} `yaml:"ingress"`
+		Egress []struct {
+			To []networkPeer `yaml:"to"`
+		} `yaml:"egress"`
+	} `yaml:"spec"`
+}
+
+// networkPolicyList is a list of network policies
+type networkPolicyList struct {
+	Items []networkPolicy `yaml:"items"`
+}
+
+// networkDependency is the pods, selected by their labels in a namespace, a workload
+// needs to reach
+type networkDependency struct {
+	namespace string
+	labels    map[string]string
+}
+
+// String is the dependency as given on the command line
+func (d networkDependency) String() string {
+	var pairs []string
+	for _, k := range sortedStrings(d.labels) {
+		pairs = append(pairs, k+"="+d.labels[k])
+	}
+	return d.namespace + "/" + strings.Join(pairs, ",")
+}
+
+// validateAdmission warns when pods would be rejected by the pod security level of
+// their namespace, or isolated from their dependencies by network policies
+func validateAdmission(c *cli.Context, resources []*ObjectResource) error {
+	out, err := runKubeCmd(c, "get", "namespaces", "-o", "yaml")
+	if isForbiddenError(err) {
+		// Deploy credentials are often scoped to their namespaces
+		logWarn.Printf("unable to simulate admission without permission to list the namespaces: %s", strings.TrimSpace(err.Error()))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("problem getting the namespaces: %s", err)
+	}
+	var namespaces namespaceList
+	if err := yaml.Unmarshal([]byte(out), &namespaces); err != nil {
+		return err
+	}
+	nsLabels := map[string]map[string]string{}
+	for _, ns := range namespaces.Items {
+		nsLabels[ns.Metadata.Name] = withNameLabel(ns.Metadata.Name, ns.Metadata.Labels)
+	}
+	var policies networkPolicyList
+	deps, err := parseNetworkDependencies(c.StringSlice(FlagNetworkDependency), defaultNamespace(c.String("namespace")))
+	if err != nil {
+		return err
+	}
+	if len(deps) > 0 {
+		out, err := runKubeCmd(c, "get", "networkpolicies", "--all-namespaces", "-o", "yaml")
+		if isForbiddenError(err) {
+			logWarn.Printf("unable to check the --%s without permission to list the network policies: %s", FlagNetworkDependency, strings.TrimSpace(err.Error()))
+			deps = nil
+		} else if err != nil {
+			return fmt.Errorf("problem getting the network policies: %s", err)
+		} else if err := yaml.Unmarshal([]byte(out), &policies); err != nil {
+			return err
+		}
+	}
+	for _, r := range resources {
+		var doc map[interface{}]interface{}
+		if err := yaml.Unmarshal(r.Template, &doc); err != nil {
+			return err
+		}
+		podSpec := lookupPath(doc, podTemplatePath(r.Kind, "spec")...)
+		if podSpec == nil {
+			continue
+		}
+		namespace := r.Namespace
+		if len(namespace) == 0 {
+			namespace = defaultNamespace(c.String("namespace"))
+		}
+		if c.Bool(FlagValidatePodSecurity) {
+			level := nsLabels[namespace][podSecurityEnforceLabel]
+			for _, problem := range podSecurityProblems(level, podSpec) {
+				logWarn.Printf("%s (from file:%q) will be rejected by the %s pod security level of namespace %s: %s",
+					resourceRef(r), r.FileName, level, namespace, problem)
+			}
+		}
+		podLabels := stringMap(lookupPath(doc, podTemplatePath(r.Kind, "metadata", "labels")...))
+		for _, dep := range deps {
+			if reason := networkIsolation(namespace, podLabels, dep, policies.Items, nsLabels); len(reason) > 0 {
+				logWarn.Printf("%s (from file:%q) can't reach %s: %s", resourceRef(r), r.FileName, dep, reason)
+			}
+		}
+	}
+	return nil
+}
+
+// isForbiddenError checks if a kubectl error is RBAC refusing the request
+func isForbiddenError(err error) bool {
+	return err != nil && (strings.Contains(err.Error(), "(Forbidden)") || strings.Contains(err.Error(), " is forbidden:"))
+}
+
+// podSecurityProblems describes how a pod spec breaks a pod security level, nothing
+// for the privileged level or a namespace without one
+func podSecurityProblems(level string, podSpec interface{}) []string {
+	if level != "baseline" && level != "restricted" {
+		return nil
+	}
+	var problems []string
+	for _, field := range []string{"hostNetwork", "hostPID", "hostIPC"} {
+		if v, _ := lookupPath(podSpec, field).(bool); v {
+			problems = append(problems, fmt.Sprintf("%s must not be true", field))
+		}
+	}
+	for _, volume := range listAt(podSpec, "volumes") {
+		name, _ := lookupPath(volume, "name").(string)
+		if lookupPath(volume, "hostPath") != nil {
+			problems = append(problems, fmt.Sprintf("volume %s must not be a hostPath", name))
+			continue
+		}
+		if v, ok := volume.(map[interface{}]interface{}); ok && level == "restricted" {
+			for k := range v {
+				if k != "name" && !contains(restrictedVolumes, fmt.Sprint(k)) {
+					problems = append(problems, fmt.Sprintf("volume %s must not be a %s", name, k))
+				}
+			}
+		}
+	}
+	podSeccomp, _ := lookupPath(podSpec, "securityContext", "seccompProfile", "type").(string)
+	podNonRoot, _ := lookupPath(podSpec, "securityContext", "runAsNonRoot").(bool)
+	if uid, ok := lookupPath(podSpec, "securityContext", "runAsUser").(int); ok && uid == 0 && level == "restricted" {
+		problems = append(problems, "securityContext.runAsUser must not be 0")
+	}
+	if podSeccomp == "Unconfined" {
+		problems = append(problems, "securityContext.seccompProfile must not be Unconfined")
+	}
+	for _, list := range []string{"initContainers", "containers", "ephemeralContainers"} {
+		for _, container := range listAt(podSpec, list) {
+			name, _ := lookupPath(container, "name").(string)
+			for _, problem := range containerSecurityProblems(level, container, podSeccomp, podNonRoot) {
+				problems = append(problems, fmt.Sprintf("container %s %s", name, problem))
+			}
+		}
+	}
+	return problems
+}
+
+// containerSecurityProblems describes how a container breaks a pod security level,
+// given the seccomp profile and runAsNonRoot of its pod
+func containerSecurityProblems(level string, container interface{}, podSeccomp string, podNonRoot bool) []string {
+	var problems []string
+	sc := lookupPath(container, "securityContext")
+	if v, _ := lookupPath(sc, "privileged").(bool); v {
+		problems = append(problems, "must not be privileged")
+	}
+	for _, port := range listAt(container, "ports") {
+		if v, ok := lookupPath(port, "hostPort").(int); ok && v != 0 {
+			problems = append(problems, fmt.Sprintf("must not use hostPort %d", v))
+		}
+	}
+	if v, ok := lookupPath(sc, "procMount").(string); ok && v != "Default" {
+		problems = append(problems, "must not set procMount "+v)
+	}
+	seccomp, _ := lookupPath(sc, "seccompProfile", "type").(string)
+	if seccomp == "Unconfined" {
+		problems = append(problems, "seccompProfile must not be Unconfined")
+	}
+	allowed := baselineCapabilities
+	if level == "restricted" {
+		allowed = []string{"NET_BIND_SERVICE"}
+	}
+	for _, capability := range listAt(sc, "capabilities", "add") {
+		if !contains(allowed, fmt.Sprint(capability)) {
+			problems = append(problems, fmt.Sprintf("must not add the %s capability", capability))
+		}
+	}
+	if level != "restricted" {
+		return problems
+	}
+	if v, ok := lookupPath(sc, "allowPrivilegeEscalation").(bool); !ok || v {
+		problems = append(problems, "must set allowPrivilegeEscalation to false")
+	}
+	dropsAll := false
+	for _, capability := range listAt(sc, "capabilities", "drop") {
+		dropsAll = dropsAll || capability == "ALL"
+	}
+	if !dropsAll {
+		problems = append(problems, "must drop ALL capabilities")
+	}
+	nonRoot, ok := lookupPath(sc, "runAsNonRoot").(bool)
+	if !ok {
+		nonRoot = podNonRoot
+	}
+	if !nonRoot {
+		problems = append(problems, "must set runAsNonRoot to true")
+	}
+	if uid, ok := lookupPath(sc, "runAsUser").(int); ok && uid == 0 {
+		problems = append(problems, "must not set runAsUser to 0")
+	}
+	if len(seccomp) == 0 {
+		seccomp = podSeccomp
+	}
+	if seccomp != "RuntimeDefault" && seccomp != "Localhost" {
+		problems = append(problems, "must set seccompProfile to RuntimeDefault or Localhost")
+	}
+	return problems
+}
+
+// parseNetworkDependencies parses the pods workloads need to reach,
+// [namespace/]key=value[,key=value] with the namespace defaulting to the deploy's
+func parseNetworkDependencies(list []string, namespace string) ([]networkDependency, error) {
+	var deps []networkDependency
+	for _, s := range list {
+		dep := networkDependency{namespace: namespace}
+		selector := s
+		if i := strings.Index(s, "/"); i >= 0 {
+			dep.namespace, selector = s[:i], s[i+1:]
+		}
+		labels, err := parseSelector(selector)
+		if err != nil || len(dep.namespace) == 0 {
+			return nil, fmt.Errorf("invalid %s %q, expecting [namespace/]key=value[,key=value]", FlagNetworkDependency, s)
+		}
+		dep.labels = labels
+		deps = append(deps, dep)
+	}
+	return deps, nil
+}
+
+// networkIsolation is why network policies stop pods reaching a dependency, empty
+// when they can. Ports aren't compared and ip blocks are assumed to allow the traffic.
+func networkIsolation(namespace string, podLabels map[string]string, dep networkDependency,
+	policies []networkPolicy, nsLabels map[string]map[string]string) string {
+	from := withNameLabel(namespace, nsLabels[namespace])
+	to := withNameLabel(dep.namespace, nsLabels[dep.namespace])
+
+	if names, allowed := policiesAllow(policies, "Egress", namespace, podLabels, dep.namespace, to, dep.labels); !allowed {
+		return fmt.Sprintf("egress isolated by network policies %s", strings.Join(names, ","))
+	}
+	if names, allowed := policiesAllow(policies, "Ingress", dep.namespace, dep.labels, namespace, from, podLabels); !allowed {
+		return fmt.Sprintf("ingress isolated by network policies %s in namespace %s", strings.Join(names, ","), dep.namespace)
+	}
+	return ""
+}
+
+// policiesAllow checks if the policies of a direction selecting pods allow traffic with
+// a peer, returning the names of the selecting policies when they don't
+func policiesAllow(policies []networkPolicy, direction, namespace string, podLabels map[string]string,
+	peerNamespace string, peerNsLabels, peerLabels map[string]string) ([]string, bool) {
+	var names []string
+	for _, p := range policies {
+		if p.Metadata.Namespace != namespace || !labelSelectorMatches(&p.Spec.PodSelector, podLabels) {
+			continue
+		}
+		var rules [][]networkPeer
+		switch direction {
+		case "Ingress":
+			if len(p.Spec.PolicyTypes) > 0 && !contains(p.Spec.PolicyTypes, "Ingress") {
+				continue
+			}
+			for _, rule := range p.Spec.Ingress {
+				rules = append(rules, rule.From)
+			}
+		case "Egress":
+			if !contains(p.Spec.PolicyTypes, "Egress") && (len(p.Spec.PolicyTypes) > 0 || len(p.Spec.Egress) == 0) {
+				continue
+			}
+			for _, rule := range p.Spec.Egress {
+				rules = append(rules, rule.To)
+			}
+		}
+		for _, peers := range rules {
+			// A rule without peers allows all traffic
+			if len(peers) == 0 {
+				return nil, true
+			}
+			for _, peer := range peers {
+				if peerMatches(peer, namespace, peerNamespace, peerNsLabels, peerLabels) {
+					return nil, true
+				}
+			}
+		}
+		names = append(names, p.Metadata.Name)
+	}
+	return names, len(names) == 0
+}
+
+// peerMatches checks if a network policy peer selects pods in a namespace
+func peerMatches(peer networkPeer, policyNamespace, namespace string, nsLabels, podLabels map[string]string) bool {
+	if peer.IPBlock != nil {
+		return true
+	}
+	if peer.NamespaceSelector == nil {
+		if namespace != policyNamespace {
+			return false
+		}
+	} else if !labelSelectorMatches(peer.NamespaceSelector, nsLabels) {
+		return false
+	}
+	return peer.PodSelector == nil || labelSelectorMatches(peer.PodSelector, podLabels)
+}
+
+// labelSelectorMatches checks if a label selector matches labels, an empty selector matches everything
+func labelSelectorMatches(s *labelSelector, labels map[string]string) bool {
+	if !labelsMatch(s.MatchLabels, labels) {
+		return false
+	}
+	for _, req := range s.MatchExpressions {
+		if !requirementMatches(req, labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// withNameLabel adds the kubernetes.io/metadata.name label every namespace has
+func withNameLabel(namespace string, labels map[string]string) map[string]string {
+	all := map[string]string{"kubernetes.io/metadata.name": namespace}
+	for k, v := range labels {
+		all[k] = v
+	}
+	return all
+}
+
+// defaultNamespace is the namespace resources without one are deployed to
+func defaultNamespace(namespace string) string {
+	if len(namespace) == 0 {
+		return "default"
+	}
+	return namespace
+}
+
+// stringMap converts a yaml map, such as labels, to strings
+func stringMap(value interface{}) map[string]string {
+	m := map[string]string{}
+	if v, ok := value.(map[interface{}]interface{}); ok {
+		for k, item := range v {
+			m[fmt.Sprint(k)] = fmt.Sprint(item)
+		}
+	}
+	return m
+}
+
+// sortedStrings are the keys of a map of strings in order
+func sortedStrings(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestPodSecurityProblems(t *testing.T) {
+	cases := []struct {
+		level   string
+		podSpec string
+		want    []string
+	}{
+		{
+			level:   "privileged",
+			podSpec: "hostNetwork: true",
+		},
+		{
+			level: "baseline",
+			podSpec: `
+hostNetwork: true
+volumes:
+- name: docker
+  hostPath: {path: /var/run/docker.sock}
+- name: config
+  configMap: {name: config}
+containers:
+- name: app
+  ports:
+  - containerPort: 80
+    hostPort: 8080
+  securityContext:
+    privileged: true
+    capabilities:
+      add: [NET_ADMIN, CHOWN]`,
+			want: []string{
+				"hostNetwork must not be true",
+				"volume docker must not be a hostPath",
+				"container app must not be privileged",
+				"container app must not use hostPort 8080",
+				"container app must not add the NET_ADMIN capability",
+			},
+		},
+		{
+			level: "restricted",
+			podSpec: `
+securityContext:
+  runAsNonRoot: true
+  seccompProfile: {type: RuntimeDefault}
+volumes:
+- name: data
+  nfs: {server: nfs, path: /}
+containers:
+- name: app
+  securityContext:
+    allowPrivilegeEscalation: false
+    capabilities:
+      drop: [ALL]
+- name: sidecar
+  securityContext:
+    runAsUser: 0
+    capabilities:
+      add: [CHOWN]`,
+			want: []string{
+				"volume data must not be a nfs",
+				"container sidecar must not add the CHOWN capability",
+				"container sidecar must set allowPrivilegeEscalation to false",
+				"container sidecar must drop ALL capabilities",
+				"container sidecar must not set runAsUser to 0",
+			},
+		},
+	}
+	for _, c := range cases {
+		var podSpec interface{}
+		if err := yaml.Unmarshal([]byte(c.podSpec), &podSpec); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got := podSecurityProblems(c.level, podSpec); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+		}
+	}
+}
+
+func TestParseNetworkDependencies(t *testing.T) {
+	got, err := parseNetworkDependencies([]string{"db/app=postgres", "app=cache,tier=backend"}, "batch")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []networkDependency{
+		{namespace: "db", labels: map[string]string{"app": "postgres"}},
+		{namespace: "batch", labels: map[string]string{"app": "cache", "tier": "backend"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+	if _, err := parseNetworkDependencies([]string{"db/postgres"}, "batch"); err == nil {
+		t.Errorf("expected an error for a dependency without a selector")
+	}
+}
+
+func TestNetworkIsolation(t *testing.T) {
+	data, err := ioutil.ReadFile("test/TestNetworkIsolation/policies.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var policies networkPolicyList
+	if err := yaml.Unmarshal(data, &policies); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	nsLabels := map[string]map[string]string{
+		"payments": {"team": "payments"},
+		"batch":    {"team": "data"},
+	}
+	postgres := networkDependency{namespace: "db", labels: map[string]string{"app": "postgres"}}
+	cache := networkDependency{namespace: "batch", labels: map[string]string{"app": "cache"}}
+	cases := []struct {
+		namespace string
+		podLabels map[string]string
+		dep       networkDependency
+		want      string
+	}{
+		{
+			namespace: "payments",
+			podLabels: map[string]string{"app": "api"},
+			dep:       postgres,
+		},
+		{
+			namespace: "payments",
+			podLabels: map[string]string{"app": "worker"},
+			dep:       postgres,
+			want:      "ingress isolated by network policies default-deny,allow-api in namespace db",
+		},
+		{
+			namespace: "batch",
+			podLabels: map[string]string{"app": "report"},
+			dep:       postgres,
+			want:      "egress isolated by network policies lock-down",
+		},
+		{
+			namespace: "batch",
+			podLabels: map[string]string{"app": "report"},
+			dep:       cache,
+		},
+	}
+	for _, c := range cases {
+		if got := networkIsolation(c.namespace, c.podLabels, c.dep, policies.Items, nsLabels); got != c.want {
+			t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+		}
+	}
+}
+
+func TestIsForbiddenError(t *testing.T) {
+	cases := map[string]bool{
+		`Error from server (Forbidden): namespaces is forbidden: User "system:serviceaccount:app:deploy" cannot list resource "namespaces" in API group "" at the cluster scope`: true,
+		`Error from server (ServiceUnavailable): the server is currently unable to handle the request`:                                                                           false,
+	}
+	for msg, want := range cases {
+		if got := isForbiddenError(errors.New(msg)); got != want {
+			t.Errorf("%s\ngot: %#v\nwant: %#v\n", msg, got, want)
+		}
+	}
+	if isForbiddenError(nil) {
+		t.Error("expected no error not to be forbidden")
+	}
+}
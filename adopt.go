@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+// legacyFieldManagers are the field managers of resources applied or edited by hand
+// with kubectl, whose fields are moved to kd's field manager when adopted
+var legacyFieldManagers = []string{
+	"kubectl", "kubectl-client-side-apply", "kubectl-create", "kubectl-edit",
+	"kubectl-patch", "kubectl-replace", "before-first-apply",
+}
+
+// markRelease will label resources as managed by kd for the release specified
+func markRelease(resources []*ObjectResource, release string) error {
+	for _, r := range resources {
+		labels := map[string]string{
+			LabelManaged: "true",
+			LabelRelease: release,
+		}
+		if err := addMetadata(r, "labels", labels); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// adopt will mark existing resources as managed by a kd release
+func adopt(c *cli.Context) error {
+	if c.Bool("debug") {
+		logDebug = logDebugIf
+	}
+	release := c.String(FlagRelease)
+	if len(release) == 0 {
+		return fmt.Errorf("a release must be specified with --%s to adopt resources", FlagRelease)
+	}
+	if c.NArg() == 0 {
+		return errors.New("no resources specified to adopt, expecting kind/name")
+	}
+	adopted := time.Now().UTC().Format(time.RFC3339)
+	for _, resString := range c.Args() {
+		resParts := strings.Split(resString, "/")
+		if len(resParts) != 2 {
+			return fmt.Errorf(
+				"invalid resource type %s, expecting kind/name", resString)
+		}
+		exists, err := NewK8ApiKubectl(c).Exists(&ObjectResource{
+			Kind:       resParts[0],
+			ObjectMeta: ObjectMeta{Name: resParts[1]},
+		})
+		if err != nil {
+			return fmt.Errorf("problem checking if resource %s exists", resString)
+		}
+		if !exists {
+			return fmt.Errorf("resource %s does not exist so cannot be adopted", resString)
+		}
+		if dryRun {
+			logInfo.Printf("dry run, skipping adoption of %s into release %s", resString, release)
+			continue
+		}
+		logInfo.Printf("adopting %s into release %s", resString, release)
+		if _, err := runKubeCmd(c, "label", resString,
+			LabelManaged+"=true", LabelRelease+"="+release, "--overwrite"); err != nil {
+			return err
+		}
+		if _, err := runKubeCmd(c, "annotate", resString,
+			AnnotationAdopted+"="+adopted, "--overwrite"); err != nil {
+			return err
+		}
+		if err := adoptFieldManagers(c, resString); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// adoptFieldManagers moves the fields owned by kubectl to kd's field manager, so a
+// server side apply by kd neither conflicts with them nor leaves them orphaned
+func adoptFieldManagers(c *cli.Context, resString string) error {
+	out, err := runKubeCmd(c, "get", resString, "-o", "json")
+	if err != nil {
+		return err
+	}
+	var live struct {
+		Metadata struct {
+			ManagedFields []map[string]interface{} `json:"managedFields"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal([]byte(out), &live); err != nil {
+		return fmt.Errorf("problem reading the field managers of %s: %s", resString, err)
+	}
+	// Clusters before 1.18 don't track field managers
+	if len(live.Metadata.ManagedFields) == 0 {
+		return nil
+	}
+	migrated, changed := migrateFieldManagers(live.Metadata.ManagedFields, c.String(FlagFieldManager))
+	if !changed {
+		return nil
+	}
+	patch, err := json.Marshal([]patchOp{{Op: "replace", Path: "/metadata/managedFields", Value: migrated}})
+	if err != nil {
+		return err
+	}
+	logInfo.Printf("moving the fields of %s owned by kubectl to field manager %s", resString, c.String(FlagFieldManager))
+	_, err = runKubeCmd(c, "patch", resString, "--type=json", "-p", string(patch))
+	return err
+}
+
+// migrateFieldManagers gives the fields of the legacy field managers to a field
+// manager as if it had applied them, merging the entries of each api version
+func migrateFieldManagers(managed []map[string]interface{}, manager string) ([]map[string]interface{}, bool) {
+	var migrated []map[string]interface{}
+	// The apply entry of the manager for each api version
+	applied := map[string]map[string]interface{}{}
+	changed := false
+	for _, entry := range managed {
+		name, _ := entry["manager"].(string)
+		operation, _ := entry["operation"].(string)
+		legacy := contains(legacyFieldManagers, name)
+		if !legacy && !(name == manager && operation == "Apply") {
+			migrated = append(migrated, entry)
+			continue
+		}
+		changed = changed || legacy
+		apiVersion, _ := entry["apiVersion"].(string)
+		if existing, found := applied[apiVersion]; found {
+			existing["fieldsV1"] = mergeFields(existing["fieldsV1"], entry["fieldsV1"])
+			continue
+		}
+		adopted := map[string]interface{}{}
+		for k, v := range entry {
+			adopted[k] = v
+		}
+		adopted["manager"] = manager
+		adopted["operation"] = "Apply"
+		applied[apiVersion] = adopted
+		migrated = append(migrated, adopted)
+	}
+	return migrated, changed
+}
+
+// mergeFields merges two fieldsV1 sets of the fields a manager owns
+func mergeFields(a, b interface{}) interface{} {
+	aMap, aOk := a.(map[string]interface{})
+	bMap, bOk := b.(map[string]interface{})
+	if !aOk || !bOk {
+		if a == nil {
+			return b
+		}
+		return a
+	}
+	merged := map[string]interface{}{}
+	for k, v := range aMap {
+		merged[k] = v
+	}
+	for k, v := range bMap {
+		merged[k] = mergeFields(merged[k], v)
+	}
+	return merged
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMigrateFieldManagers(t *testing.T) {
+	cases := []struct {
+		name        string
+		managed     []map[string]interface{}
+		want        []map[string]interface{}
+		wantChanged bool
+	}{
+		{
+			name: "Check fields of kubectl are moved to the field manager",
+			managed: []map[string]interface{}{
+				{"manager": "kubectl-client-side-apply", "operation": "Update", "apiVersion": "apps/v1",
+					"fieldsV1": map[string]interface{}{"f:spec": map[string]interface{}{"f:replicas": map[string]interface{}{}}}},
+				{"manager": "kube-controller-manager", "operation": "Update", "apiVersion": "apps/v1",
+					"fieldsV1": map[string]interface{}{"f:status": map[string]interface{}{}}},
+			},
+			want: []map[string]interface{}{
+				{"manager": "kd", "operation": "Apply", "apiVersion": "apps/v1",
+					"fieldsV1": map[string]interface{}{"f:spec": map[string]interface{}{"f:replicas": map[string]interface{}{}}}},
+				{"manager": "kube-controller-manager", "operation": "Update", "apiVersion": "apps/v1",
+					"fieldsV1": map[string]interface{}{"f:status": map[string]interface{}{}}},
+			},
+			wantChanged: true,
+		},
+		{
+			name: "Check fields of several kubectl managers are merged",
+			managed: []map[string]interface{}{
+				{"manager": "kubectl-client-side-apply", "operation": "Update", "apiVersion": "v1",
+					"fieldsV1": map[string]interface{}{"f:data": map[string]interface{}{"f:a": map[string]interface{}{}}}},
+				{"manager": "kubectl-edit", "operation": "Update", "apiVersion": "v1",
+					"fieldsV1": map[string]interface{}{"f:data": map[string]interface{}{"f:b": map[string]interface{}{}}}},
+			},
+			want: []map[string]interface{}{
+				{"manager": "kd", "operation": "Apply", "apiVersion": "v1",
+					"fieldsV1": map[string]interface{}{"f:data": map[string]interface{}{
+						"f:a": map[string]interface{}{}, "f:b": map[string]interface{}{}}}},
+			},
+			wantChanged: true,
+		},
+		{
+			name: "Check fields already applied by the field manager are unchanged",
+			managed: []map[string]interface{}{
+				{"manager": "kd", "operation": "Apply", "apiVersion": "v1",
+					"fieldsV1": map[string]interface{}{"f:data": map[string]interface{}{}}},
+			},
+			want: []map[string]interface{}{
+				{"manager": "kd", "operation": "Apply", "apiVersion": "v1",
+					"fieldsV1": map[string]interface{}{"f:data": map[string]interface{}{}}},
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, changed := migrateFieldManagers(tc.managed, "kd")
+			if changed != tc.wantChanged {
+				t.Errorf("got changed: %v\nwant: %v\n", changed, tc.wantChanged)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got: %#v\nwant: %#v\n", got, tc.want)
+			}
+		})
+	}
+}
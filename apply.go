@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+// transientErrors are the kubectl errors which can succeed when retried
+var transientErrors = []string{
+	"failed calling webhook",
+	"the object has been modified",
+	"connection refused",
+	"connection reset by peer",
+	"i/o timeout",
+	"TLS handshake timeout",
+	"unexpected EOF",
+	"etcdserver: request timed out",
+	"the server is currently unable to handle the request",
+	"Too many requests",
+	"the server was unable to return a response in the time allotted",
+}
+
+// ambiguousErrors are the transient errors where the request may have reached the
+// server, so the change may have been made
+var ambiguousErrors = []string{
+	"connection reset by peer",
+	"i/o timeout",
+	"unexpected EOF",
+	"etcdserver: request timed out",
+	"the server was unable to return a response in the time allotted",
+}
+
+// applyWithRetries runs kubectl for a resource, retrying transient failures
+// with an exponential backoff. A create is only retried after an ambiguous
+// failure when the resource doesn't exist.
+func applyWithRetries(c *cli.Context, r *ObjectResource, args []string) (string, error) {
+	retries := c.Int(FlagApplyRetries)
+	for attempt := 0; ; attempt++ {
+		out, err := applyResource(c, r, args)
+		if err == nil || attempt >= retries || !isTransientError(err) {
+			return out, err
+		}
+		if args[0] == "create" && isAmbiguousError(err) {
+			// A generated name can't be checked, retrying could create another
+			if len(r.Name) == 0 {
+				return out, err
+			}
+			exists, existsErr := NewK8ApiKubectl(c).Exists(r)
+			if existsErr != nil {
+				return out, err
+			}
+			if exists {
+				logWarn.Printf("%s was created despite a transient failure: %s", resourceRef(r), strings.TrimSpace(err.Error()))
+				return resourceRef(r) + " created\n", nil
+			}
+		}
+		delay := jitter(retryDelay(c.Duration(FlagApplyRetryInterval), attempt))
+		logWarn.Printf("retrying %s in %s (%d of %d) after a transient failure: %s",
+			resourceRef(r), delay, attempt+1, retries, strings.TrimSpace(err.Error()))
+		time.Sleep(delay)
+	}
+}
+
+// applyResource runs kubectl with the resource template as stdin
+func applyResource(c *cli.Context, r *ObjectResource, args []string) (string, error) {
+	cmd, err := newResourceKubeCmd(c, r, args, true)
+	if err != nil {
+		return "", err
+	}
+
+	if c.Bool("debug") {
+		logDebug.Printf("kubectl arguments: %q", strings.Join(cmd.Args, " "))
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return "", err
+	}
+
+	var outbuf, errbuf bytes.Buffer
+	cmd.Stdout = &outbuf
+	cmd.Stderr = &errbuf
+
+	go func() {
+		defer stdin.Close()
+		stdin.Write(r.Template)
+	}()
+
+	if err = cmd.Run(); err != nil {
+		if errbuf.Len() > 0 {
+			return "", errors.New(errbuf.String())
+		}
+		return "", err
+	}
+	return outbuf.String(), nil
+}
+
+// isTransientError checks if a kubectl error is worth retrying
+func isTransientError(err error) bool {
+	for _, transient := range transientErrors {
+		if strings.Contains(err.Error(), transient) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAmbiguousError checks if a kubectl error leaves it unknown whether the change was made
+func isAmbiguousError(err error) bool {
+	for _, ambiguous := range ambiguousErrors {
+		if strings.Contains(err.Error(), ambiguous) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryDelay is the backoff before a retry, doubling the interval for each attempt
+func retryDelay(interval time.Duration, attempt int) time.Duration {
+	return interval << uint(attempt)
+}
+
+// jitter adds up to half a delay again at random, so clients retrying at the same
+// time spread out
+func jitter(delay time.Duration) time.Duration {
+	if delay <= 0 {
+		return delay
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/2+1))
+}
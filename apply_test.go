@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIsTransientError(t *testing.T) {
+	cases := map[string]bool{
+		`Error from server (InternalError): error when creating "STDIN": Internal error occurred: failed calling webhook "validate.nginx.ingress.kubernetes.io": Post "https://ingress-nginx-controller-admission.ingress-nginx.svc:443/networking/v1/ingresses?timeout=10s": dial tcp 10.0.0.1:443: connect: connection refused`: true,
+		`Error from server (Conflict): error when applying patch: Operation cannot be fulfilled on deployments.apps "app": the object has been modified; please apply your changes to the latest version and try again`:                                                                                                           true,
+		`Unable to connect to the server: net/http: TLS handshake timeout`:                                                  true,
+		`error: error validating "STDIN": error validating data: ValidationError(Deployment.spec): unknown field "replica"`: false,
+		`Error from server (Forbidden): error when creating "STDIN": deployments.apps is forbidden`:                         false,
+	}
+	for msg, want := range cases {
+		if got := isTransientError(errors.New(msg)); got != want {
+			t.Errorf("%s\ngot: %#v\nwant: %#v\n", msg, got, want)
+		}
+	}
+}
+
+func TestIsAmbiguousError(t *testing.T) {
+	cases := map[string]bool{
+		`Error from server (InternalError): error when creating "STDIN": Internal error occurred: failed calling webhook "validate.nginx.ingress.kubernetes.io": dial tcp 10.0.0.1:443: connect: connection refused`: false,
+		`Unable to connect to the server: net/http: TLS handshake timeout`:                                                                       false,
+		`Error from server: error when creating "STDIN": etcdserver: request timed out`:                                                          true,
+		`error when creating "STDIN": Post "https://10.0.0.1/api/v1/namespaces/app/secrets": read tcp 10.0.0.2:51234->10.0.0.1:443: i/o timeout`: true,
+	}
+	for msg, want := range cases {
+		if got := isAmbiguousError(errors.New(msg)); got != want {
+			t.Errorf("%s\ngot: %#v\nwant: %#v\n", msg, got, want)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	want := []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second}
+	for attempt, w := range want {
+		if got := retryDelay(2*time.Second, attempt); got != w {
+			t.Errorf("got: %#v\nwant: %#v\n", got, w)
+		}
+	}
+}
+
+func TestJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if got := jitter(2 * time.Second); got < 2*time.Second || got > 3*time.Second {
+			t.Errorf("got: %#v\nwant: between 2s and 3s\n", got)
+		}
+	}
+	if got := jitter(0); got != 0 {
+		t.Errorf("got: %#v\nwant: %#v\n", got, 0)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+// autoscalerStatus checks the events of the pods of a resource for the cluster
+// autoscaler adding nodes for them, or being unable to, describing what it's doing
+func autoscalerStatus(c *cli.Context, r *ObjectResource) (bool, string) {
+	if simulating {
+		return false, ""
+	}
+	out, err := runResourceKubeCmd(c, r, "get", "events", "--field-selector", "involvedObject.kind=Pod", "--no-headers",
+		"--sort-by", ".lastTimestamp", "-o",
+		"custom-columns=KIND:.involvedObject.kind,NAME:.involvedObject.name,REASON:.reason,MESSAGE:.message")
+	if err != nil {
+		logDebug.Printf("unable to get the autoscaler events for %s: %s", resourceRef(r), err)
+		return false, ""
+	}
+	return autoscalerEvents(out, r.Name)
+}
+
+// autoscalerEvents finds the latest cluster autoscaler event of the pods named after
+// a resource, true when it triggered a scale up
+func autoscalerEvents(out, name string) (bool, string) {
+	scalingUp, status := false, ""
+	for _, event := range warningEvents(out, name) {
+		switch {
+		case strings.Contains(event, " TriggeredScaleUp: "):
+			scalingUp, status = true, "the cluster autoscaler is adding nodes, "+event
+		case strings.Contains(event, " NotTriggerScaleUp: "):
+			scalingUp, status = false, "the cluster autoscaler can't add nodes, "+event
+		}
+	}
+	return scalingUp, status
+}
+
+// extendForScaleUp checks if a timeout is extended while the cluster autoscaler adds
+// nodes: the first time when it is scaling up, then again as long as more objects
+// became available since the last extension
+func extendForScaleUp(scalingUp bool, extensions int, available, availableWhenExtended int32) bool {
+	if !scalingUp {
+		return false
+	}
+	return extensions == 0 || available > availableWhenExtended
+}
+
+// timeoutError is the error when a resource isn't ready in time, with what the cluster
+// autoscaler is doing for it
+func timeoutError(r *ObjectResource, limit fmt.Stringer, autoscaler string) error {
+	if len(autoscaler) > 0 {
+		autoscaler = " (" + autoscaler + ")"
+	}
+	if r.Kind == "Job" {
+		return fmt.Errorf("hook Job %q timed out after %s%s", r.Name, limit, autoscaler)
+	}
+	return fmt.Errorf("%s rolling update %q timed out after %s%s", r.Kind, r.Name, limit, autoscaler)
+}
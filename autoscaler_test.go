@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestAutoscalerEvents(t *testing.T) {
+	cases := []struct {
+		out       string
+		scalingUp bool
+		status    string
+	}{
+		{
+			out: `Pod   api-5d9f7-x2x4z   FailedScheduling   0/3 nodes are available: 3 Insufficient cpu.
+`,
+		},
+		{
+			out: `Pod   api-5d9f7-x2x4z   FailedScheduling   0/3 nodes are available: 3 Insufficient cpu.
+Pod   api-5d9f7-x2x4z   TriggeredScaleUp   pod triggered scale-up: [{workers 3->4 (max: 10)}]
+`,
+			scalingUp: true,
+			status:    "the cluster autoscaler is adding nodes, pod/api-5d9f7-x2x4z TriggeredScaleUp: pod triggered scale-up: [{workers 3->4 (max: 10)}]",
+		},
+		{
+			out: `Pod   api-5d9f7-x2x4z   TriggeredScaleUp   pod triggered scale-up: [{workers 3->4 (max: 10)}]
+Pod   apiary-1          TriggeredScaleUp   pod triggered scale-up: [{workers 4->5 (max: 10)}]
+Pod   api-5d9f7-x2x4z   NotTriggerScaleUp  pod didn't trigger scale-up: 1 max node group size reached
+`,
+			status: "the cluster autoscaler can't add nodes, pod/api-5d9f7-x2x4z NotTriggerScaleUp: pod didn't trigger scale-up: 1 max node group size reached",
+		},
+	}
+	for _, c := range cases {
+		scalingUp, status := autoscalerEvents(c.out, "api")
+		if scalingUp != c.scalingUp || status != c.status {
+			t.Errorf("got: %#v, %#v\nwant: %#v, %#v\n", scalingUp, status, c.scalingUp, c.status)
+		}
+	}
+}
+
+func TestExtendForScaleUp(t *testing.T) {
+	cases := []struct {
+		name                  string
+		scalingUp             bool
+		extensions            int
+		available             int32
+		availableWhenExtended int32
+		want                  bool
+	}{
+		{name: "Check the timeout isn't extended without a scale up", extensions: 0, want: false},
+		{name: "Check the timeout is extended for a scale up", scalingUp: true, want: true},
+		{name: "Check the timeout is extended again after progress", scalingUp: true, extensions: 1, available: 3, availableWhenExtended: 1, want: true},
+		{name: "Check the timeout isn't extended again without progress", scalingUp: true, extensions: 2, available: 3, availableWhenExtended: 3, want: false},
+		{name: "Check the timeout isn't extended once the scale up stops", extensions: 1, available: 3, availableWhenExtended: 1, want: false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := extendForScaleUp(c.scalingUp, c.extensions, c.available, c.availableWhenExtended); got != c.want {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+var (
+	// awsValues caches the parameters and secrets read from aws while rendering
+	awsValues     = map[string]string{}
+	awsValuesLock sync.Mutex
+)
+
+// ssm returns the (decrypted) value of an AWS SSM parameter
+func ssm(ctx context.Context, name string) (string, error) {
+	return awsValue(ctx, "ssm parameter "+name,
+		"ssm", "get-parameter", "--name", name, "--with-decryption",
+		"--query", "Parameter.Value", "--output", "text")
+}
+
+// awsSecret returns a key of an AWS Secrets Manager secret stored as json
+func awsSecret(ctx context.Context, id, key string) (string, error) {
+	secret, err := awsValue(ctx, "secret "+id,
+		"secretsmanager", "get-secret-value", "--secret-id", id,
+		"--query", "SecretString", "--output", "text")
+	if err != nil {
+		return "", err
+	}
+	return secretKey(id, secret, key)
+}
+
+// awsValue runs the aws cli to read a value, using the usual aws credentials and region
+func awsValue(ctx context.Context, name string, args ...string) (string, error) {
+	awsValuesLock.Lock()
+	defer awsValuesLock.Unlock()
+	cacheKey := strings.Join(args, " ")
+	if value, found := awsValues[cacheKey]; found {
+		return value, nil
+	}
+	// Values are read while rendering so must finish by the render deadline
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "aws", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("reading aws %s stalled: %s", name, ctx.Err())
+	}
+	if err != nil {
+		return "", fmt.Errorf("problem reading aws %s: %s %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	value := strings.TrimSuffix(string(out), "\n")
+	awsValues[cacheKey] = value
+	return value, nil
+}
+
+// secretKey returns a key from the json of a secret
+func secretKey(id, secret, key string) (string, error) {
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(secret), &values); err != nil {
+		return "", fmt.Errorf("secret %s is not json key/value pairs: %s", id, err)
+	}
+	value, found := values[key]
+	if !found {
+		return "", fmt.Errorf("secret %s has no key %s", id, key)
+	}
+	return fmt.Sprint(value), nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSecretKey(t *testing.T) {
+	cases := []struct {
+		name    string
+		secret  string
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "Check a key is found",
+			secret: `{"username": "app", "password": "secret"}`,
+			key:    "password",
+			want:   "secret",
+		},
+		{
+			name:   "Check a number is formatted",
+			secret: `{"port": 5432}`,
+			key:    "port",
+			want:   "5432",
+		},
+		{
+			name:    "Check a missing key is an error",
+			secret:  `{"username": "app"}`,
+			key:     "password",
+			wantErr: true,
+		},
+		{
+			name:    "Check a plain text secret is an error",
+			secret:  `secret`,
+			key:     "password",
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := secretKey("db", c.secret, c.key)
+			if c.wantErr {
+				if err == nil {
+					t.Errorf("expected an error for %q", c.secret)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != c.want {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
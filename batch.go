@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// batchFile is a list of deploy jobs run by the batch command
+type batchFile struct {
+	// Values are the base config data for every job, overlaid by ring and job values
+	Values []string    `yaml:"values"`
+	Jobs   []batchJob  `yaml:"jobs"`
+	Rings  []batchRing `yaml:"rings"`
+}
+
+// batchJob is a single deploy in a batch
+type batchJob struct {
+	Name      string   `yaml:"name"`
+	Files     []string `yaml:"files"`
+	Values    []string `yaml:"values"`
+	Namespace string   `yaml:"namespace"`
+	Context   string   `yaml:"context"`
+	Args      []string `yaml:"args"`
+}
+
+// batchResult is the outcome of a job in a batch
+type batchResult struct {
+	job      batchJob
+	err      error
+	duration time.Duration
+}
+
+// batch runs kd for each of the jobs in a file and reports the results
+func batch(c *cli.Context) error {
+	if c.Bool("debug") {
+		logDebug = logDebugIf
+	}
+	if c.NArg() != 1 {
+		return errors.New("expecting a single batch file of jobs")
+	}
+	b, err := loadBatch(c.Args().First())
+	if err != nil {
+		return err
+	}
+	kd, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	common := passThroughArgs(os.Args[1:], c.Command.Name, c.Args().First())
+
+	parallel := c.Int(FlagBatchParallel)
+	if parallel < 1 {
+		parallel = 1
+	}
+	if len(b.Rings) > 0 {
+		return rollout(b, func(jobs []batchJob) []batchResult {
+			return runJobs(kd, common, jobs, parallel)
+		})
+	}
+	return batchReport(runJobs(kd, common, b.Jobs, parallel))
+}
+
+// runJobs runs kd for each job, at most parallel at a time
+func runJobs(kd string, common []string, jobs []batchJob, parallel int) []batchResult {
+	results := make([]batchResult, len(jobs))
+	workers := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	var outputLock sync.Mutex
+	for i, job := range jobs {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func(i int, job batchJob) {
+			defer wg.Done()
+			defer func() { <-workers }()
+			start := time.Now()
+			cmd := exec.Command(kd, append(common, job.args()...)...)
+			stdout := &prefixWriter{prefix: "[" + job.Name + "] ", out: os.Stdout, lock: &outputLock}
+			stderr := &prefixWriter{prefix: "[" + job.Name + "] ", out: os.Stderr, lock: &outputLock}
+			cmd.Stdout, cmd.Stderr = stdout, stderr
+			logInfo.Printf("starting batch job %s", job.Name)
+			err := cmd.Run()
+			stdout.Flush()
+			stderr.Flush()
+			results[i] = batchResult{job: job, err: err, duration: time.Since(start).Round(time.Second)}
+		}(i, job)
+	}
+	wg.Wait()
+	return results
+}
+
+// loadBatch reads and checks the jobs in a batch file
+func loadBatch(fn string) (*batchFile, error) {
+	data, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	var b batchFile
+	if err := yaml.UnmarshalStrict(data, &b); err != nil {
+		return nil, fmt.Errorf("problem reading batch file %s: %s", fn, err)
+	}
+	if len(b.Jobs) == 0 {
+		return nil, fmt.Errorf("no jobs found in batch file %s", fn)
+	}
+	names := map[string]bool{}
+	for i := range b.Jobs {
+		job := &b.Jobs[i]
+		if len(job.Name) == 0 {
+			job.Name = fmt.Sprintf("job-%d", i+1)
+		}
+		if names[job.Name] {
+			return nil, fmt.Errorf("duplicate batch job name %s", job.Name)
+		}
+		names[job.Name] = true
+		if len(job.Files) == 0 {
+			return nil, fmt.Errorf("no files specified for batch job %s", job.Name)
+		}
+	}
+	if err := resolveRings(&b); err != nil {
+		return nil, err
+	}
+	for i := range b.Jobs {
+		b.Jobs[i].Values = layerValues(b.Values, b.Jobs[i].Values)
+	}
+	return &b, nil
+}
+
+// layerValues lists the config data with later (more specific) values last so they take precedence
+func layerValues(layers ...[]string) []string {
+	var values []string
+	for _, layer := range layers {
+		values = append(values, layer...)
+	}
+	return values
+}
+
+// args are the kd arguments for a job
+func (j batchJob) args() []string {
+	var args []string
+	for _, f := range j.Files {
+		args = append(args, "--file="+f)
+	}
+	for _, v := range j.Values {
+		args = append(args, "--"+FlagConfigData+"="+v)
+	}
+	if len(j.Namespace) > 0 {
+		args = append(args, "--namespace="+j.Namespace)
+	}
+	if len(j.Context) > 0 {
+		args = append(args, "--context="+j.Context)
+	}
+	return append(args, j.Args...)
+}
+
+// passThroughArgs removes the batch command, batch flags and jobs file from the
+// arguments, leaving the flags which apply to every job. The jobs file can be
+// anywhere after the command, as flags after it are parsed too.
+func passThroughArgs(args []string, command, fn string) []string {
+	var common []string
+	commandFound, fileFound := false, false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case !commandFound && arg == command:
+			commandFound = true
+		case commandFound && !fileFound && arg == fn:
+			fileFound = true
+		case arg == "--"+FlagBatchParallel || arg == "-"+FlagBatchParallel:
+			// Skip the value too
+			i++
+		case strings.HasPrefix(arg, "--"+FlagBatchParallel+"=") || strings.HasPrefix(arg, "-"+FlagBatchParallel+"="):
+		default:
+			common = append(common, arg)
+		}
+	}
+	return common
+}
+
+// batchReport logs the result of each job, failing if any job failed
+func batchReport(results []batchResult) error {
+	failed := 0
+	var report bytes.Buffer
+	for _, r := range results {
+		status := "succeeded"
+		if r.err != nil {
+			status = "failed: " + r.err.Error()
+			failed++
+		}
+		fmt.Fprintf(&report, "\n  %-20s %-8s %s", r.job.Name, r.duration, status)
+	}
+	logSummary.Printf("batch results:%s", report.String())
+	if failed > 0 {
+		return fmt.Errorf("%d of %d batch jobs failed", failed, len(results))
+	}
+	return nil
+}
+
+// prefixWriter writes each line of output with a prefix
+type prefixWriter struct {
+	prefix string
+	out    io.Writer
+	lock   *sync.Mutex
+	buf    []byte
+}
+
+// Write writes the complete lines with the prefix, keeping any partial line
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		w.lock.Lock()
+		_, err := fmt.Fprintf(w.out, "%s%s", w.prefix, w.buf[:i+1])
+		w.lock.Unlock()
+		if err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[i+1:]
+	}
+}
+
+// Flush writes any partial line left at the end of the output
+func (w *prefixWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.Write([]byte("\n"))
+	}
+}
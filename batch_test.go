@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestLoadBatch(t *testing.T) {
+	b, err := loadBatch("test/TestLoadBatch/jobs.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	jobs := b.Jobs
+	want := [][]string{
+		{"--file=./kube", "--config-data=Values=values/team-a.yaml", "--namespace=team-a", "--context=prod"},
+		{"--file=./kube/cronjob.yaml", "--dryrun"},
+	}
+	var got [][]string
+	for _, job := range jobs {
+		got = append(got, job.args())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+	if jobs[1].Name != "job-2" {
+		t.Errorf("got: %#v\nwant: %#v\n", jobs[1].Name, "job-2")
+	}
+}
+
+func TestPassThroughArgs(t *testing.T) {
+	cases := []struct {
+		name  string
+		input []string
+		want  []string
+	}{
+		{
+			name:  "Check the command and jobs file are removed",
+			input: []string{"--debug", "batch", "--timeout=5m", "jobs.yaml"},
+			want:  []string{"--debug", "--timeout=5m"},
+		},
+		{
+			name:  "Check the batch flags are removed",
+			input: []string{"batch", "--batch-parallel", "4", "--allow-missing", "--batch-parallel=2", "jobs.yaml"},
+			want:  []string{"--allow-missing"},
+		},
+		{
+			name:  "Check the jobs file is removed before trailing flags",
+			input: []string{"batch", "jobs.yaml", "--timeout=5m"},
+			want:  []string{"--timeout=5m"},
+		},
+		{
+			name:  "Check the jobs file is removed between flags",
+			input: []string{"--debug", "batch", "--allow-missing", "jobs.yaml", "--batch-parallel", "2", "--timeout", "5m"},
+			want:  []string{"--debug", "--allow-missing", "--timeout", "5m"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := passThroughArgs(c.input, "batch", "jobs.yaml")
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := &prefixWriter{prefix: "[a] ", out: &out, lock: &sync.Mutex{}}
+	w.Write([]byte("one\ntw"))
+	w.Write([]byte("o\nthree"))
+	want := "[a] one\n[a] two\n"
+	if out.String() != want {
+		t.Errorf("got: %#v\nwant: %#v\n", out.String(), want)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// cidrhost is the address of a host number in a network e.g.
+// cidrhost "10.0.0.0/24" 5 is 10.0.0.5, negative numbers count back from the end
+func cidrhost(prefix string, hostnum interface{}) (string, error) {
+	network, err := parseNetwork(prefix)
+	if err != nil {
+		return "", err
+	}
+	n, err := templateInt(hostnum)
+	if err != nil {
+		return "", fmt.Errorf("invalid host number for cidrhost: %s", err)
+	}
+	ones, bits := network.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	num := big.NewInt(int64(n))
+	if n < 0 {
+		num.Add(num, size)
+	}
+	if num.Sign() < 0 || num.Cmp(size) >= 0 {
+		return "", fmt.Errorf("host number %d is out of range for %s", n, prefix)
+	}
+	return addToIP(network.IP, num).String(), nil
+}
+
+// cidrsubnet is a subnet of a network with its prefix extended by newbits e.g.
+// cidrsubnet "10.0.0.0/16" 8 2 is 10.0.2.0/24
+func cidrsubnet(prefix string, newbits, netnum interface{}) (string, error) {
+	network, err := parseNetwork(prefix)
+	if err != nil {
+		return "", err
+	}
+	extra, err := templateInt(newbits)
+	if err != nil {
+		return "", fmt.Errorf("invalid new bits for cidrsubnet: %s", err)
+	}
+	n, err := templateInt(netnum)
+	if err != nil {
+		return "", fmt.Errorf("invalid network number for cidrsubnet: %s", err)
+	}
+	ones, bits := network.Mask.Size()
+	if extra < 0 || ones+extra > bits {
+		return "", fmt.Errorf("can't extend the prefix of %s by %d bits", prefix, extra)
+	}
+	if n < 0 || big.NewInt(int64(n)).Cmp(new(big.Int).Lsh(big.NewInt(1), uint(extra))) >= 0 {
+		return "", fmt.Errorf("network number %d is out of range for %d new bits", n, extra)
+	}
+	num := new(big.Int).Lsh(big.NewInt(int64(n)), uint(bits-ones-extra))
+	subnet := net.IPNet{IP: addToIP(network.IP, num), Mask: net.CIDRMask(ones+extra, bits)}
+	return subnet.String(), nil
+}
+
+// cidrnetmask is the dotted netmask of an IPv4 network e.g. 255.255.255.0 for a /24
+func cidrnetmask(prefix string) (string, error) {
+	network, err := parseNetwork(prefix)
+	if err != nil {
+		return "", err
+	}
+	if len(network.Mask) != net.IPv4len {
+		return "", fmt.Errorf("cidrnetmask only supports IPv4 networks, not %s", prefix)
+	}
+	return net.IP(network.Mask).String(), nil
+}
+
+// portOffset is a port a number of ports from a base port e.g. an admin port next to
+// the service port, failing when it isn't a valid port
+func portOffset(base, offset interface{}) (int, error) {
+	b, err := templateInt(base)
+	if err != nil {
+		return 0, fmt.Errorf("invalid base port for portOffset: %s", err)
+	}
+	o, err := templateInt(offset)
+	if err != nil {
+		return 0, fmt.Errorf("invalid offset for portOffset: %s", err)
+	}
+	if port := b + o; port > 0 && port <= 65535 {
+		return port, nil
+	}
+	return 0, fmt.Errorf("port %d offset by %d is out of range", b, o)
+}
+
+// parseNetwork parses a network in CIDR notation
+func parseNetwork(prefix string) (*net.IPNet, error) {
+	_, network, err := net.ParseCIDR(strings.TrimSpace(prefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid network %q: %s", prefix, err)
+	}
+	return network, nil
+}
+
+// addToIP adds a number to an address
+func addToIP(ip net.IP, num *big.Int) net.IP {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	sum := new(big.Int).Add(new(big.Int).SetBytes(ip), num).Bytes()
+	result := make(net.IP, len(ip))
+	copy(result[len(result)-len(sum):], sum)
+	return result
+}
+
+// templateInt is a number given to a template function, which may be a string from
+// the config data
+func templateInt(value interface{}) (int, error) {
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case float64:
+		return int(v), nil
+	case string:
+		return strconv.Atoi(strings.TrimSpace(v))
+	}
+	return 0, fmt.Errorf("%v isn't a number", value)
+}
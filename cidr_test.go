@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCidrhost(t *testing.T) {
+	cases := []struct {
+		prefix  string
+		hostnum interface{}
+		want    string
+		err     bool
+	}{
+		{prefix: "10.0.0.0/24", hostnum: 5, want: "10.0.0.5"},
+		{prefix: "10.0.1.7/24", hostnum: "10", want: "10.0.1.10"},
+		{prefix: "10.0.0.0/24", hostnum: -2, want: "10.0.0.254"},
+		{prefix: "fd00::/64", hostnum: 258, want: "fd00::102"},
+		{prefix: "10.0.0.0/30", hostnum: 4, err: true},
+		{prefix: "10.0.0.0", hostnum: 1, err: true},
+	}
+	for _, c := range cases {
+		got, err := cidrhost(c.prefix, c.hostnum)
+		if (err != nil) != c.err {
+			t.Errorf("unexpected error for %s %v: %v", c.prefix, c.hostnum, err)
+		}
+		if got != c.want {
+			t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+		}
+	}
+}
+
+func TestCidrsubnet(t *testing.T) {
+	cases := []struct {
+		prefix  string
+		newbits interface{}
+		netnum  interface{}
+		want    string
+		err     bool
+	}{
+		{prefix: "10.0.0.0/16", newbits: 8, netnum: 2, want: "10.0.2.0/24"},
+		{prefix: "10.0.0.0/16", newbits: 4, netnum: 15, want: "10.0.240.0/20"},
+		{prefix: "fd00::/48", newbits: "16", netnum: "1", want: "fd00:0:0:1::/64"},
+		{prefix: "10.0.0.0/16", newbits: 2, netnum: 4, err: true},
+		{prefix: "10.0.0.0/30", newbits: 4, netnum: 0, err: true},
+	}
+	for _, c := range cases {
+		got, err := cidrsubnet(c.prefix, c.newbits, c.netnum)
+		if (err != nil) != c.err {
+			t.Errorf("unexpected error for %s %v %v: %v", c.prefix, c.newbits, c.netnum, err)
+		}
+		if got != c.want {
+			t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+		}
+	}
+}
+
+func TestCidrnetmask(t *testing.T) {
+	got, err := cidrnetmask("172.16.0.0/12")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "255.240.0.0"; got != want {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+	if _, err := cidrnetmask("fd00::/64"); err == nil {
+		t.Errorf("expected an error for an IPv6 network")
+	}
+}
+
+func TestPortOffset(t *testing.T) {
+	cases := []struct {
+		base   interface{}
+		offset interface{}
+		want   int
+		err    bool
+	}{
+		{base: 8080, offset: 1, want: 8081},
+		{base: "9000", offset: -10, want: 8990},
+		{base: 65535, offset: 1, err: true},
+		{base: "http", offset: 1, err: true},
+	}
+	for _, c := range cases {
+		got, err := portOffset(c.base, c.offset)
+		if (err != nil) != c.err {
+			t.Errorf("unexpected error for %v %v: %v", c.base, c.offset, err)
+		}
+		if got != c.want {
+			t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+		}
+	}
+}
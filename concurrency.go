@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/urfave/cli"
+)
+
+// deployAll deploys the resources in order, running up to concurrency consecutive
+// watchable resources in parallel. Any other resource, or a resource depending on
+// one being watched, waits for the watchable resources before it to complete, so
+// the order of dependencies is kept.
+func deployAll(c *cli.Context, resources []*ObjectResource, concurrency int) error {
+	awaitedResources = dependedOn(resources)
+	if concurrency < 2 {
+		for _, r := range resources {
+			if err := deployTracked(c, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var batch []*ObjectResource
+	for _, r := range resources {
+		if isWatchableResouce(r) {
+			// Wait for any dependencies being watched in parallel
+			if dependsOnAny(r, batch) {
+				if err := deployParallel(c, batch, concurrency); err != nil {
+					return err
+				}
+				batch = nil
+			}
+			batch = append(batch, r)
+			continue
+		}
+		if err := deployParallel(c, batch, concurrency); err != nil {
+			return err
+		}
+		batch = nil
+		if err := deployTracked(c, r); err != nil {
+			return err
+		}
+	}
+	return deployParallel(c, batch, concurrency)
+}
+
+// pendingWatches are the resources applied with --watch-after-apply, which are
+// watched once every resource has been applied
+var pendingWatches struct {
+	sync.Mutex
+	resources []*ObjectResource
+	// keys are the state keys of the resources, recorded once they are watched
+	keys map[*ObjectResource]string
+}
+
+// awaitedResources are the kind/name of the resources others depend on, which are
+// watched as they are applied even with --watch-after-apply
+var awaitedResources map[string]bool
+
+// dependedOn returns the kind/name of the resources any of the resources depend on
+func dependedOn(resources []*ObjectResource) map[string]bool {
+	refs := map[string]bool{}
+	for _, r := range resources {
+		for _, dep := range dependsOn(r) {
+			refs[dep] = true
+		}
+	}
+	return refs
+}
+
+// canDeferWatch checks if a resource can be watched after everything is applied,
+// only workloads nothing depends on are. Jobs and dependencies are still waited for.
+func canDeferWatch(r *ObjectResource) bool {
+	return contains(rolloutKinds, r.Kind) && !isCustomResource(r) && !awaitedResources[resourceRef(r)]
+}
+
+// deferWatch records a resource to watch after everything is applied
+func deferWatch(r *ObjectResource) {
+	pendingWatches.Lock()
+	defer pendingWatches.Unlock()
+	pendingWatches.resources = append(pendingWatches.resources, r)
+}
+
+// deferRecord keeps the state key of a resource waiting to be watched, so it is
+// only recorded as completed once its watch succeeds
+func deferRecord(r *ObjectResource, key string) bool {
+	pendingWatches.Lock()
+	defer pendingWatches.Unlock()
+	for _, pending := range pendingWatches.resources {
+		if pending == r {
+			if pendingWatches.keys == nil {
+				pendingWatches.keys = map[*ObjectResource]string{}
+			}
+			pendingWatches.keys[r] = key
+			return true
+		}
+	}
+	return false
+}
+
+// watchPending watches every resource waiting to be watched at the same time
+func watchPending(c *cli.Context) error {
+	pendingWatches.Lock()
+	resources, keys := pendingWatches.resources, pendingWatches.keys
+	pendingWatches.resources, pendingWatches.keys = nil, nil
+	pendingWatches.Unlock()
+	if len(resources) > 0 {
+		logInfo.Printf("watching %d resources", len(resources))
+	}
+	return inParallel(resources, len(resources), func(r *ObjectResource) error {
+		if err := watchWorkload(c, r); err != nil {
+			return err
+		}
+		if key, found := keys[r]; found {
+			return deployState.record(r, key)
+		}
+		return nil
+	})
+}
+
+// deployParallel deploys and watches resources using up to concurrency workers
+func deployParallel(c *cli.Context, resources []*ObjectResource, concurrency int) error {
+	return inParallel(resources, concurrency, func(r *ObjectResource) error {
+		return deployTracked(c, r)
+	})
+}
+
+// inParallel runs an action for each resource using up to concurrency workers,
+// failing with every error once they have all finished
+func inParallel(resources []*ObjectResource, concurrency int, action func(*ObjectResource) error) error {
+	if len(resources) == 0 {
+		return nil
+	}
+	logOutput.start(resources)
+	defer logOutput.stop(resources)
+	workers := make(chan struct{}, concurrency)
+	errs := make(chan error, len(resources))
+	var wg sync.WaitGroup
+	for _, r := range resources {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func(r *ObjectResource) {
+			defer wg.Done()
+			defer func() { <-workers }()
+			defer logOutput.end(r)
+			if err := action(r); err != nil {
+				errs <- fmt.Errorf("%s/%s: %s", strings.ToLower(r.Kind), r.Name, err)
+			}
+		}(r)
+	}
+	wg.Wait()
+	close(errs)
+
+	var failed []string
+	for err := range errs {
+		failed = append(failed, err.Error())
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d resources failed:\n%s", len(failed), len(resources), strings.Join(failed, "\n"))
+	}
+	return nil
+}
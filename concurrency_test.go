@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestInParallel(t *testing.T) {
+	resources := []*ObjectResource{
+		{Kind: "Deployment", ObjectMeta: ObjectMeta{Name: "api"}},
+		{Kind: "Deployment", ObjectMeta: ObjectMeta{Name: "web"}},
+		{Kind: "StatefulSet", ObjectMeta: ObjectMeta{Name: "db"}},
+	}
+	var calls int32
+	err := inParallel(resources, len(resources), func(r *ObjectResource) error {
+		atomic.AddInt32(&calls, 1)
+		if r.Name == "db" {
+			return errors.New("timed out")
+		}
+		return nil
+	})
+	if calls != 3 {
+		t.Errorf("got: %#v\nwant: %#v\n", calls, 3)
+	}
+	want := "1 of 3 resources failed:\nstatefulset/db: timed out"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("got: %#v\nwant: %#v\n", err, want)
+	}
+	if err := inParallel(nil, 0, nil); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestDeferRecord(t *testing.T) {
+	defer func() { pendingWatches.resources, pendingWatches.keys = nil, nil }()
+	deferred := &ObjectResource{Kind: "Deployment", ObjectMeta: ObjectMeta{Name: "api"}}
+	watched := &ObjectResource{Kind: "Deployment", ObjectMeta: ObjectMeta{Name: "web"}}
+	deferWatch(deferred)
+
+	if !deferRecord(deferred, "deployment//api") {
+		t.Errorf("expected the deferred resource to be recorded after its watch")
+	}
+	if deferRecord(watched, "deployment//web") {
+		t.Errorf("expected the watched resource to be recorded straight away")
+	}
+	if got := pendingWatches.keys[deferred]; got != "deployment//api" {
+		t.Errorf("got: %#v\nwant: %#v\n", got, "deployment//api")
+	}
+}
+
+func TestCanDeferWatch(t *testing.T) {
+	defer func() { awaitedResources = nil }()
+	api := &ObjectResource{APIVersion: "apps/v1", Kind: "Deployment", ObjectMeta: ObjectMeta{Name: "api"}}
+	db := &ObjectResource{APIVersion: "apps/v1", Kind: "StatefulSet", ObjectMeta: ObjectMeta{Name: "db"}}
+	migrate := &ObjectResource{APIVersion: "batch/v1", Kind: "Job", ObjectMeta: ObjectMeta{Name: "migrate"}}
+	api.Annotations = map[string]string{AnnotationDependsOn: "StatefulSet/db"}
+	awaitedResources = dependedOn([]*ObjectResource{api, db, migrate})
+
+	cases := []struct {
+		name string
+		r    *ObjectResource
+		want bool
+	}{
+		{name: "Check a workload is watched later", r: api, want: true},
+		{name: "Check a dependency is waited for", r: db, want: false},
+		{name: "Check a job is waited for", r: migrate, want: false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := canDeferWatch(c.r); got != c.want {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+// maxNameLength is the longest name a job created from a cronjob can have
+const maxNameLength = 52
+
+// triggerCronJob creates a job from a cronjob and watches it to completion, deleting
+// it afterwards when cleanup is set
+func triggerCronJob(c *cli.Context, r *ObjectResource, cleanup bool) (err error) {
+	job := &ObjectResource{
+		Kind:       "Job",
+		ObjectMeta: ObjectMeta{Name: cronJobRunName(r.Name, time.Now()), Namespace: r.Namespace},
+	}
+	logInfo.Printf("triggering cronjob/%s as job/%s", r.Name, job.Name)
+	out, err := runResourceKubeCmd(c, r, "create", "job", job.Name, "--from=cronjob/"+r.Name)
+	if err != nil {
+		return fmt.Errorf("problem triggering cronjob/%s: %s", r.Name, err)
+	}
+	logInfo.Print(out)
+	if c.Bool(FlagNoWait) {
+		return nil
+	}
+	if cleanup {
+		// The events and logs of a failed job are dumped by the watch before it is deleted
+		defer func() {
+			logInfo.Printf("deleting test job/%s", job.Name)
+			if _, deleteErr := runResourceKubeCmd(c, job, "delete", "job", job.Name, "--ignore-not-found"); deleteErr != nil && err == nil {
+				err = fmt.Errorf("problem deleting test job/%s: %s", job.Name, deleteErr)
+			}
+		}()
+	}
+	return watchResource(c, job)
+}
+
+// cronJobRunName is a unique name for a job triggered from a cronjob
+func cronJobRunName(cronJob string, now time.Time) string {
+	suffix := fmt.Sprintf("-kd-%d", now.Unix())
+	if len(cronJob)+len(suffix) > maxNameLength {
+		cronJob = strings.TrimRight(cronJob[:maxNameLength-len(suffix)], "-.")
+	}
+	return cronJob + suffix
+}
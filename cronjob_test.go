@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCronJobRunName(t *testing.T) {
+	now := time.Unix(1546344000, 0)
+	cases := []struct {
+		name    string
+		cronJob string
+		want    string
+	}{
+		{
+			name:    "Check a timestamp is added",
+			cronJob: "backup",
+			want:    "backup-kd-1546344000",
+		},
+		{
+			name:    "Check long names are shortened",
+			cronJob: strings.Repeat("a", 37) + "-" + strings.Repeat("b", 20),
+			want:    strings.Repeat("a", 37) + "-kd-1546344000",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := cronJobRunName(c.cronJob, now)
+			if got != c.want {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// derivedRefPattern matches a reference to a value in a derived value expression,
+// e.g. ${TAG} or ${APP | lower | trunc 20}
+var derivedRefPattern = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// dnsLabelPattern matches the characters which can't be used in a DNS-1123 label
+var dnsLabelPattern = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// valueTransforms are the functions a value can be piped through in a derived value
+var valueTransforms = map[string]func(value string, args []string) (string, error){
+	"lower": func(value string, args []string) (string, error) {
+		return strings.ToLower(value), nil
+	},
+	"upper": func(value string, args []string) (string, error) {
+		return strings.ToUpper(value), nil
+	},
+	"trim": func(value string, args []string) (string, error) {
+		return strings.TrimSpace(value), nil
+	},
+	"sha1": func(value string, args []string) (string, error) {
+		sum := sha1.Sum([]byte(value))
+		return hex.EncodeToString(sum[:]), nil
+	},
+	"sha256": func(value string, args []string) (string, error) {
+		sum := sha256.Sum256([]byte(value))
+		return hex.EncodeToString(sum[:]), nil
+	},
+	"base64": func(value string, args []string) (string, error) {
+		return base64.StdEncoding.EncodeToString([]byte(value)), nil
+	},
+	// dns makes a value usable as a name e.g. a branch name as a namespace
+	"dns": func(value string, args []string) (string, error) {
+		label := strings.Trim(dnsLabelPattern.ReplaceAllString(strings.ToLower(value), "-"), "-")
+		if len(label) > 63 {
+			label = strings.TrimRight(label[:63], "-")
+		}
+		return label, nil
+	},
+	"trunc": func(value string, args []string) (string, error) {
+		if len(args) != 1 {
+			return "", fmt.Errorf("trunc expects a length")
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 0 {
+			return "", fmt.Errorf("invalid trunc length %q", args[0])
+		}
+		if len(value) > n {
+			value = value[:n]
+		}
+		return value, nil
+	},
+}
+
+// derivedValue is a value computed from others before rendering
+type derivedValue struct {
+	name string
+	expr string
+}
+
+// parseDerivedValues parses the derived values, NAME=EXPRESSION, in order
+func parseDerivedValues(list []string) ([]derivedValue, error) {
+	var derived []derivedValue
+	for _, item := range list {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 || len(strings.TrimSpace(kv[0])) == 0 {
+			return nil, fmt.Errorf("invalid %s %q, expecting NAME=EXPRESSION", FlagDerive, item)
+		}
+		derived = append(derived, derivedValue{name: strings.TrimSpace(kv[0]), expr: kv[1]})
+	}
+	return derived, nil
+}
+
+// setDerivedValues evaluates the derived values and sets them in the environment, so
+// they're available to templates like other variables. Each can refer to those before
+// it, and variables already in the environment take precedence.
+func setDerivedValues(list []string) error {
+	derived, err := parseDerivedValues(list)
+	if err != nil {
+		return err
+	}
+	for _, d := range derived {
+		if _, set := os.LookupEnv(d.name); set {
+			logDebug.Printf("not deriving %s, it is already set", d.name)
+			continue
+		}
+		value, err := evaluateDerived(d.expr, os.LookupEnv)
+		if err != nil {
+			return fmt.Errorf("problem deriving %s: %s", d.name, err)
+		}
+		if err := os.Setenv(d.name, value); err != nil {
+			return fmt.Errorf("invalid %s %q: %s", FlagDerive, d.name, err)
+		}
+	}
+	return nil
+}
+
+// evaluateDerived replaces each reference in an expression with the value, piped
+// through any transforms
+func evaluateDerived(expr string, lookup func(string) (string, bool)) (string, error) {
+	var problem error
+	result := derivedRefPattern.ReplaceAllStringFunc(expr, func(ref string) string {
+		if problem != nil {
+			return ""
+		}
+		steps := strings.Split(derivedRefPattern.FindStringSubmatch(ref)[1], "|")
+		name := strings.TrimSpace(steps[0])
+		value, found := lookup(name)
+		if !found {
+			problem = fmt.Errorf("%s isn't set", name)
+			return ""
+		}
+		for _, step := range steps[1:] {
+			fields := strings.Fields(step)
+			if len(fields) == 0 {
+				problem = fmt.Errorf("empty transform in %s", ref)
+				return ""
+			}
+			transform, found := valueTransforms[fields[0]]
+			if !found {
+				problem = fmt.Errorf("unknown transform %q in %s", fields[0], ref)
+				return ""
+			}
+			if value, problem = transform(value, fields[1:]); problem != nil {
+				return ""
+			}
+		}
+		return value
+	})
+	return result, problem
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEvaluateDerived(t *testing.T) {
+	env := map[string]string{
+		"REGISTRY": "quay.io/uswitch",
+		"APP":      "Payments API",
+		"TAG":      "v1.2.3",
+		"BRANCH":   "feature/ADD_Cards",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+	cases := []struct {
+		expr string
+		want string
+		err  bool
+	}{
+		{expr: "${REGISTRY}/${APP | dns}:${TAG}", want: "quay.io/uswitch/payments-api:v1.2.3"},
+		{expr: "${BRANCH|lower|dns}", want: "feature-add-cards"},
+		{expr: "${TAG | sha256 | trunc 8}", want: "e3cad1a6"},
+		{expr: "${APP | upper | trim}-$HOME", want: "PAYMENTS API-$HOME"},
+		{expr: "${MISSING}", err: true},
+		{expr: "${TAG | reverse}", err: true},
+		{expr: "${TAG | trunc}", err: true},
+	}
+	for _, c := range cases {
+		got, err := evaluateDerived(c.expr, lookup)
+		if (err != nil) != c.err {
+			t.Errorf("unexpected error for %s: %v", c.expr, err)
+		}
+		if err == nil && got != c.want {
+			t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+		}
+	}
+}
+
+func TestParseDerivedValues(t *testing.T) {
+	got, err := parseDerivedValues([]string{"IMAGE=${REGISTRY}/app:${TAG}", " URL =https://${HOST}/?a=b"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []derivedValue{
+		{name: "IMAGE", expr: "${REGISTRY}/app:${TAG}"},
+		{name: "URL", expr: "https://${HOST}/?a=b"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+	if _, err := parseDerivedValues([]string{"IMAGE"}); err == nil {
+		t.Errorf("expected an error for a value without an expression")
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"github.com/urfave/cli"
+)
+
+// errResourcesChanged is returned when a diff finds resources which would change
+var errResourcesChanged = errors.New("resources differ from the cluster")
+
+// diff will render all the resources and show what would change for each one
+func diff(c *cli.Context) error {
+	if c.Bool("debug") {
+		logDebug = logDebugIf
+	}
+	format := c.String(FlagDiffFormat)
+	if err := checkDiffFormat(format); err != nil {
+		return err
+	}
+	ignore, err := parseIgnoreRules(c.StringSlice(FlagDiffIgnore))
+	if err != nil {
+		return err
+	}
+	resources, err := renderResources(c)
+	if err != nil {
+		return err
+	}
+	changed := false
+	for _, r := range resources {
+		if c.Bool(FlagPreserveReplicas) {
+			if err := preserveReplicas(c, r); err != nil {
+				return err
+			}
+		}
+		out, err := diffResourceAs(c, r, format, ignore)
+		if err != nil {
+			return err
+		}
+		if len(out) == 0 {
+			logInfo.Printf("no changes for %s/%s", strings.ToLower(r.Kind), r.Name)
+			continue
+		}
+		changed = true
+		logInfo.Printf("changes for %s/%s:\n%s", strings.ToLower(r.Kind), r.Name, out)
+	}
+	if changed {
+		return errResourcesChanged
+	}
+	return nil
+}
+
+// diffResource will use kubectl diff to compare a resource with the cluster
+func diffResource(c *cli.Context, r *ObjectResource) (string, error) {
+	serverSide, err := serverSideArgs(c)
+	if err != nil {
+		return "", err
+	}
+	cmd, err := newResourceKubeCmd(c, r, append([]string{"diff", "-f", "-"}, serverSide...), true)
+	if err != nil {
+		return "", err
+	}
+	logDebug.Printf("kubectl arguments: %q", strings.Join(cmd.Args, " "))
+
+	var outbuf, errbuf bytes.Buffer
+	cmd.Stdin = bytes.NewReader(r.Template)
+	cmd.Stdout = &outbuf
+	cmd.Stderr = &errbuf
+
+	// kubectl diff exits with 1 when there are differences and > 1 on error
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.ExitStatus() == 1 {
+				return outbuf.String(), nil
+			}
+		}
+		if errbuf.Len() > 0 {
+			return "", fmt.Errorf(errbuf.String())
+		}
+		return "", err
+	}
+	return outbuf.String(), nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli"
+)
+
+// fakeKubectl is a kubectl which diffs the resources named changed and new, and
+// finds no changes for any other resource
+const fakeKubectl = `#!/bin/sh
+input=$(cat)
+case "$input" in
+*"name: changed"*)
+  echo "-  replicas: 2"
+  echo "+  replicas: 3"
+  exit 1 ;;
+*"name: new"*)
+  echo "+apiVersion: v1"
+  echo "+kind: ConfigMap"
+  exit 1 ;;
+*"name: broken"*)
+  echo "error: the server is currently unable to handle the request" >&2
+  exit 2 ;;
+esac
+exit 0
+`
+
+// withFakeKubectl puts a kubectl script first in the PATH for a test
+func withFakeKubectl(t *testing.T, script string) func() {
+	dir, err := ioutil.TempDir("", "kd-kubectl")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "kubectl"), []byte(script), 0755); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	return func() {
+		os.Setenv("PATH", path)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestDiffResource(t *testing.T) {
+	defer withFakeKubectl(t, fakeKubectl)()
+	c := cli.NewContext(nil, flag.NewFlagSet("diff", flag.ContinueOnError), nil)
+
+	cases := []struct {
+		name     string
+		resource string
+		want     string
+		wantErr  string
+	}{
+		{name: "Check an unchanged resource has no diff", resource: "unchanged"},
+		{name: "Check a changed resource is diffed", resource: "changed", want: "-  replicas: 2\n+  replicas: 3\n"},
+		{name: "Check a new resource is diffed", resource: "new", want: "+apiVersion: v1\n+kind: ConfigMap\n"},
+		{name: "Check kubectl errors are returned", resource: "broken", wantErr: "unable to handle the request"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &ObjectResource{Kind: "ConfigMap", ObjectMeta: ObjectMeta{Name: tc.resource},
+				Template: []byte("kind: ConfigMap\nmetadata:\n  name: " + tc.resource + "\n")}
+			got, err := diffResource(c, r)
+			if len(tc.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("got: %#v\nwant error: %#v\n", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != tc.want {
+				t.Errorf("got: %#v\nwant: %#v\n", got, tc.want)
+			}
+		})
+	}
+}
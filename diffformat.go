@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// serverFields are the fields the api server sets which never come from a manifest
+var serverFields = [][]string{
+	{"status"},
+	{"metadata", "managedFields"},
+	{"metadata", "resourceVersion"},
+	{"metadata", "uid"},
+	{"metadata", "generation"},
+	{"metadata", "creationTimestamp"},
+	{"metadata", "selfLink"},
+	{"metadata", "annotations", "kubectl.kubernetes.io/last-applied-configuration"},
+	{"metadata", "annotations", "deployment.kubernetes.io/revision"},
+}
+
+// patchOp is a json patch (RFC 6902) operation
+type patchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// checkDiffFormat validates how the diff command shows the changes
+func checkDiffFormat(format string) error {
+	switch format {
+	case "unified", "json-patch", "semantic":
+		return nil
+	}
+	return fmt.Errorf("invalid %s %q, expecting unified, json-patch or semantic", FlagDiffFormat, format)
+}
+
+// diffResourceAs compares a resource with the cluster, showing the changes in a format
+// without the ignored fields
+func diffResourceAs(c *cli.Context, r *ObjectResource, format string, ignore []ignoreRule) (string, error) {
+	if format == "unified" && len(ignore) == 0 {
+		return diffResource(c, r)
+	}
+	live, err := liveObject(c, r)
+	if err != nil {
+		return "", err
+	}
+	if live == nil {
+		return fmt.Sprintf("%s would be created\n", resourceRef(r)), nil
+	}
+	if format == "semantic" {
+		var desired interface{}
+		if err := yaml.Unmarshal(r.Template, &desired); err != nil {
+			return "", err
+		}
+		changes := semanticChanges("", ignoreFields(desired, r.Kind, ignore), ignoreFields(removeServerFields(live), r.Kind, ignore))
+		if len(changes) == 0 {
+			return "", nil
+		}
+		return strings.Join(changes, "\n") + "\n", nil
+	}
+	// The server side dry run has the defaults the api server would set, so only
+	// the changes from the manifest are in the patch
+	desired, err := dryRunObject(c, r, "--"+FlagDiffFormat+"="+format)
+	if err != nil {
+		return "", err
+	}
+	if format == "unified" {
+		return unifiedDiff(resourceRef(r), ignoreFields(removeServerFields(live), r.Kind, ignore), ignoreFields(removeServerFields(desired), r.Kind, ignore))
+	}
+	ops := jsonPatch("", ignoreFields(removeServerFields(live), r.Kind, ignore), ignoreFields(removeServerFields(desired), r.Kind, ignore))
+	if len(ops) == 0 {
+		return "", nil
+	}
+	out, err := json.MarshalIndent(ops, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out) + "\n", nil
+}
+
+// liveObject gets a resource from the cluster, nil when it doesn't exist
+func liveObject(c *cli.Context, r *ObjectResource) (interface{}, error) {
+	out, err := resourceKubeOutput(c, r, nil, "get", kubectlRef(r), "-o", "yaml", "--ignore-not-found")
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(out)) == 0 {
+		return nil, nil
+	}
+	var live interface{}
+	return live, yaml.Unmarshal(out, &live)
+}
+
+// unifiedDiff is the text diff of the live and desired resource as yaml, like
+// kubectl diff it runs diff -u and is empty when they are the same
+func unifiedDiff(ref string, live, desired interface{}) (string, error) {
+	dir, err := ioutil.TempDir("", "kd-diff")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	var files []string
+	for _, side := range []struct {
+		name string
+		obj  interface{}
+	}{{"live", live}, {"desired", desired}} {
+		data, err := yaml.Marshal(side.obj)
+		if err != nil {
+			return "", err
+		}
+		fn := filepath.Join(dir, side.name+".yaml")
+		if err := ioutil.WriteFile(fn, data, 0600); err != nil {
+			return "", err
+		}
+		files = append(files, fn)
+	}
+	cmd := exec.Command("diff", "-u", "--label", "live/"+ref, "--label", "desired/"+ref, files[0], files[1])
+	var outbuf, errbuf bytes.Buffer
+	cmd.Stdout = &outbuf
+	cmd.Stderr = &errbuf
+	// diff exits with 1 when the files differ and > 1 on error
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.ExitStatus() == 1 {
+				return outbuf.String(), nil
+			}
+		}
+		if errbuf.Len() > 0 {
+			return "", errors.New(errbuf.String())
+		}
+		return "", err
+	}
+	return "", nil
+}
+
+// dryRunObject is a resource as the api server would store it when applied, the
+// feature needing it is named when kubectl is too old
+func dryRunObject(c *cli.Context, r *ObjectResource, feature string) (interface{}, error) {
+	if err := requireKubectl(c, minServerDryRunMinor, feature); err != nil {
+		return nil, err
+	}
+	serverSide, err := serverSideArgs(c)
+	if err != nil {
+		return nil, err
+	}
+	args := append([]string{"apply", "--dry-run=server", "-o", "yaml", "-f", "-"}, serverSide...)
+	out, err := resourceKubeOutput(c, r, r.Template, args...)
+	if err != nil {
+		return nil, err
+	}
+	var desired interface{}
+	return desired, yaml.Unmarshal(out, &desired)
+}
+
+// resourceKubeOutput runs kubectl for a resource and returns the output
+func resourceKubeOutput(c *cli.Context, r *ObjectResource, stdin []byte, args ...string) ([]byte, error) {
+	cmd, err := newResourceKubeCmd(c, r, args, false)
+	if err != nil {
+		return nil, err
+	}
+	logDebug.Printf("kubectl arguments: %q", strings.Join(cmd.Args, " "))
+
+	var outbuf, errbuf bytes.Buffer
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	cmd.Stdout = &outbuf
+	cmd.Stderr = &errbuf
+	if err := cmd.Run(); err != nil {
+		if errbuf.Len() > 0 {
+			return nil, fmt.Errorf(errbuf.String())
+		}
+		return nil, err
+	}
+	return outbuf.Bytes(), nil
+}
+
+// removeServerFields removes the fields set by the api server from an object
+func removeServerFields(doc interface{}) interface{} {
+	for _, path := range serverFields {
+		parent, ok := lookupPath(doc, path[:len(path)-1]...).(map[interface{}]interface{})
+		if !ok {
+			continue
+		}
+		delete(parent, path[len(path)-1])
+		if len(parent) == 0 && len(path) > 2 {
+			delete(lookupPath(doc, path[:len(path)-2]...).(map[interface{}]interface{}), path[len(path)-2])
+		}
+	}
+	return doc
+}
+
+// jsonPatch is the json patch operations which change one object into another
+func jsonPatch(path string, from, to interface{}) []patchOp {
+	if reflect.DeepEqual(from, to) {
+		return nil
+	}
+	fromMap, fromIsMap := from.(map[interface{}]interface{})
+	toMap, toIsMap := to.(map[interface{}]interface{})
+	if fromIsMap && toIsMap {
+		var ops []patchOp
+		for _, k := range sortedKeys(fromMap, toMap) {
+			child := path + "/" + pointerEscape(fmt.Sprint(k))
+			fromValue, inFrom := fromMap[k]
+			toValue, inTo := toMap[k]
+			switch {
+			case !inTo:
+				ops = append(ops, patchOp{Op: "remove", Path: child})
+			case !inFrom:
+				ops = append(ops, patchOp{Op: "add", Path: child, Value: jsonValue(toValue)})
+			default:
+				ops = append(ops, jsonPatch(child, fromValue, toValue)...)
+			}
+		}
+		return ops
+	}
+	fromList, fromIsList := from.([]interface{})
+	toList, toIsList := to.([]interface{})
+	if fromIsList && toIsList && len(fromList) == len(toList) {
+		var ops []patchOp
+		for i := range fromList {
+			ops = append(ops, jsonPatch(fmt.Sprintf("%s/%d", path, i), fromList[i], toList[i])...)
+		}
+		return ops
+	}
+	return []patchOp{{Op: "replace", Path: path, Value: jsonValue(to)}}
+}
+
+// semanticChanges describes the changes to the fields set in a manifest, one per
+// line. Fields only in the live object (e.g. defaulted by the api server) are
+// ignored, as is the order of lists.
+func semanticChanges(path string, desired, live interface{}) []string {
+	desiredMap, desiredIsMap := desired.(map[interface{}]interface{})
+	liveMap, liveIsMap := live.(map[interface{}]interface{})
+	if desiredIsMap && liveIsMap {
+		var changes []string
+		for _, k := range sortedKeys(desiredMap) {
+			child := fieldPath(path, fmt.Sprint(k))
+			liveValue, found := liveMap[k]
+			if !found {
+				changes = append(changes, fmt.Sprintf("%s: <none> -> %s", child, displayValue(desiredMap[k])))
+				continue
+			}
+			changes = append(changes, semanticChanges(child, desiredMap[k], liveValue)...)
+		}
+		return changes
+	}
+	desiredList, desiredIsList := desired.([]interface{})
+	liveList, liveIsList := live.([]interface{})
+	if desiredIsList && liveIsList {
+		return semanticListChanges(path, desiredList, liveList)
+	}
+	if fmt.Sprint(desired) != fmt.Sprint(live) {
+		return []string{fmt.Sprintf("%s: %s -> %s", path, displayValue(live), displayValue(desired))}
+	}
+	return nil
+}
+
+// semanticListChanges compares lists ignoring their order, items with a name (such
+// as containers, ports or env) are matched by it
+func semanticListChanges(path string, desired, live []interface{}) []string {
+	desiredNamed, desiredOk := namedItems(desired)
+	liveNamed, liveOk := namedItems(live)
+	if desiredOk && liveOk {
+		var changes []string
+		for _, name := range sortedNames(desiredNamed) {
+			child := fmt.Sprintf("%s[%s]", path, name)
+			liveItem, found := liveNamed[name]
+			if !found {
+				changes = append(changes, fmt.Sprintf("%s: <none> -> %s", child, displayValue(desiredNamed[name])))
+				continue
+			}
+			changes = append(changes, semanticChanges(child, desiredNamed[name], liveItem)...)
+		}
+		for _, name := range sortedNames(liveNamed) {
+			if _, found := desiredNamed[name]; !found {
+				changes = append(changes, fmt.Sprintf("%s[%s]: %s -> <none>", path, name, displayValue(liveNamed[name])))
+			}
+		}
+		return changes
+	}
+	if len(desired) == len(live) {
+		if sameItems(desired, live) {
+			return nil
+		}
+		var changes []string
+		for i := range desired {
+			changes = append(changes, semanticChanges(fmt.Sprintf("%s[%d]", path, i), desired[i], live[i])...)
+		}
+		if len(changes) > 0 {
+			return changes
+		}
+	}
+	return []string{fmt.Sprintf("%s: %s -> %s", path, displayValue(live), displayValue(desired))}
+}
+
+// namedItems indexes a list by the name of each item, false unless every item has one
+func namedItems(list []interface{}) (map[string]interface{}, bool) {
+	named := map[string]interface{}{}
+	for _, item := range list {
+		name, ok := lookupPath(item, "name").(string)
+		if !ok {
+			return nil, false
+		}
+		named[name] = item
+	}
+	return named, len(list) > 0
+}
+
+// sameItems checks if two lists have the same items in any order
+func sameItems(a, b []interface{}) bool {
+	count := map[string]int{}
+	for _, item := range a {
+		count[displayValue(item)]++
+	}
+	for _, item := range b {
+		count[displayValue(item)]--
+	}
+	for _, n := range count {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// sortedKeys are the keys of yaml maps in order
+func sortedKeys(maps ...map[interface{}]interface{}) []interface{} {
+	seen := map[string]interface{}{}
+	for _, m := range maps {
+		for k := range m {
+			seen[fmt.Sprint(k)] = k
+		}
+	}
+	var names []string
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	keys := make([]interface{}, len(names))
+	for i, name := range names {
+		keys[i] = seen[name]
+	}
+	return keys
+}
+
+// sortedNames are the names of the items of a list in order
+func sortedNames(named map[string]interface{}) []string {
+	var names []string
+	for name := range named {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fieldPath adds a field to a dotted path, quoting keys such as annotations with dots
+func fieldPath(path, key string) string {
+	if strings.ContainsAny(key, "./") {
+		return fmt.Sprintf("%s[%q]", path, key)
+	}
+	if len(path) == 0 {
+		return key
+	}
+	return path + "." + key
+}
+
+// pointerEscape escapes a key for a json pointer
+func pointerEscape(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+// displayValue is a yaml value on one line, maps and lists as json
+func displayValue(value interface{}) string {
+	switch value.(type) {
+	case map[interface{}]interface{}, []interface{}:
+		out, err := json.Marshal(jsonValue(value))
+		if err == nil {
+			return string(out)
+		}
+	}
+	return fmt.Sprint(value)
+}
+
+// jsonValue converts a yaml value so it can be written as json
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for k, item := range v {
+			m[fmt.Sprint(k)] = jsonValue(item)
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = jsonValue(item)
+		}
+		return list
+	}
+	return value
+}
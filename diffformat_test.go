@@ -0,0 +1,132 @@
+package main
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestSemanticChanges(t *testing.T) {
+	var docs []interface{}
+	for _, fn := range []string{"test/TestSemanticChanges/desired.yaml", "test/TestSemanticChanges/live.yaml"} {
+		data, err := ioutil.ReadFile(fn)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		docs = append(docs, doc)
+	}
+
+	got := semanticChanges("", docs[0], removeServerFields(docs[1]))
+	want := []string{
+		"spec.replicas: 2 -> 3",
+		"spec.template.spec.containers[api].env[LOG_LEVEL].value: debug -> info",
+		"spec.template.spec.containers[api].image: api:v1 -> api:v2",
+		`spec.template.spec.containers[metrics]: {"image":"exporter:1.0","name":"metrics"} -> <none>`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	cases := []struct {
+		name string
+		from string
+		to   string
+		want []patchOp
+	}{
+		{
+			name: "Check no operations for the same objects",
+			from: "spec: {replicas: 2}",
+			to:   "spec: {replicas: 2}",
+		},
+		{
+			name: "Check fields are added, removed and replaced",
+			from: "spec: {replicas: 2, paused: true}",
+			to:   "spec: {replicas: 3, minReadySeconds: 10}",
+			want: []patchOp{
+				{Op: "add", Path: "/spec/minReadySeconds", Value: 10},
+				{Op: "remove", Path: "/spec/paused"},
+				{Op: "replace", Path: "/spec/replicas", Value: 3},
+			},
+		},
+		{
+			name: "Check list items are patched by index",
+			from: "args: [--a, --b]",
+			to:   "args: [--a, --c]",
+			want: []patchOp{{Op: "replace", Path: "/args/1", Value: "--c"}},
+		},
+		{
+			name: "Check lists of a different length are replaced",
+			from: "args: [--a]",
+			to:   "args: [--a, --b]",
+			want: []patchOp{{Op: "replace", Path: "/args", Value: []interface{}{"--a", "--b"}}},
+		},
+		{
+			name: "Check keys are escaped",
+			from: "annotations: {example.com/owner: a}",
+			to:   "annotations: {example.com/owner: b}",
+			want: []patchOp{{Op: "replace", Path: "/annotations/example.com~1owner", Value: "b"}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var from, to interface{}
+			if err := yaml.Unmarshal([]byte(c.from), &from); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if err := yaml.Unmarshal([]byte(c.to), &to); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			got := jsonPatch("", from, to)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
+
+func TestCheckDiffFormat(t *testing.T) {
+	for _, format := range []string{"unified", "json-patch", "semantic"} {
+		if err := checkDiffFormat(format); err != nil {
+			t.Errorf("got: %#v\nwant: nil\n", err)
+		}
+	}
+	if err := checkDiffFormat("side-by-side"); err == nil {
+		t.Errorf("got: nil\nwant: an error\n")
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	var live, desired interface{}
+	if err := yaml.Unmarshal([]byte("spec:\n  replicas: 5\n  paused: false\n"), &live); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := yaml.Unmarshal([]byte("spec:\n  replicas: 2\n  paused: true\n"), &desired); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ignore, err := parseIgnoreRules([]string{"Deployment:spec.replicas"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got, err := unifiedDiff("deployment/api", ignoreFields(live, "Deployment", ignore), ignoreFields(desired, "Deployment", ignore))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := "--- live/deployment/api\n+++ desired/deployment/api\n@@ -1,2 +1,2 @@\n spec:\n-  paused: false\n+  paused: true\n"
+	if got != want {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+
+	got, err = unifiedDiff("deployment/api", ignoreFields(live, "Deployment", ignore), ignoreFields(live, "Deployment", ignore))
+	if err != nil || got != "" {
+		t.Errorf("expected no changes, got: %#v %v", got, err)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+// envCreate will create an environment namespace and deploy the resources into it
+func envCreate(c *cli.Context) error {
+	if c.Bool("debug") {
+		logDebug = logDebugIf
+	}
+	name, err := envName(c)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		logInfo.Printf("dry run, skipping creation of environment %s", name)
+		return run(c)
+	}
+	if err := ensureNamespace(c, name, map[string]string{LabelManaged: "true"}); err != nil {
+		return err
+	}
+	if c.IsSet(FlagTTL) {
+		expires := time.Now().Add(c.Duration(FlagTTL)).UTC().Format(time.RFC3339)
+		logInfo.Printf("environment %s will expire at %s", name, expires)
+		if _, err := runKubeCmd(c, "annotate", "namespace", name, AnnotationExpires+"="+expires, "--overwrite"); err != nil {
+			return err
+		}
+	}
+	// Resources removed from the manifests are pruned when the environment is updated,
+	// and those outside its namespace are found by env destroy
+	if !c.IsSet(FlagPruneSelector) {
+		for flag, value := range map[string]string{FlagPrune: "true", FlagPruneSelector: LabelEnv + "=" + name} {
+			if err := c.Set(flag, value); err != nil {
+				return err
+			}
+		}
+	}
+	return run(c)
+}
+
+// createNamespace ensures the namespaces resources are deployed to exist, with
+// any labels from --namespace-labels
+func createNamespace(c *cli.Context, resources []*ObjectResource) error {
+	names := resourceNamespaces(resources, c.String("namespace"))
+	if len(names) == 0 {
+		return fmt.Errorf("a namespace must be specified with --namespace to use --%s", FlagCreateNamespace)
+	}
+	labels := map[string]string{}
+	if c.IsSet(FlagNamespaceLabels) {
+		var err error
+		if labels, err = parseSelector(c.String(FlagNamespaceLabels)); err != nil {
+			return err
+		}
+	}
+	for _, name := range names {
+		if err := ensureNamespace(c, name, labels); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resourceNamespaces are the namespaces of the namespaced resources, those without
+// one in their metadata are deployed to the default namespace
+func resourceNamespaces(resources []*ObjectResource, namespace string) []string {
+	var names []string
+	if len(namespace) > 0 {
+		names = append(names, namespace)
+	}
+	for _, r := range resources {
+		if isClusterScoped(r.Kind) || len(r.Namespace) == 0 || contains(names, r.Namespace) {
+			continue
+		}
+		names = append(names, r.Namespace)
+	}
+	return names
+}
+
+// ensureNamespace creates a namespace if it doesn't exist and sets its labels
+func ensureNamespace(c *cli.Context, name string, labels map[string]string) error {
+	exists, err := NewK8ApiKubectl(c).Exists(&ObjectResource{
+		Kind:       "namespace",
+		ObjectMeta: ObjectMeta{Name: name},
+	})
+	if err != nil {
+		return fmt.Errorf("problem checking if namespace %s exists", name)
+	}
+	if !exists {
+		logInfo.Printf("creating namespace %s", name)
+		if _, err := runKubeCmd(c, "create", "namespace", name); err != nil {
+			return err
+		}
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	args := append([]string{"label", "namespace", name}, labelArgs(labels)...)
+	_, err = runKubeCmd(c, append(args, "--overwrite")...)
+	return err
+}
+
+// labelArgs are the key=value arguments to kubectl label, sorted by key
+func labelArgs(labels map[string]string) []string {
+	var args []string
+	for k, v := range labels {
+		args = append(args, k+"="+v)
+	}
+	sort.Strings(args)
+	return args
+}
+
+// envDestroy will delete an environment namespace created by envCreate
+func envDestroy(c *cli.Context) error {
+	if c.Bool("debug") {
+		logDebug = logDebugIf
+	}
+	name, err := envName(c)
+	if err != nil {
+		return err
+	}
+	exists, err := NewK8ApiKubectl(c).Exists(&ObjectResource{
+		Kind:       "namespace",
+		ObjectMeta: ObjectMeta{Name: name},
+	})
+	if err != nil {
+		return fmt.Errorf("problem checking if namespace %s exists", name)
+	}
+	if !exists {
+		logInfo.Printf("skipping destroy for environment %s as it does not exist", name)
+		return nil
+	}
+	// Never remove a namespace kd didn't create
+	managed, err := runKubeCmd(c, "get", "namespace", name,
+		"-o", "jsonpath={.metadata.labels."+jsonPathKey(LabelManaged)+"}")
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(managed) != "true" {
+		return fmt.Errorf("namespace %s is not managed by kd, refusing to destroy it", name)
+	}
+	if dryRun {
+		logInfo.Printf("dry run, skipping destroy of environment %s", name)
+		return nil
+	}
+	if err := pruneEnv(c, name); err != nil {
+		return err
+	}
+	logInfo.Printf("deleting namespace %s", name)
+	out, err := runKubeCmd(c, "delete", "namespace", name)
+	if err != nil {
+		return err
+	}
+	logInfo.Print(out)
+	return nil
+}
+
+// pruneEnv deletes the resources of an environment which aren't deleted with its
+// namespace, those which are cluster scoped or were deployed to other namespaces
+func pruneEnv(c *cli.Context, name string) error {
+	cmd, err := newKubeCmdScoped(c, []string{"get", c.String(FlagPruneKinds), "--all-namespaces",
+		"-l", LabelEnv + "=" + name,
+		"-o", "custom-columns=KIND:.kind,NAMESPACE:.metadata.namespace,NAME:.metadata.name", "--no-headers"}, false, false, false)
+	if err != nil {
+		return err
+	}
+	out, err := runCmdOutput(cmd)
+	if err != nil {
+		return err
+	}
+	for _, left := range envLeftovers(out, name) {
+		logInfo.Printf("pruning %s left over from environment %s", left, name)
+		args := []string{"delete", left.ref, "--ignore-not-found"}
+		if len(left.namespace) > 0 {
+			args = append(args, "--namespace="+left.namespace)
+		}
+		cmd, err := newKubeCmdScoped(c, args, false, false, false)
+		if err != nil {
+			return err
+		}
+		if _, err := runCmdOutput(cmd); err != nil {
+			return fmt.Errorf("problem pruning %s: %s", left, err)
+		}
+	}
+	return nil
+}
+
+// envLeftover is a resource of an environment outside its namespace
+type envLeftover struct {
+	namespace string
+	ref       string
+}
+
+// String formats a left over resource for messages
+func (l envLeftover) String() string {
+	if len(l.namespace) == 0 {
+		return l.ref
+	}
+	return l.namespace + "/" + l.ref
+}
+
+// envLeftovers returns the resources labelled with an environment which aren't in
+// its namespace, from the kind, namespace and name of each
+func envLeftovers(out, name string) []envLeftover {
+	var leftovers []envLeftover
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		kind, namespace, resource := fields[0], fields[1], fields[2]
+		if namespace == name || (kind == "Namespace" && resource == name) {
+			continue
+		}
+		// Cluster scoped resources have no namespace
+		if namespace == "<none>" {
+			namespace = ""
+		}
+		leftovers = append(leftovers, envLeftover{namespace: namespace, ref: strings.ToLower(kind) + "/" + resource})
+	}
+	return leftovers
+}
+
+// envName gets the environment name and targets the namespace of the same name
+func envName(c *cli.Context) (string, error) {
+	name := c.String(FlagEnvName)
+	if len(name) == 0 {
+		return "", fmt.Errorf("an environment name must be specified with --%s", FlagEnvName)
+	}
+	if err := c.Set("namespace", name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLabelArgs(t *testing.T) {
+	got := labelArgs(map[string]string{"team": "payments", LabelManaged: "true", "app": "review-123"})
+	want := []string{"app=review-123", LabelManaged + "=true", "team=payments"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+}
+
+func TestResourceNamespaces(t *testing.T) {
+	resources := []*ObjectResource{
+		{Kind: "Deployment", ObjectMeta: ObjectMeta{Name: "api"}},
+		{Kind: "Deployment", ObjectMeta: ObjectMeta{Name: "worker", Namespace: "jobs"}},
+		{Kind: "ClusterRole", ObjectMeta: ObjectMeta{Name: "reader", Namespace: "ignored"}},
+		{Kind: "Service", ObjectMeta: ObjectMeta{Name: "worker", Namespace: "jobs"}},
+		{Kind: "ConfigMap", ObjectMeta: ObjectMeta{Name: "settings", Namespace: "review-123"}},
+	}
+	cases := []struct {
+		name      string
+		namespace string
+		want      []string
+	}{
+		{
+			name:      "Check the namespace flag comes first",
+			namespace: "review-123",
+			want:      []string{"review-123", "jobs"},
+		},
+		{
+			name: "Check only the namespaces of resources without the flag",
+			want: []string{"jobs", "review-123"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := resourceNamespaces(resources, c.namespace)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
+
+func TestEnvLeftovers(t *testing.T) {
+	out := `Namespace    <none>      pr-123
+Namespace    <none>      pr-123-jobs
+Deployment   pr-123      api
+Deployment   pr-123-jobs worker
+ConfigMap    shared      pr-123-routes
+`
+	want := []envLeftover{
+		{ref: "namespace/pr-123-jobs"},
+		{namespace: "pr-123-jobs", ref: "deployment/worker"},
+		{namespace: "shared", ref: "configmap/pr-123-routes"},
+	}
+	if got := envLeftovers(out, "pr-123"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+}
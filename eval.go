@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// debugContextMarker is inserted into a template to print the context at a line
+func debugContextMarker() string {
+	left, right := leftDelim, rightDelim
+	if len(left) == 0 {
+		left, right = "{{", "}}"
+	}
+	return left + " debugContext . " + right
+}
+
+// debugRenderAt is the file:line the template context is printed at
+var debugRenderAt string
+
+// evalTemplate renders a single template expression with the kd config data
+func evalTemplate(c *cli.Context) error {
+	if c.Bool("debug") {
+		logDebug = logDebugIf
+	}
+	if c.NArg() != 1 {
+		return errors.New("expecting a single template expression to evaluate")
+	}
+	conf, err := GetAnyConfigData(c)
+	if err != nil {
+		return err
+	}
+	if c.IsSet(FlagAllowMissing) {
+		allowMissingVariables = true
+	}
+	if c.IsSet(FlagReproducible) {
+		reproducible = true
+	}
+	if c.IsSet(FlagTemplateDelims) {
+		if leftDelim, rightDelim, err = parseDelims(c.String(FlagTemplateDelims)); err != nil {
+			return err
+		}
+	}
+	var k8api K8Api
+	if dryRun {
+		k8api = NewK8ApiNoop()
+	} else {
+		k8api = NewK8ApiKubectl(c)
+	}
+	out, _, err := Render(k8api, c.Args().First(), conf)
+	if err != nil {
+		return err
+	}
+	fmt.Println(out)
+	return nil
+}
+
+// parseDebugRender splits a --debug-render value of the form file.yaml:LINE
+func parseDebugRender(value string) (string, int, error) {
+	i := strings.LastIndex(value, ":")
+	if i < 1 {
+		return "", 0, fmt.Errorf("invalid %s %q, expecting file.yaml:LINE", FlagDebugRender, value)
+	}
+	line, err := strconv.Atoi(value[i+1:])
+	if err != nil || line < 1 {
+		return "", 0, fmt.Errorf("invalid %s %q, expecting file.yaml:LINE", FlagDebugRender, value)
+	}
+	return filepath.Clean(value[:i]), line, nil
+}
+
+// insertDebugMarker adds the debug context marker to the start of a line of a template
+func insertDebugMarker(data []byte, line int) ([]byte, error) {
+	lines := strings.Split(string(data), "\n")
+	if line > len(lines) {
+		return nil, fmt.Errorf("line %d is past the end of the file (%d lines)", line, len(lines))
+	}
+	if strings.HasPrefix(lines[line-1], "---") {
+		return nil, fmt.Errorf("line %d is a document separator", line)
+	}
+	lines[line-1] = debugContextMarker() + lines[line-1]
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// debugContext prints the template context (dot) where the marker was inserted
+func debugContext(dot interface{}) (string, error) {
+	b, err := yaml.Marshal(dot)
+	if err != nil {
+		return "", err
+	}
+	logInfo.Printf("context at %s:\n%s", debugRenderAt, string(b))
+	return "", nil
+}
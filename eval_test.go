@@ -0,0 +1,69 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDebugRender(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		wantFile string
+		wantLine int
+		wantErr  bool
+	}{
+		{
+			name:     "Check a file and line are parsed",
+			input:    "./kube/deployment.yaml:12",
+			wantFile: "kube/deployment.yaml",
+			wantLine: 12,
+		},
+		{
+			name:    "Check a missing line is an error",
+			input:   "deployment.yaml",
+			wantErr: true,
+		},
+		{
+			name:    "Check line zero is an error",
+			input:   "deployment.yaml:0",
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			file, line, err := parseDebugRender(c.input)
+			if c.wantErr {
+				if err == nil {
+					t.Errorf("expected an error for %q", c.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if file != c.wantFile || line != c.wantLine {
+				t.Errorf("got: %#v\nwant: %#v\n", []interface{}{file, line}, []interface{}{c.wantFile, c.wantLine})
+			}
+		})
+	}
+}
+
+func TestInsertDebugMarker(t *testing.T) {
+	input := "---\nkind: ConfigMap\ndata:\n  a: {{ .A }}\n"
+	want := "---\nkind: ConfigMap\ndata:\n" + debugContextMarker() + "  a: {{ .A }}\n"
+	got, err := insertDebugMarker([]byte(input), 4)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(string(got), want) {
+		t.Errorf("got: %#v\nwant: %#v\n", string(got), want)
+	}
+	if _, err := insertDebugMarker([]byte(input), 1); err == nil {
+		t.Error("expected an error for a document separator")
+	}
+	if _, err := insertDebugMarker([]byte(input), 10); err == nil {
+		t.Error("expected an error for a line past the end of the file")
+	}
+}
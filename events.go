@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+// dumpEvents logs the warning events for a resource and the objects it created
+func dumpEvents(c *cli.Context, r *ObjectResource) {
+	out, err := runResourceKubeCmd(c, r, "get", "events", "--field-selector", "type=Warning", "--no-headers",
+		"--sort-by", ".lastTimestamp", "-o",
+		"custom-columns=KIND:.involvedObject.kind,NAME:.involvedObject.name,REASON:.reason,MESSAGE:.message")
+	if err != nil {
+		logError.Printf("unable to get the events for %s %q: %s", r.Kind, r.Name, err)
+		return
+	}
+	if events := warningEvents(out, r.Name); len(events) > 0 {
+		logError.Printf("warning events for %s %q:\n  %s", r.Kind, r.Name, strings.Join(events, "\n  "))
+	}
+}
+
+// warningEvents returns the events for an object, or the objects named after it
+// (e.g. the replicasets and pods of a deployment)
+func warningEvents(out, name string) []string {
+	var events []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		if fields[1] != name && !strings.HasPrefix(fields[1], name+"-") {
+			continue
+		}
+		message := strings.Join(fields[3:], " ")
+		events = append(events, strings.ToLower(fields[0])+"/"+fields[1]+" "+fields[2]+": "+message)
+	}
+	return events
+}
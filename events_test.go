@@ -0,0 +1,23 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWarningEvents(t *testing.T) {
+	out := `Pod          api-5d9f7-x2x4z   FailedScheduling   0/3 nodes are available: 3 Insufficient cpu.
+Pod          apiary-1          BackOff            Back-off restarting failed container
+ReplicaSet   api-5d9f7         FailedCreate       Error creating: pods "api-5d9f7-" is forbidden
+Deployment   api               ProgressDeadlineExceeded   ReplicaSet "api-5d9f7" has timed out progressing.
+`
+	want := []string{
+		"pod/api-5d9f7-x2x4z FailedScheduling: 0/3 nodes are available: 3 Insufficient cpu.",
+		"replicaset/api-5d9f7 FailedCreate: Error creating: pods \"api-5d9f7-\" is forbidden",
+		"deployment/api ProgressDeadlineExceeded: ReplicaSet \"api-5d9f7\" has timed out progressing.",
+	}
+	got := warningEvents(out, "api")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+}
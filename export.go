@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// envKeyPattern matches the characters which can't be used in an environment variable name
+var envKeyPattern = regexp.MustCompile(`[^A-Z0-9_]+`)
+
+// exportEnv writes the outputs of a deploy, as dotenv or (for a .json file) json, for
+// later steps of a pipeline
+func exportEnv(c *cli.Context, resources []*ObjectResource) error {
+	outputs := map[string]string{}
+	if c.IsSet("namespace") {
+		outputs["KD_NAMESPACE"] = c.String("namespace")
+	}
+	if c.IsSet(FlagRelease) {
+		outputs["KD_RELEASE"] = c.String(FlagRelease)
+		outputs["KD_RELEASE_REVISION"] = strconv.Itoa(releaseRevision)
+	}
+	for _, r := range resources {
+		var live interface{}
+		if r.Kind == "Service" {
+			out, err := runResourceKubeCmd(c, r, "get", kubectlRef(r), "-o", "yaml")
+			if err != nil {
+				return fmt.Errorf("problem getting the outputs of %s: %s", resourceRef(r), err)
+			}
+			if err := yaml.Unmarshal([]byte(out), &live); err != nil {
+				return err
+			}
+		}
+		doc := live
+		if doc == nil {
+			if err := yaml.Unmarshal(r.Template, &doc); err != nil {
+				return err
+			}
+		}
+		for k, v := range resourceOutputs(r, doc) {
+			outputs[k] = v
+		}
+	}
+	fn := c.String(FlagExportEnv)
+	data, err := formatOutputs(outputs, filepath.Ext(fn) == ".json")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(fn, data, 0644); err != nil {
+		return err
+	}
+	logInfo.Printf("wrote %d outputs to %s", len(outputs), fn)
+	return nil
+}
+
+// resourceOutputs are the outputs of a resource, the names of resources with
+// generated names, the cluster and load balancer addresses of services and the
+// hosts of ingresses
+func resourceOutputs(r *ObjectResource, doc interface{}) map[string]string {
+	outputs := map[string]string{}
+	if len(r.GenerateName) > 0 {
+		outputs[envKey("KD", r.Kind, strings.TrimSuffix(r.GenerateName, "-"), "NAME")] = r.Name
+	}
+	switch r.Kind {
+	case "Service":
+		if ip, ok := lookupPath(doc, "spec", "clusterIP").(string); ok && len(ip) > 0 {
+			outputs[envKey("KD", r.Kind, r.Name, "CLUSTER_IP")] = ip
+		}
+		var addresses []string
+		for _, ingress := range listAt(doc, "status", "loadBalancer", "ingress") {
+			for _, field := range []string{"hostname", "ip"} {
+				if address, ok := lookupPath(ingress, field).(string); ok && len(address) > 0 {
+					addresses = append(addresses, address)
+				}
+			}
+		}
+		if len(addresses) > 0 {
+			outputs[envKey("KD", r.Kind, r.Name, "LOAD_BALANCER")] = strings.Join(addresses, ",")
+		}
+	case "Ingress":
+		var hosts []string
+		for _, rule := range listAt(doc, "spec", "rules") {
+			if host, ok := lookupPath(rule, "host").(string); ok && len(host) > 0 && !contains(hosts, host) {
+				hosts = append(hosts, host)
+			}
+		}
+		if len(hosts) > 0 {
+			outputs[envKey("KD", r.Kind, r.Name, "HOSTS")] = strings.Join(hosts, ",")
+		}
+	}
+	return outputs
+}
+
+// envKey joins the parts of an environment variable name e.g. KD_SERVICE_API_CLUSTER_IP
+func envKey(parts ...string) string {
+	return envKeyPattern.ReplaceAllString(strings.ToUpper(strings.Join(parts, "_")), "_")
+}
+
+// formatOutputs writes the outputs as a json object or dotenv lines, sorted by name
+func formatOutputs(outputs map[string]string, asJSON bool) ([]byte, error) {
+	if asJSON {
+		data, err := json.MarshalIndent(outputs, "", "  ")
+		return append(data, '\n'), err
+	}
+	keys := make([]string, 0, len(outputs))
+	for k := range outputs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var buf bytes.Buffer
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "%s=%q\n", k, outputs[k])
+	}
+	return buf.Bytes(), nil
+}
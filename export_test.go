@@ -0,0 +1,89 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestResourceOutputs(t *testing.T) {
+	cases := []struct {
+		name     string
+		resource *ObjectResource
+		doc      string
+		want     map[string]string
+	}{
+		{
+			name:     "Check the generated name of a job",
+			resource: &ObjectResource{Kind: "Job", ObjectMeta: ObjectMeta{Name: "db-migrate-x7k2p", GenerateName: "db-migrate-"}},
+			doc:      "kind: Job\n",
+			want:     map[string]string{"KD_JOB_DB_MIGRATE_NAME": "db-migrate-x7k2p"},
+		},
+		{
+			name:     "Check the addresses of a service",
+			resource: &ObjectResource{Kind: "Service", ObjectMeta: ObjectMeta{Name: "api"}},
+			doc:      "spec:\n  clusterIP: 10.0.12.7\nstatus:\n  loadBalancer:\n    ingress:\n    - hostname: a1b2.elb.amazonaws.com\n    - ip: 203.0.113.10\n",
+			want: map[string]string{
+				"KD_SERVICE_API_CLUSTER_IP":    "10.0.12.7",
+				"KD_SERVICE_API_LOAD_BALANCER": "a1b2.elb.amazonaws.com,203.0.113.10",
+			},
+		},
+		{
+			name:     "Check a headless service has no cluster ip",
+			resource: &ObjectResource{Kind: "Service", ObjectMeta: ObjectMeta{Name: "db"}},
+			doc:      "spec:\n  clusterIP: \"\"\n",
+			want:     map[string]string{},
+		},
+		{
+			name:     "Check the hosts of an ingress",
+			resource: &ObjectResource{Kind: "Ingress", ObjectMeta: ObjectMeta{Name: "web.public"}},
+			doc:      "spec:\n  rules:\n  - host: example.com\n  - host: www.example.com\n  - host: example.com\n",
+			want:     map[string]string{"KD_INGRESS_WEB_PUBLIC_HOSTS": "example.com,www.example.com"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var doc interface{}
+			if err := yaml.Unmarshal([]byte(c.doc), &doc); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			got := resourceOutputs(c.resource, doc)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
+
+func TestFormatOutputs(t *testing.T) {
+	outputs := map[string]string{"KD_RELEASE_REVISION": "4", "KD_RELEASE": "my app"}
+	cases := []struct {
+		name   string
+		asJSON bool
+		want   string
+	}{
+		{
+			name: "Check dotenv lines are sorted and quoted",
+			want: "KD_RELEASE=\"my app\"\nKD_RELEASE_REVISION=\"4\"\n",
+		},
+		{
+			name:   "Check json",
+			asJSON: true,
+			want:   "{\n  \"KD_RELEASE\": \"my app\",\n  \"KD_RELEASE_REVISION\": \"4\"\n}\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := formatOutputs(outputs, c.asJSON)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if string(got) != c.want {
+				t.Errorf("got: %#v\nwant: %#v\n", string(got), c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// waitForGates waits for each url and tcp address to be available before deploying
+func waitForGates(urls, addrs []string, timeout, interval time.Duration) error {
+	for _, u := range urls {
+		if err := waitFor("url "+u, timeout, interval, func() error { return checkURL(u) }); err != nil {
+			return err
+		}
+	}
+	for _, a := range addrs {
+		if err := waitFor("tcp "+a, timeout, interval, func() error { return checkTCP(a) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitFor retries a check until it succeeds or the timeout is reached
+func waitFor(name string, timeout, interval time.Duration, check func() error) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := check()
+		if err == nil {
+			logInfo.Printf("%s is available", name)
+			return nil
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return fmt.Errorf("%s was not available after %s: %s", name, timeout, err)
+		}
+		logInfo.Printf("waiting for %s: %s", name, err)
+		time.Sleep(interval)
+	}
+}
+
+// checkURL checks a url responds without an error status
+func checkURL(u string) error {
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(u)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
+
+// checkTCP checks a tcp address accepts connections
+func checkTCP(addr string) error {
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWaitForGates(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer healthy.Close()
+	unhealthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unhealthy.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer listener.Close()
+
+	cases := []struct {
+		name    string
+		urls    []string
+		addrs   []string
+		wantErr bool
+	}{
+		{
+			name:  "Check available services pass",
+			urls:  []string{healthy.URL},
+			addrs: []string{listener.Addr().String()},
+		},
+		{
+			name:    "Check an error status fails",
+			urls:    []string{unhealthy.URL},
+			wantErr: true,
+		},
+		{
+			name:    "Check a closed port fails",
+			addrs:   []string{"127.0.0.1:1"},
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := waitForGates(c.urls, c.addrs, 50*time.Millisecond, 10*time.Millisecond)
+			if (err != nil) != c.wantErr {
+				t.Errorf("got: %#v\nwant error: %#v\n", err, c.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gitRepoManifests fetches a ref of a git repo and returns the path of the manifests in it
+func gitRepoManifests(repo, ref, path string) (string, error) {
+	if len(ref) == 0 {
+		ref = "HEAD"
+	}
+	ws, err := workspace()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(ws, "git")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	for _, args := range gitFetchCommands(repo, ref) {
+		var stderr bytes.Buffer
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("problem fetching %s of git repo %s: %s %s", ref, redactURL(repo), err, strings.TrimSpace(stderr.String()))
+		}
+	}
+	logInfo.Printf("fetched %s of git repo %s", ref, redactURL(repo))
+	return gitManifestPath(dir, path)
+}
+
+// gitFetchCommands are the git commands to check out a branch, tag or commit of a repo
+// without its history
+func gitFetchCommands(repo, ref string) [][]string {
+	return [][]string{
+		{"init", "--quiet"},
+		{"remote", "add", "origin", repo},
+		{"fetch", "--quiet", "--depth", "1", "origin", ref},
+		{"checkout", "--quiet", "FETCH_HEAD"},
+	}
+}
+
+// gitManifestPath is the path of the manifests in a checked out repo, which must be
+// inside it
+func gitManifestPath(dir, path string) (string, error) {
+	clean := filepath.Clean(path)
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid %s %q, expecting a path inside the repo", FlagGitPath, path)
+	}
+	return filepath.Join(dir, clean), nil
+}
+
+// redactURL removes any credentials from a repo url so it can be logged
+func redactURL(repo string) string {
+	u, err := url.Parse(repo)
+	if err != nil || u.User == nil {
+		return repo
+	}
+	u.User = nil
+	return u.String()
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// isGlob checks if a file name is a shell style glob pattern
+func isGlob(fn string) bool {
+	return strings.ContainsAny(fn, "*?[")
+}
+
+// expandFileGlob returns the files matching a glob pattern in order, where ** matches
+// any number of directories. Names without a pattern are returned as they are.
+func expandFileGlob(pattern string) ([]string, error) {
+	if !isGlob(pattern) {
+		return []string{pattern}, nil
+	}
+	re, err := globRegexp(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file pattern %q: %s", pattern, err)
+	}
+	var matches []string
+	err = filepath.Walk(globBase(pattern), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && re.MatchString(filepath.ToSlash(path)) {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no files match the pattern %q", pattern)
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// globBase is the directory a glob pattern is under, its leading path without patterns
+func globBase(pattern string) string {
+	var dirs []string
+	for _, part := range strings.Split(filepath.ToSlash(pattern), "/") {
+		if isGlob(part) {
+			break
+		}
+		dirs = append(dirs, part)
+	}
+	base := strings.Join(dirs, "/")
+	if len(base) == 0 {
+		if strings.HasPrefix(pattern, "/") {
+			return "/"
+		}
+		return "."
+	}
+	return filepath.FromSlash(base)
+}
+
+// globRegexp converts a glob pattern to a regular expression matching slash separated
+// paths, * and ? don't match a / but ** does
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	pattern = filepath.ToSlash(filepath.Clean(pattern))
+	var re bytes.Buffer
+	re.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch ch := pattern[i]; ch {
+		case '*':
+			if strings.HasPrefix(pattern[i:], "**/") {
+				re.WriteString("(.*/)?")
+				i += 2
+			} else if strings.HasPrefix(pattern[i:], "**") {
+				re.WriteString(".*")
+				i++
+			} else {
+				re.WriteString("[^/]*")
+			}
+		case '?':
+			re.WriteString("[^/]")
+		case '[':
+			end := strings.Index(pattern[i:], "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated character class")
+			}
+			class := pattern[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + class + "]")
+			i += end
+		default:
+			re.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	re.WriteString("$")
+	return regexp.Compile(re.String())
+}
+
+// isExcluded checks if a path, relative to the directory being listed, matches an
+// exclude pattern. Patterns with a trailing / only match directories, and those
+// without a / match the base name at any depth.
+func isExcluded(rel string, isDir bool, patterns []string) bool {
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "/") {
+			if !isDir {
+				continue
+			}
+			pattern = strings.TrimSuffix(pattern, "/")
+		}
+		name := rel
+		if !strings.Contains(pattern, "/") {
+			name = filepath.Base(rel)
+		}
+		if re, err := globRegexp(pattern); err == nil && re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandFileGlob(t *testing.T) {
+	cases := []struct {
+		pattern string
+		want    []string
+	}{
+		{
+			pattern: "test/TestExpandFileGlob/manifests",
+			want:    []string{"test/TestExpandFileGlob/manifests"},
+		},
+		{
+			pattern: "test/TestExpandFileGlob/manifests/*-prod.yaml",
+			want:    []string{"test/TestExpandFileGlob/manifests/top-prod.yaml"},
+		},
+		{
+			pattern: "test/TestExpandFileGlob/manifests/**/*-prod.yaml",
+			want: []string{
+				"test/TestExpandFileGlob/manifests/api/deployment-prod.yaml",
+				"test/TestExpandFileGlob/manifests/top-prod.yaml",
+				"test/TestExpandFileGlob/manifests/worker/jobs/cron-prod.yaml",
+			},
+		},
+		{
+			pattern: "./test/TestExpandFileGlob/manifests/a?i/*-[!p]*.yaml",
+			want:    []string{"test/TestExpandFileGlob/manifests/api/deployment-dev.yaml"},
+		},
+	}
+	for _, c := range cases {
+		got, err := expandFileGlob(c.pattern)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+		}
+	}
+	if _, err := expandFileGlob("test/TestExpandFileGlob/**/*.json"); err == nil {
+		t.Errorf("expected an error when no files match")
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logHeartbeat writes the heartbeats straight to stdout, they aren't held back with
+// the other messages about a resource by the CI log sections
+var logHeartbeat = log.New(os.Stdout, "[INFO] ", log.Ldate|log.Ltime)
+
+// watching are the resources being watched and when each started
+var watching = struct {
+	sync.Mutex
+	started map[string]time.Time
+}{started: map[string]time.Time{}}
+
+// trackWatch records a resource being watched until the returned func is called
+func trackWatch(r *ObjectResource) func() {
+	ref := resourceRef(r)
+	watching.Lock()
+	watching.started[ref] = time.Now()
+	watching.Unlock()
+	return func() {
+		watching.Lock()
+		delete(watching.started, ref)
+		watching.Unlock()
+	}
+}
+
+// startHeartbeat writes a progress line at an interval, so CI systems which stop
+// jobs without output don't stop a long rollout, until the returned func is called
+func startHeartbeat(interval time.Duration) func() {
+	start := time.Now()
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				watching.Lock()
+				line := heartbeatLine(now.Sub(start), watching.started, now)
+				watching.Unlock()
+				logHeartbeat.Print(line)
+				// Not all CI runners show output until stdout is flushed
+				os.Stdout.Sync()
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+// heartbeatLine describes how long kd has been deploying and the resources it's
+// waiting for
+func heartbeatLine(elapsed time.Duration, started map[string]time.Time, now time.Time) string {
+	line := fmt.Sprintf("still deploying after %s", elapsed.Round(time.Second))
+	if len(started) == 0 {
+		return line
+	}
+	var refs []string
+	for ref := range started {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	var waiting []string
+	for _, ref := range refs {
+		waiting = append(waiting, fmt.Sprintf("%s for %s", ref, now.Sub(started[ref]).Round(time.Second)))
+	}
+	return line + ", waiting for " + strings.Join(waiting, ", ")
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHeartbeatLine(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		elapsed time.Duration
+		started map[string]time.Time
+		want    string
+	}{
+		{
+			elapsed: 30*time.Second + 400*time.Millisecond,
+			want:    "still deploying after 30s",
+		},
+		{
+			elapsed: 4 * time.Minute,
+			started: map[string]time.Time{
+				"StatefulSet/db": now.Add(-3*time.Minute - 10*time.Second),
+				"Deployment/api": now.Add(-20 * time.Second),
+			},
+			want: "still deploying after 4m0s, waiting for Deployment/api for 20s, StatefulSet/db for 3m10s",
+		},
+	}
+	for _, c := range cases {
+		if got := heartbeatLine(c.elapsed, c.started, now); got != c.want {
+			t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+		}
+	}
+}
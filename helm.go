@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+// chartResources renders the chart locally with helm template and returns the
+// resources, helm doesn't track a release of them
+func chartResources(c *cli.Context, conf interface{}) ([]*ObjectResource, error) {
+	chart := c.String(FlagChart)
+	if len(chart) == 0 {
+		return nil, nil
+	}
+	args := helmTemplateArgs(chart, c.String(FlagRelease), c.String("namespace"), c.StringSlice(FlagChartValues))
+	logDebug.Printf("helm arguments: %q", strings.Join(args, " "))
+	var stderr bytes.Buffer
+	cmd := exec.Command("helm", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("problem rendering chart:%q: %s %s", chart, err, strings.TrimSpace(stderr.String()))
+	}
+	return builtResources(c, conf, chart, string(out), false)
+}
+
+// helmTemplateArgs are the arguments to render a chart, named after the release or
+// else the chart
+func helmTemplateArgs(chart, release, namespace string, values []string) []string {
+	name := release
+	if len(name) == 0 {
+		name = strings.TrimSuffix(filepath.Base(strings.TrimRight(chart, "/")), filepath.Ext(chart))
+	}
+	args := []string{"template", name, chart}
+	if len(namespace) > 0 {
+		args = append(args, "--namespace", namespace)
+	}
+	for _, fn := range values {
+		args = append(args, "--values", fn)
+	}
+	return args
+}
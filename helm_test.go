@@ -0,0 +1,38 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHelmTemplateArgs(t *testing.T) {
+	cases := []struct {
+		chart     string
+		release   string
+		namespace string
+		values    []string
+		want      []string
+	}{
+		{
+			chart: "charts/redis/",
+			want:  []string{"template", "redis", "charts/redis/"},
+		},
+		{
+			chart: "ingress-nginx-4.0.1.tgz",
+			want:  []string{"template", "ingress-nginx-4.0.1", "ingress-nginx-4.0.1.tgz"},
+		},
+		{
+			chart:     "bitnami/redis",
+			release:   "cache",
+			namespace: "testing",
+			values:    []string{"values.yaml", "values-prod.yaml"},
+			want: []string{"template", "cache", "bitnami/redis", "--namespace", "testing",
+				"--values", "values.yaml", "--values", "values-prod.yaml"},
+		},
+	}
+	for _, c := range cases {
+		if got := helmTemplateArgs(c.chart, c.release, c.namespace, c.values); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// releaseValues are the Values config data of the current render, recorded for the next release
+var releaseValues interface{}
+
+// releaseRevision is the number of the current release, one more than the previous release
+var releaseRevision = 1
+
+// releaseRecord is the Secret recording the values of the last successful release
+type releaseRecord struct {
+	Data map[string]string `yaml:"data"`
+}
+
+// releaseRecordName is the name of the Secret recording a release
+func releaseRecordName(release string) string {
+	return "kd-release-" + release
+}
+
+// previousRelease returns the values, image tag and revision of the last successful
+// deploy of the release, available to templates as .Previous
+func previousRelease(c *cli.Context) (map[string]interface{}, error) {
+	previous := map[string]interface{}{
+		"Values":   map[interface{}]interface{}{},
+		"ImageTag": "",
+		"Revision": 0,
+	}
+	if !c.IsSet(FlagRelease) || dryRun {
+		return previous, nil
+	}
+	out, err := runKubeCmd(c, "get", "secret", releaseRecordName(c.String(FlagRelease)), "--ignore-not-found", "-o", "yaml")
+	if err != nil {
+		return nil, fmt.Errorf("problem reading the previous release: %s", err)
+	}
+	var record releaseRecord
+	if err := yaml.Unmarshal([]byte(out), &record); err != nil {
+		return nil, err
+	}
+	for key, field := range map[string]string{"values": "Values", "imageTag": "ImageTag", "revision": "Revision"} {
+		data, err := base64.StdEncoding.DecodeString(record.Data[key])
+		if err != nil {
+			return nil, fmt.Errorf("problem reading %s of the previous release: %s", key, err)
+		}
+		if key == "imageTag" {
+			previous[field] = string(data)
+			continue
+		}
+		if key == "revision" {
+			// Releases recorded without a revision are counted as revision 0
+			if revision, err := strconv.Atoi(string(data)); err == nil {
+				previous[field] = revision
+			}
+			continue
+		}
+		var values interface{}
+		if err := yaml.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("problem reading values of the previous release: %s", err)
+		}
+		if values != nil {
+			previous[field] = values
+		}
+	}
+	return previous, nil
+}
+
+// withPrevious adds the previous release to the config data used by templates
+func withPrevious(conf interface{}, previous map[string]interface{}) interface{} {
+	switch m := conf.(type) {
+	case map[string]interface{}:
+		m["Previous"] = previous
+	case map[interface{}]interface{}:
+		m["Previous"] = previous
+	}
+	return conf
+}
+
+// recordRelease saves the values, image tag and revision of a successful deploy of a
+// release, and the manifests deployed so the revision can be rolled back to
+func recordRelease(c *cli.Context, resources []*ObjectResource) error {
+	if err := recordRevision(c, resources); err != nil {
+		return err
+	}
+	manifest, err := releaseRecordManifest(c.String(FlagRelease), releaseValues, imageTag(resources), releaseRevision)
+	if err != nil {
+		return err
+	}
+	r := &ObjectResource{Kind: "Secret", ObjectMeta: ObjectMeta{Name: releaseRecordName(c.String(FlagRelease))}, Template: manifest}
+	if err := deploy(c, r); err != nil {
+		return err
+	}
+	return pruneRevisions(c)
+}
+
+// releaseRevisionName is the name of the Secret recording the manifests of a revision
+func releaseRevisionName(release string, revision int) string {
+	return fmt.Sprintf("%s.v%d", releaseRecordName(release), revision)
+}
+
+// recordRevision saves the manifests deployed for the revision, replacing any left
+// by a deploy of the revision which failed before it was recorded
+func recordRevision(c *cli.Context, resources []*ObjectResource) error {
+	manifest, err := releaseRevisionManifest(c.String(FlagRelease), releaseRevision, releaseValues, resources)
+	if err != nil {
+		return err
+	}
+	name := releaseRevisionName(c.String(FlagRelease), releaseRevision)
+	if _, err := runKubeCmd(c, "delete", "secret", name, "--ignore-not-found"); err != nil {
+		return fmt.Errorf("problem replacing the manifests of revision %d: %s", releaseRevision, err)
+	}
+	// Created rather than applied, as the last applied annotation would double the
+	// size of the manifests and quickly reach the limit of annotations
+	cmd, err := newKubeCmd(c, []string{"create", "-f", "-"}, false)
+	if err != nil {
+		return err
+	}
+	cmd.Stdin = bytes.NewReader(manifest)
+	if _, err := runCmdOutput(cmd); err != nil {
+		return fmt.Errorf("problem recording the manifests of revision %d: %s", releaseRevision, err)
+	}
+	return nil
+}
+
+// releaseRevisionManifest is the Secret recording the values and gzipped manifests
+// of a revision of a release, along with the resources which are only created
+func releaseRevisionManifest(release string, revision int, values interface{}, resources []*ObjectResource) ([]byte, error) {
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	var manifests bytes.Buffer
+	zw := gzip.NewWriter(&manifests)
+	var createOnly []string
+	for _, r := range resources {
+		if _, err := zw.Write([]byte(manifest(r))); err != nil {
+			return nil, err
+		}
+		if r.CreateOnly {
+			createOnly = append(createOnly, resourceRef(r))
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"type":       "Opaque",
+		"metadata": map[string]interface{}{
+			"name": releaseRevisionName(release, revision),
+			// Not labelled with the release, so it isn't pruned as one of its resources
+			"labels": map[string]string{
+				LabelManaged:        "true",
+				LabelReleaseHistory: release,
+				LabelRevision:       strconv.Itoa(revision),
+			},
+		},
+		"data": map[string]string{
+			"manifests": base64.StdEncoding.EncodeToString(manifests.Bytes()),
+		},
+		"stringData": map[string]string{
+			"values":     string(data),
+			"createOnly": strings.Join(createOnly, "\n"),
+		},
+	})
+}
+
+// releaseRevisionData is a revision read back from its Secret
+type releaseRevisionData struct {
+	values    interface{}
+	resources []*ObjectResource
+}
+
+// readRevision reads the values and manifests recorded for a revision of a release
+func readRevision(c *cli.Context, revision int) (*releaseRevisionData, error) {
+	name := releaseRevisionName(c.String(FlagRelease), revision)
+	out, err := runKubeCmd(c, "get", "secret", name, "--ignore-not-found", "-o", "yaml")
+	if err != nil {
+		return nil, fmt.Errorf("problem reading revision %d: %s", revision, err)
+	}
+	if len(strings.TrimSpace(out)) == 0 {
+		return nil, fmt.Errorf("the manifests of revision %d of %q aren't recorded, it is older than the --%s or was deployed before manifests were recorded",
+			revision, c.String(FlagRelease), FlagHistoryLimit)
+	}
+	var record releaseRecord
+	if err := yaml.Unmarshal([]byte(out), &record); err != nil {
+		return nil, err
+	}
+	return decodeRevision(record, fmt.Sprintf("revision %d", revision))
+}
+
+// decodeRevision decodes the values and resources of a revision's Secret, the
+// resources are from the file named source
+func decodeRevision(record releaseRecord, source string) (*releaseRevisionData, error) {
+	decoded := map[string][]byte{}
+	for _, key := range []string{"manifests", "values", "createOnly"} {
+		data, err := base64.StdEncoding.DecodeString(record.Data[key])
+		if err != nil {
+			return nil, fmt.Errorf("problem reading %s of %s: %s", key, source, err)
+		}
+		decoded[key] = data
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(decoded["manifests"]))
+	if err != nil {
+		return nil, fmt.Errorf("problem reading the manifests of %s: %s", source, err)
+	}
+	manifests, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("problem reading the manifests of %s: %s", source, err)
+	}
+	revision := &releaseRevisionData{}
+	if err := yaml.Unmarshal(decoded["values"], &revision.values); err != nil {
+		return nil, fmt.Errorf("problem reading values of %s: %s", source, err)
+	}
+	createOnly := strings.Split(string(decoded["createOnly"]), "\n")
+	for _, doc := range splitYamlDocs(string(manifests)) {
+		r := &ObjectResource{FileName: source, Template: []byte(doc)}
+		if err := yaml.Unmarshal(r.Template, r); err != nil {
+			return nil, fmt.Errorf("problem reading the manifests of %s: %s", source, err)
+		}
+		r.CreateOnly = contains(createOnly, resourceRef(r))
+		revision.resources = append(revision.resources, r)
+	}
+	return revision, nil
+}
+
+// pruneRevisions deletes the manifests of the revisions older than the --history-limit
+func pruneRevisions(c *cli.Context) error {
+	if c.Int(FlagHistoryLimit) <= 0 {
+		return nil
+	}
+	out, err := runKubeCmd(c, "get", "secrets", "-l", LabelReleaseHistory+"="+c.String(FlagRelease), "--no-headers",
+		"-o", "custom-columns=NAME:.metadata.name,REVISION:.metadata.labels."+jsonPathKey(LabelRevision))
+	if err != nil {
+		return fmt.Errorf("problem listing the revisions of %s: %s", c.String(FlagRelease), err)
+	}
+	for _, name := range expiredRevisions(out, releaseRevision, c.Int(FlagHistoryLimit)) {
+		logDebug.Printf("deleting the manifests of %s, older than the --%s", name, FlagHistoryLimit)
+		if _, err := runKubeCmd(c, "delete", "secret", name, "--ignore-not-found"); err != nil {
+			return fmt.Errorf("problem deleting the manifests of %s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// expiredRevisions returns the names of the revisions (listed as NAME REVISION)
+// which are older than the most recent limit revisions
+func expiredRevisions(out string, current, limit int) []string {
+	var expired []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if revision, err := strconv.Atoi(fields[1]); err == nil && revision <= current-limit {
+			expired = append(expired, fields[0])
+		}
+	}
+	sort.Strings(expired)
+	return expired
+}
+
+// releaseRecordManifest is the Secret recording the values, image tag and revision of a release
+func releaseRecordManifest(release string, values interface{}, tag string, revision int) ([]byte, error) {
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"type":       "Opaque",
+		"metadata": map[string]interface{}{
+			"name": releaseRecordName(release),
+			"labels": map[string]string{
+				LabelManaged: "true",
+				LabelRelease: release,
+			},
+		},
+		"stringData": map[string]string{
+			"values":   string(data),
+			"imageTag": tag,
+			"revision": strconv.Itoa(revision),
+		},
+	})
+}
+
+// imageTag is the tag of the first container image of the first workload
+func imageTag(resources []*ObjectResource) string {
+	for _, r := range resources {
+		var doc interface{}
+		if err := yaml.Unmarshal(r.Template, &doc); err != nil {
+			continue
+		}
+		for _, ctr := range listAt(doc, podTemplatePath(r.Kind, "spec", "containers")...) {
+			image, _ := lookupPath(ctr, "image").(string)
+			if len(image) == 0 {
+				continue
+			}
+			if _, tag, _ := splitImage(image); len(tag) > 0 {
+				return tag
+			}
+			return "latest"
+		}
+	}
+	return ""
+}
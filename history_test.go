@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestImageTag(t *testing.T) {
+	workload := func(kind, image string) *ObjectResource {
+		return &ObjectResource{Kind: kind, Template: []byte("kind: " + kind + "\nspec:\n  template:\n    spec:\n      containers:\n      - image: " + image + "\n")}
+	}
+	cases := []struct {
+		name      string
+		resources []*ObjectResource
+		want      string
+	}{
+		{
+			name:      "Check the tag of the first workload is used",
+			resources: []*ObjectResource{{Kind: "ConfigMap", Template: []byte("kind: ConfigMap\n")}, workload("Deployment", "quay.io/myapp:v1.2.0"), workload("Deployment", "quay.io/other:v9")},
+			want:      "v1.2.0",
+		},
+		{
+			name:      "Check a registry port and digest are ignored",
+			resources: []*ObjectResource{workload("StatefulSet", "registry:5000/myapp:v2@sha256:abc")},
+			want:      "v2",
+		},
+		{
+			name:      "Check an image without a tag is latest",
+			resources: []*ObjectResource{workload("Deployment", "registry:5000/myapp")},
+			want:      "latest",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := imageTag(c.resources); got != c.want {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
+
+func TestReleaseRecordManifest(t *testing.T) {
+	values := map[interface{}]interface{}{"colour": "blue"}
+	manifest, err := releaseRecordManifest("myapp", values, "v1.2.0", 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var got struct {
+		Kind       string            `yaml:"kind"`
+		Metadata   ObjectMeta        `yaml:"metadata"`
+		StringData map[string]string `yaml:"stringData"`
+	}
+	if err := yaml.Unmarshal(manifest, &got); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := map[string]string{"values": "colour: blue\n", "imageTag": "v1.2.0", "revision": "3"}
+	if got.Kind != "Secret" || got.Metadata.Name != "kd-release-myapp" || !reflect.DeepEqual(got.StringData, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got.StringData, want)
+	}
+}
+
+func TestWithPrevious(t *testing.T) {
+	previous := map[string]interface{}{"ImageTag": "v1"}
+	got := withPrevious(map[string]interface{}{"Values": "x"}, previous)
+	want := map[string]interface{}{"Values": "x", "Previous": previous}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+}
+
+func TestReleaseRevision(t *testing.T) {
+	resources := []*ObjectResource{
+		{Kind: "Secret", ObjectMeta: ObjectMeta{Name: "password"}, CreateOnly: true,
+			Template: []byte("apiVersion: v1\nkind: Secret\nmetadata:\n  name: password\n")},
+		{Kind: "Deployment", ObjectMeta: ObjectMeta{Name: "api"},
+			Template: []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: api\n")},
+	}
+	manifest, err := releaseRevisionManifest("myapp", 3, map[interface{}]interface{}{"colour": "blue"}, resources)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var secret struct {
+		Metadata   ObjectMeta        `yaml:"metadata"`
+		Data       map[string]string `yaml:"data"`
+		StringData map[string]string `yaml:"stringData"`
+	}
+	if err := yaml.Unmarshal(manifest, &secret); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if secret.Metadata.Name != "kd-release-myapp.v3" {
+		t.Errorf("got: %#v\nwant: %#v\n", secret.Metadata.Name, "kd-release-myapp.v3")
+	}
+	// The cluster stores the string data encoded with the data
+	record := releaseRecord{Data: secret.Data}
+	for key, value := range secret.StringData {
+		record.Data[key] = base64.StdEncoding.EncodeToString([]byte(value))
+	}
+	got, err := decodeRevision(record, "revision 3")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := map[interface{}]interface{}{"colour": "blue"}; !reflect.DeepEqual(got.values, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got.values, want)
+	}
+	if len(got.resources) != len(resources) {
+		t.Fatalf("got: %d resources\nwant: %d\n", len(got.resources), len(resources))
+	}
+	for i, r := range got.resources {
+		want := resources[i]
+		if r.Kind != want.Kind || r.Name != want.Name || r.CreateOnly != want.CreateOnly || string(r.Template) != string(want.Template) {
+			t.Errorf("got: %#v\nwant: %#v\n", r, want)
+		}
+	}
+}
+
+func TestExpiredRevisions(t *testing.T) {
+	out := "kd-release-myapp.v1   1\nkd-release-myapp.v2   2\nkd-release-myapp.v3   3\nkd-release-myapp.v4   4\n"
+	cases := []struct {
+		name  string
+		limit int
+		want  []string
+	}{
+		{name: "Check revisions older than the limit expire", limit: 2, want: []string{"kd-release-myapp.v1", "kd-release-myapp.v2"}},
+		{name: "Check revisions within the limit are kept", limit: 4},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := expiredRevisions(out, 4, c.limit); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
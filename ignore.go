@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ignoreRule is a field left out of diffs, optionally only for a kind
+type ignoreRule struct {
+	kind     string
+	segments []fieldSegment
+}
+
+// fieldSegment is a map key, or a list item selected by name, in a field path. Both
+// may contain * to match any characters.
+type fieldSegment struct {
+	pattern *regexp.Regexp
+	item    bool
+}
+
+// parseIgnoreRules parses the fields to ignore, [Kind:]path where the path is the
+// dotted field path of a semantic diff e.g. Deployment:spec.replicas,
+// metadata.annotations["cert-manager.io/*"] or spec.template.spec.containers[istio-proxy]
+func parseIgnoreRules(rules []string) ([]ignoreRule, error) {
+	var parsed []ignoreRule
+	for _, rule := range rules {
+		r := ignoreRule{}
+		path := rule
+		if i := strings.Index(rule, ":"); i > 0 && !strings.ContainsAny(rule[:i], ".[") {
+			r.kind, path = rule[:i], rule[i+1:]
+		}
+		segments, err := parseFieldPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %s", FlagDiffIgnore, rule, err)
+		}
+		r.segments = segments
+		parsed = append(parsed, r)
+	}
+	return parsed, nil
+}
+
+// parseFieldPath splits a field path into its keys and list items
+func parseFieldPath(path string) ([]fieldSegment, error) {
+	var segments []fieldSegment
+	add := func(s string, item bool) {
+		pattern := "^" + strings.Replace(regexp.QuoteMeta(s), `\*`, ".*", -1) + "$"
+		segments = append(segments, fieldSegment{pattern: regexp.MustCompile(pattern), item: item})
+	}
+	for len(path) > 0 {
+		switch {
+		case strings.HasPrefix(path, `["`):
+			end := strings.Index(path, `"]`)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated key in %s", path)
+			}
+			add(path[2:end], false)
+			path = path[end+2:]
+		case strings.HasPrefix(path, "["):
+			end := strings.Index(path, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated list item in %s", path)
+			}
+			add(path[1:end], true)
+			path = path[end+1:]
+		case strings.HasPrefix(path, "."):
+			path = path[1:]
+		default:
+			end := strings.IndexAny(path, ".[")
+			if end < 0 {
+				end = len(path)
+			}
+			add(path[:end], false)
+			path = path[end:]
+		}
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("no fields")
+	}
+	return segments, nil
+}
+
+// ignoreFields removes the fields matching the rules for a kind from an object
+func ignoreFields(doc interface{}, kind string, rules []ignoreRule) interface{} {
+	for _, rule := range rules {
+		if len(rule.kind) > 0 && !strings.EqualFold(rule.kind, kind) {
+			continue
+		}
+		doc = removeFields(doc, rule.segments)
+	}
+	return doc
+}
+
+// removeFields removes the keys or list items at a field path, returning the value
+func removeFields(value interface{}, segments []fieldSegment) interface{} {
+	s := segments[0]
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		if s.item {
+			return v
+		}
+		for k, child := range v {
+			if !s.pattern.MatchString(fmt.Sprint(k)) {
+				continue
+			}
+			if len(segments) == 1 {
+				delete(v, k)
+			} else {
+				v[k] = removeFields(child, segments[1:])
+			}
+		}
+		return v
+	case []interface{}:
+		if !s.item {
+			return v
+		}
+		var kept []interface{}
+		for _, child := range v {
+			name, _ := lookupPath(child, "name").(string)
+			if !s.pattern.MatchString(name) {
+				kept = append(kept, child)
+			} else if len(segments) > 1 {
+				kept = append(kept, removeFields(child, segments[1:]))
+			}
+		}
+		return kept
+	}
+	return value
+}
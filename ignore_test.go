@@ -0,0 +1,134 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestIgnoreFields(t *testing.T) {
+	doc := `
+metadata:
+  labels:
+    app: api
+    security.istio.io/tlsMode: istio
+  annotations:
+    cert-manager.io/issuer: letsencrypt
+    cert-manager.io/common-name: api.example.com
+    owner: payments
+spec:
+  replicas: 5
+  template:
+    spec:
+      containers:
+        - name: api
+          image: api:v2
+        - name: istio-proxy
+          image: proxyv2:1.20
+`
+	want := `
+metadata:
+  labels:
+    app: api
+  annotations:
+    owner: payments
+spec:
+  template:
+    spec:
+      containers:
+        - name: api
+`
+	cases := []struct {
+		name  string
+		kind  string
+		rules []string
+		want  string
+	}{
+		{
+			name: "Check fields, quoted keys, globs and list items are removed",
+			kind: "Deployment",
+			rules: []string{
+				"Deployment:spec.replicas",
+				`metadata.annotations["cert-manager.io/*"]`,
+				`metadata.labels["security.istio.io/tlsMode"]`,
+				"spec.template.spec.containers[istio-proxy]",
+				"spec.template.spec.containers[*].image",
+			},
+			want: want,
+		},
+		{
+			name:  "Check rules for another kind are skipped",
+			kind:  "StatefulSet",
+			rules: []string{"deployment:spec.replicas"},
+			want:  doc,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rules, err := parseIgnoreRules(c.rules)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			var got, want interface{}
+			if err := yaml.Unmarshal([]byte(doc), &got); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if err := yaml.Unmarshal([]byte(c.want), &want); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			got = ignoreFields(got, c.kind, rules)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got: %#v\nwant: %#v\n", got, want)
+			}
+		})
+	}
+}
+
+func TestParseIgnoreRules(t *testing.T) {
+	cases := []struct {
+		name    string
+		rule    string
+		kind    string
+		fields  int
+		wantErr bool
+	}{
+		{
+			name:   "Check a kind prefix",
+			rule:   "HorizontalPodAutoscaler:spec.maxReplicas",
+			kind:   "HorizontalPodAutoscaler",
+			fields: 2,
+		},
+		{
+			name:   "Check a colon in a quoted key isn't a kind",
+			rule:   `metadata.annotations["example.com/a:b"]`,
+			fields: 3,
+		},
+		{
+			name:    "Check an unterminated key is an error",
+			rule:    `metadata.annotations["cert-manager.io/issuer`,
+			wantErr: true,
+		},
+		{
+			name:    "Check an empty path is an error",
+			rule:    "Deployment:",
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rules, err := parseIgnoreRules([]string{c.rule})
+			if (err != nil) != c.wantErr {
+				t.Fatalf("got: %#v\nwant error: %#v\n", err, c.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if rules[0].kind != c.kind || len(rules[0].segments) != c.fields {
+				t.Errorf("got: %q with %d fields\nwant: %q with %d fields\n", rules[0].kind, len(rules[0].segments), c.kind, c.fields)
+			}
+		})
+	}
+}
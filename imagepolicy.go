@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+// imagePolicies are the values of --image-policy
+var imagePolicies = []string{"none", "immutable-tags", "digest"}
+
+// DefaultMutableTags matches the tags which are commonly moved to new images
+const DefaultMutableTags = "^(latest|master|main|develop|dev|staging|stable|head|HEAD)$"
+
+// validateImagePolicy rejects images which don't meet the image policy when
+// deploying to a protected context
+func validateImagePolicy(c *cli.Context, resources []*ObjectResource) error {
+	policy := c.String(FlagImagePolicy)
+	if !contains(imagePolicies, policy) {
+		return fmt.Errorf("invalid %s %q, expecting one of %s", FlagImagePolicy, policy, strings.Join(imagePolicies, ", "))
+	}
+	if policy == "none" {
+		return nil
+	}
+	mutable, err := regexp.Compile(c.String(FlagMutableTags))
+	if err != nil {
+		return fmt.Errorf("invalid %s: %s", FlagMutableTags, err)
+	}
+	if protected := c.StringSlice(FlagProtectedContexts); len(protected) > 0 {
+		context := c.String("context")
+		if len(context) == 0 {
+			out, err := runKubeCmd(c, "config", "current-context")
+			if err != nil {
+				return fmt.Errorf("unable to find the context to check the image policy: %s", err)
+			}
+			context = strings.TrimSpace(out)
+		}
+		if !contains(splitList(protected), context) {
+			logDebug.Printf("context %s isn't protected, skipping the image policy", context)
+			return nil
+		}
+	}
+	images, err := workloadImages(resources)
+	if err != nil {
+		return err
+	}
+	if problems := imagePolicyProblems(images, policy, mutable); len(problems) > 0 {
+		return fmt.Errorf("images don't meet the %s image policy:\n  %s", policy, strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// imagePolicyProblems describes each image which doesn't meet a policy
+func imagePolicyProblems(images []string, policy string, mutable *regexp.Regexp) []string {
+	var problems []string
+	for _, image := range images {
+		_, tag, digest := splitImage(image)
+		switch {
+		case len(digest) > 0:
+			continue
+		case policy == "digest":
+			problems = append(problems, fmt.Sprintf("%s isn't referenced by digest", image))
+		case len(tag) == 0:
+			problems = append(problems, fmt.Sprintf("%s has no tag (so uses latest)", image))
+		case mutable.MatchString(tag):
+			problems = append(problems, fmt.Sprintf("%s uses the mutable tag %s", image, tag))
+		}
+	}
+	return problems
+}
+
+// splitImage splits an image into its repository, tag and digest (either may be empty)
+func splitImage(image string) (repository, tag, digest string) {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image, digest = image[:i], image[i+1:]
+	}
+	// A colon before the last slash is a registry port
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i], image[i+1:], digest
+	}
+	return image, "", digest
+}
+
+// splitList splits comma separated values given to a string slice flag
+func splitList(values []string) []string {
+	var list []string
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); len(item) > 0 {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestSplitImage(t *testing.T) {
+	cases := []struct {
+		input      string
+		wantRepo   string
+		wantTag    string
+		wantDigest string
+	}{
+		{input: "nginx:1.11", wantRepo: "nginx", wantTag: "1.11"},
+		{input: "localhost:5000/nginx", wantRepo: "localhost:5000/nginx"},
+		{input: "nginx@sha256:abc", wantRepo: "nginx", wantDigest: "sha256:abc"},
+		{input: "localhost:5000/nginx:1.11@sha256:abc", wantRepo: "localhost:5000/nginx", wantTag: "1.11", wantDigest: "sha256:abc"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			repo, tag, digest := splitImage(c.input)
+			got := []string{repo, tag, digest}
+			want := []string{c.wantRepo, c.wantTag, c.wantDigest}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got: %#v\nwant: %#v\n", got, want)
+			}
+		})
+	}
+}
+
+func TestImagePolicyProblems(t *testing.T) {
+	images := []string{"quay.io/app:v1.2.0", "quay.io/app:latest", "quay.io/worker", "quay.io/cron:main", "quay.io/proxy@sha256:abc"}
+	mutable := regexp.MustCompile(DefaultMutableTags)
+	cases := []struct {
+		policy string
+		want   []string
+	}{
+		{
+			policy: "immutable-tags",
+			want: []string{
+				"quay.io/app:latest uses the mutable tag latest",
+				"quay.io/worker has no tag (so uses latest)",
+				"quay.io/cron:main uses the mutable tag main",
+			},
+		},
+		{
+			policy: "digest",
+			want: []string{
+				"quay.io/app:v1.2.0 isn't referenced by digest",
+				"quay.io/app:latest isn't referenced by digest",
+				"quay.io/worker isn't referenced by digest",
+				"quay.io/cron:main isn't referenced by digest",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.policy, func(t *testing.T) {
+			got := imagePolicyProblems(images, c.policy, mutable)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
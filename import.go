@@ -0,0 +1,209 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var (
+	// serverMetadataFields are populated by the api server and shouldn't be in manifests
+	serverMetadataFields = []string{
+		"creationTimestamp", "generation", "managedFields", "namespace",
+		"resourceVersion", "selfLink", "uid",
+	}
+	// serverAnnotations are added by kubectl or controllers and shouldn't be in manifests
+	serverAnnotations = []string{
+		"kubectl.kubernetes.io/last-applied-configuration",
+		"deployment.kubernetes.io/revision",
+	}
+	// defaultedSpecFields are the workload spec fields with the api server default values
+	defaultedSpecFields = map[string]string{
+		"progressDeadlineSeconds": "600",
+		"revisionHistoryLimit":    "10",
+	}
+	// defaultedPodFields are the pod spec fields with the api server default values
+	defaultedPodFields = map[string]string{
+		"dnsPolicy":                     "ClusterFirst",
+		"restartPolicy":                 "Always",
+		"schedulerName":                 "default-scheduler",
+		"terminationGracePeriodSeconds": "30",
+	}
+	// defaultedContainerFields are the container fields with the api server default values
+	defaultedContainerFields = map[string]string{
+		"terminationMessagePath":   "/dev/termination-log",
+		"terminationMessagePolicy": "File",
+	}
+	// nonVariableChars are the characters not allowed in a suggested template variable
+	nonVariableChars = regexp.MustCompile(`[^A-Z0-9_]`)
+)
+
+// importResources will export live resources as kd compatible manifests
+func importResources(c *cli.Context) error {
+	if c.Bool("debug") {
+		logDebug = logDebugIf
+	}
+	if c.NArg() == 0 {
+		return errors.New("no resources specified to import, expecting kind/name")
+	}
+	dir := c.String(FlagOutputDir)
+	if len(dir) == 0 {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, resString := range c.Args() {
+		resParts := strings.Split(resString, "/")
+		if len(resParts) != 2 {
+			return fmt.Errorf(
+				"invalid resource type %s, expecting kind/name", resString)
+		}
+		live, err := runKubeCmd(c, "get", resString, "-o", "yaml")
+		if err != nil {
+			return err
+		}
+		manifest, vars, err := cleanManifest([]byte(live))
+		if err != nil {
+			return fmt.Errorf("problem cleaning %s: %s", resString, err)
+		}
+		fn := filepath.Join(dir, strings.ToLower(resParts[0])+"-"+resParts[1]+".yaml")
+		if err := ioutil.WriteFile(fn, append([]byte("---\n"), manifest...), 0644); err != nil {
+			return err
+		}
+		logInfo.Printf("imported %s to %s", resString, fn)
+
+		names := make([]string, 0, len(vars))
+		for v := range vars {
+			names = append(names, v)
+		}
+		sort.Strings(names)
+		for _, v := range names {
+			logInfo.Printf("suggested template variable %s=%s", v, vars[v])
+		}
+	}
+	return nil
+}
+
+// cleanManifest strips server populated fields from a live object and replaces
+// the image tags and replicas with template variables, returning their values
+func cleanManifest(data []byte) ([]byte, map[string]string, error) {
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, err
+	}
+	doc = mapSliceDelete(doc, "status")
+
+	meta := getMapSlice(doc, "metadata")
+	for _, f := range serverMetadataFields {
+		meta = mapSliceDelete(meta, f)
+	}
+	annotations := getMapSlice(meta, "annotations")
+	for _, a := range serverAnnotations {
+		annotations = mapSliceDelete(annotations, a)
+	}
+	if len(annotations) == 0 {
+		meta = mapSliceDelete(meta, "annotations")
+	} else {
+		meta = mapSliceSet(meta, "annotations", annotations)
+	}
+	doc = mapSliceSet(doc, "metadata", meta)
+	name, _ := mapSliceGet(meta, "name")
+
+	vars := map[string]string{}
+	spec := getMapSlice(doc, "spec")
+	if len(spec) == 0 {
+		return marshalManifest(doc, vars)
+	}
+	if kind, _ := mapSliceGet(doc, "kind"); kind == "Service" {
+		spec = mapSliceDelete(spec, "clusterIP")
+		spec = mapSliceDelete(spec, "clusterIPs")
+	}
+	spec = removeDefaults(spec, defaultedSpecFields)
+	if replicas, ok := mapSliceGet(spec, "replicas"); ok {
+		v := variableName(fmt.Sprint(name), "REPLICAS")
+		vars[v] = fmt.Sprint(replicas)
+		spec = mapSliceSet(spec, "replicas", placeholder(v))
+	}
+
+	if tmpl := getMapSlice(spec, "template"); len(tmpl) > 0 {
+		tmplMeta := mapSliceDelete(getMapSlice(tmpl, "metadata"), "creationTimestamp")
+		tmpl = mapSliceSet(tmpl, "metadata", tmplMeta)
+		podSpec := removeDefaults(getMapSlice(tmpl, "spec"), defaultedPodFields)
+		for _, field := range []string{"initContainers", "containers"} {
+			list, _ := mapSliceGet(podSpec, field)
+			containers, _ := list.([]interface{})
+			for i, item := range containers {
+				ctr, _ := item.(yaml.MapSlice)
+				ctr = removeDefaults(ctr, defaultedContainerFields)
+				ctrName, _ := mapSliceGet(ctr, "name")
+				image, _ := mapSliceGet(ctr, "image")
+				// Images pinned to a digest are left as they are
+				if repo, tag, digest := splitImage(fmt.Sprint(image)); len(tag) > 0 && len(digest) == 0 {
+					v := variableName(fmt.Sprint(ctrName), "IMAGE_TAG")
+					vars[v] = tag
+					ctr = mapSliceSet(ctr, "image", repo+":"+placeholder(v))
+				}
+				containers[i] = ctr
+			}
+		}
+		tmpl = mapSliceSet(tmpl, "spec", podSpec)
+		spec = mapSliceSet(spec, "template", tmpl)
+	}
+	doc = mapSliceSet(doc, "spec", spec)
+	return marshalManifest(doc, vars)
+}
+
+// marshalManifest writes out a manifest replacing placeholders with template variables
+func marshalManifest(doc yaml.MapSlice, vars map[string]string) ([]byte, map[string]string, error) {
+	b, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	manifest := string(b)
+	for v := range vars {
+		manifest = strings.Replace(manifest, placeholder(v), "{{ ."+v+" }}", -1)
+	}
+	return []byte(manifest), vars, nil
+}
+
+// getMapSlice returns the ordered yaml map for a key (empty if missing)
+func getMapSlice(m yaml.MapSlice, key string) yaml.MapSlice {
+	v, _ := mapSliceGet(m, key)
+	ms, _ := v.(yaml.MapSlice)
+	return ms
+}
+
+// removeDefaults removes fields set to their default values and any empty maps
+func removeDefaults(m yaml.MapSlice, defaults map[string]string) yaml.MapSlice {
+	clean := yaml.MapSlice{}
+	for _, item := range m {
+		k, _ := item.Key.(string)
+		if d, ok := defaults[k]; ok && fmt.Sprint(item.Value) == d {
+			continue
+		}
+		if ms, ok := item.Value.(yaml.MapSlice); ok && len(ms) == 0 {
+			continue
+		}
+		clean = append(clean, item)
+	}
+	return clean
+}
+
+// variableName suggests a template variable name e.g. MY_APP_IMAGE_TAG
+func variableName(prefix, suffix string) string {
+	return nonVariableChars.ReplaceAllString(strings.ToUpper(prefix), "_") + "_" + suffix
+}
+
+// placeholder is a marker for a template variable which is safe to marshal as yaml
+func placeholder(v string) string {
+	return "KD_PLACEHOLDER_" + v
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCleanManifest(t *testing.T) {
+	got, vars, err := cleanManifest([]byte(readfile("test/TestImport/live-deployment.yaml")))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := readfile("test/TestImport/clean-deployment.yaml")
+	if string(got) != want {
+		t.Errorf("got: %#v\nwant: %#v\n", string(got), want)
+	}
+	wantVars := map[string]string{"NGINX_REPLICAS": "3", "NGINX_IMAGE_TAG": "1.11-alpine"}
+	if !reflect.DeepEqual(vars, wantVars) {
+		t.Errorf("got: %#v\nwant: %#v\n", vars, wantVars)
+	}
+}
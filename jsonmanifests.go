@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// isJSONManifest checks if a file is a json manifest rather than yaml
+func isJSONManifest(fn string) bool {
+	return strings.ToLower(filepath.Ext(fn)) == ".json"
+}
+
+// jsonManifestKeys match the keys every json manifest (or list of them) has
+var jsonManifestKeys = []*regexp.Regexp{
+	regexp.MustCompile(`"apiVersion"\s*:`),
+	regexp.MustCompile(`"kind"\s*:`),
+}
+
+// hasManifestKeys checks if a json file has an apiVersion and kind, the file is a
+// template so it isn't parsed. A file which can't be read is left to fail later.
+func hasManifestKeys(path string) bool {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return true
+	}
+	for _, key := range jsonManifestKeys {
+		if !key.Match(data) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitJSONList returns the resources of a rendered json manifest, the items of a
+// List (as written by kubectl get -o json) or the manifest itself
+func splitJSONList(rendered string) ([]string, error) {
+	dec := json.NewDecoder(strings.NewReader(rendered))
+	dec.UseNumber()
+	var doc map[string]interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("expecting a single json object, use a List for several resources")
+	}
+	if doc["kind"] != "List" {
+		return []string{rendered}, nil
+	}
+	items, ok := doc["items"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expecting the items of the List to be a list of resources")
+	}
+	var docs []string
+	for _, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, string(data))
+	}
+	return docs, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestSplitJSONList(t *testing.T) {
+	list, err := ioutil.ReadFile("test/TestSplitJSONList/list.json")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	single := "{\n\t\"apiVersion\": \"v1\",\n\t\"kind\": \"Service\",\n\t\"metadata\": {\"name\": \"api\"}\n}\n"
+	cases := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "Check a resource is kept as it is",
+			input: single,
+			want:  []string{single},
+		},
+		{
+			name:  "Check the items of a list are split",
+			input: string(list),
+			want: []string{
+				`{"apiVersion":"v1","data":{"replicas":"3"},"kind":"ConfigMap","metadata":{"name":"settings"}}`,
+				`{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"api"},"spec":{"replicas":3}}`,
+			},
+		},
+		{
+			name:    "Check several objects are an error",
+			input:   single + single,
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := splitJSONList(c.input)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("got: %#v\nwant error: %#v\n", err, c.wantErr)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+			// The resources are read as yaml, which json is a subset of
+			for _, doc := range got {
+				if err := checkStrictYaml([]byte(doc)); err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				var r ObjectResource
+				if err := yaml.Unmarshal([]byte(doc), &r); err != nil || len(r.Kind) == 0 || len(r.Name) == 0 {
+					t.Errorf("got: %#v (%v)\nwant: a kind and name\n", r, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// isJsonnet checks if a file is rendered with jsonnet rather than templated
+func isJsonnet(fn string) bool {
+	return filepath.Ext(fn) == ".jsonnet"
+}
+
+// evaluateJsonnet evaluates a jsonnet file with the jsonnet binary, each environment
+// variable is an external variable e.g. std.extVar('IMAGE_TAG')
+func evaluateJsonnet(fn string, env map[string]string) (string, error) {
+	args := jsonnetArgs(fn, env)
+	logDebug.Printf("jsonnet arguments: %q", strings.Join(args, " "))
+	var stderr bytes.Buffer
+	cmd := exec.Command("jsonnet", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("problem evaluating file:%q with jsonnet: %s %s", fn, err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// jsonnetArgs are the arguments to evaluate a file, the values of the external
+// variables are read by jsonnet from the environment so they aren't on the command line
+func jsonnetArgs(fn string, env map[string]string) []string {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var args []string
+	for _, name := range names {
+		args = append(args, "--ext-str", name)
+	}
+	return append(args, "--jpathdir", filepath.Dir(fn), fn)
+}
+
+// jsonnetResources returns the resources a jsonnet file evaluated to, a resource,
+// a List, an array of resources or an object of named resources (in name order)
+func jsonnetResources(out string) ([]string, error) {
+	dec := json.NewDecoder(strings.NewReader(out))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	var items []interface{}
+	collectResources(doc, &items)
+	var docs []string
+	for _, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, string(data))
+	}
+	return docs, nil
+}
+
+// collectResources adds the resources in a jsonnet value to a list
+func collectResources(value interface{}, items *[]interface{}) {
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			collectResources(item, items)
+		}
+	case map[string]interface{}:
+		if v["kind"] == "List" {
+			collectResources(v["items"], items)
+			return
+		}
+		if _, found := v["kind"]; found {
+			*items = append(*items, v)
+			return
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			collectResources(v[name], items)
+		}
+	}
+}
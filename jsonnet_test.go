@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJsonnetArgs(t *testing.T) {
+	got := jsonnetArgs("kube/app.jsonnet", map[string]string{"IMAGE_TAG": "v1", "ENV": "prod"})
+	want := []string{"--ext-str", "ENV", "--ext-str", "IMAGE_TAG", "--jpathdir", "kube", "kube/app.jsonnet"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+}
+
+func TestJsonnetResources(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "Check a single resource",
+			input: `{"kind": "Service", "metadata": {"name": "api"}}`,
+			want:  []string{`{"kind":"Service","metadata":{"name":"api"}}`},
+		},
+		{
+			name:  "Check an array and a list of resources",
+			input: `[{"kind": "Service", "metadata": {"name": "api"}}, {"kind": "List", "items": [{"kind": "ConfigMap", "metadata": {"name": "a"}}]}]`,
+			want:  []string{`{"kind":"Service","metadata":{"name":"api"}}`, `{"kind":"ConfigMap","metadata":{"name":"a"}}`},
+		},
+		{
+			name:  "Check an object of named resources is in name order",
+			input: `{"service": {"kind": "Service", "spec": {"port": 80}}, "deployment": {"kind": "Deployment", "spec": {"replicas": 3}}}`,
+			want:  []string{`{"kind":"Deployment","spec":{"replicas":3}}`, `{"kind":"Service","spec":{"port":80}}`},
+		},
+		{
+			name:    "Check invalid json is an error",
+			input:   `{"kind": `,
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := jsonnetResources(c.input)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("got: %#v\nwant error: %#v\n", err, c.wantErr)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
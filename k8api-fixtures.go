@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// K8ApiFixtures is an API runner which returns the statuses recorded for each
+// resource in turn, used to simulate watching resources without a cluster
+type K8ApiFixtures struct {
+	K8Api
+	// dir has a file of yaml documents for each resource named kind-name.yaml
+	dir string
+	// interval is the time each recorded status represents
+	interval time.Duration
+	// timeout is how long a resource would be watched for
+	timeout func(*ObjectResource) (time.Duration, error)
+
+	lock     sync.Mutex
+	statuses map[string][]string
+	calls    map[string]int
+}
+
+// NewK8ApiFixtures creates a K8Api implementation returning the statuses recorded in a directory
+func NewK8ApiFixtures(dir string, interval time.Duration, timeout func(*ObjectResource) (time.Duration, error)) K8Api {
+	return &K8ApiFixtures{
+		dir:      dir,
+		interval: interval,
+		timeout:  timeout,
+		statuses: map[string][]string{},
+		calls:    map[string]int{},
+	}
+}
+
+// Lookup isn't supported when simulating
+func (a *K8ApiFixtures) Lookup(ctx context.Context, kind, name, path string) (string, error) {
+	return "", fmt.Errorf("k8lookup of %s/%s isn't supported when simulating", kind, name)
+}
+
+// Exists checks if statuses are recorded for a resource
+func (a *K8ApiFixtures) Exists(r *ObjectResource) (bool, error) {
+	statuses, err := a.recorded(r)
+	return len(statuses) > 0, err
+}
+
+// UpdateStatus sets the next status recorded for a resource, failing once the
+// statuses used would take longer than the timeout or there are none left
+func (a *K8ApiFixtures) UpdateStatus(r *ObjectResource) error {
+	statuses, err := a.recorded(r)
+	if err != nil {
+		return err
+	}
+	limit, err := a.timeout(r)
+	if err != nil {
+		return err
+	}
+	a.lock.Lock()
+	n := a.calls[fixtureFileName(r)]
+	a.calls[fixtureFileName(r)]++
+	a.lock.Unlock()
+
+	elapsed := time.Duration(n) * a.interval
+	if elapsed > limit {
+		return fmt.Errorf("%s %q would time out after %s, at recorded status %d", r.Kind, r.Name, limit, n+1)
+	}
+	if n >= len(statuses) {
+		return fmt.Errorf("%s %q isn't complete after the %d recorded statuses (%s)", r.Kind, r.Name, len(statuses), elapsed)
+	}
+	return yaml.Unmarshal([]byte(statuses[n]), r)
+}
+
+// recorded returns the statuses recorded for a resource, none if there is no file
+func (a *K8ApiFixtures) recorded(r *ObjectResource) ([]string, error) {
+	fn := fixtureFileName(r)
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if statuses, found := a.statuses[fn]; found {
+		return statuses, nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(a.dir, fn))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	statuses := splitYamlDocs(string(data))
+	a.statuses[fn] = statuses
+	return statuses, nil
+}
+
+// fixtureFileName is the file the statuses of a resource are recorded in
+func fixtureFileName(r *ObjectResource) string {
+	return strings.ToLower(r.Kind) + "-" + r.Name + ".yaml"
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestK8ApiFixtures(t *testing.T) {
+	timeout := func(*ObjectResource) (time.Duration, error) { return 3 * time.Second, nil }
+	api := NewK8ApiFixtures("test/TestK8ApiFixtures", time.Second, timeout)
+
+	r := &ObjectResource{Kind: "Deployment", ObjectMeta: ObjectMeta{Name: "api"}}
+	if found, err := api.Exists(r); !found || err != nil {
+		t.Fatalf("expected statuses to be recorded, got: %v %v", found, err)
+	}
+	var got []DeploymentStatus
+	for i := 0; i < 2; i++ {
+		r.DeploymentStatus = DeploymentStatus{}
+		if err := api.UpdateStatus(r); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		got = append(got, r.DeploymentStatus)
+	}
+	want := []DeploymentStatus{
+		{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 1, AvailableReplicas: 1, UnavailableReplicas: 1},
+		{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+	if err := api.UpdateStatus(r); err == nil || !strings.Contains(err.Error(), "after the 2 recorded statuses") {
+		t.Errorf("expected an error once the statuses are used, got: %v", err)
+	}
+
+	slow := NewK8ApiFixtures("test/TestK8ApiFixtures", 2*time.Second, timeout)
+	for i := 0; i < 2; i++ {
+		if err := slow.UpdateStatus(r); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if err := slow.UpdateStatus(r); err == nil || !strings.Contains(err.Error(), "would time out after 3s") {
+		t.Errorf("expected a timeout, got: %v", err)
+	}
+
+	missing := &ObjectResource{Kind: "Deployment", ObjectMeta: ObjectMeta{Name: "web"}}
+	if found, err := api.Exists(missing); found || err != nil {
+		t.Errorf("expected no statuses to be recorded, got: %v %v", found, err)
+	}
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"strings"
 
 	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
 )
 
 // K8ApiKubectl is a kubectl implimentation of K8Api interface
@@ -23,7 +26,7 @@ func NewK8ApiKubectl(c *cli.Context) K8Api {
 }
 
 // Lookup will get data from a specified kubernetes object
-func (a K8ApiKubectl) Lookup(kind, name, path string) (string, error) {
+func (a K8ApiKubectl) Lookup(ctx context.Context, kind, name, path string) (string, error) {
 	args := []string{"get", kind + "/" + name, "-o", "custom-columns=:" + path, "--no-headers"}
 
 	cmd, err := newKubeCmd(a.Cx, args, false)
@@ -36,8 +39,20 @@ func (a K8ApiKubectl) Lookup(kind, name, path string) (string, error) {
 		logDebug.Printf("error starting kubectl: %s", err)
 		return "", err
 	}
+	// Lookups are made while rendering so must finish by the render deadline
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		if ctx.Err() == context.DeadlineExceeded {
+			cmd.Process.Kill()
+		}
+	}()
 	data, _ := ioutil.ReadAll(stdout)
 	if err := cmd.Wait(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("k8lookup of %s/%s path %s stalled, render timeout reached", kind, name, path)
+		}
 		logDebug.Printf("error with kubectl: %s", err)
 		errData, _ := ioutil.ReadAll(stderr)
 		if strings.Contains("NotFound", string(errData[:])) {
@@ -47,3 +62,70 @@ func (a K8ApiKubectl) Lookup(kind, name, path string) (string, error) {
 	}
 	return strings.TrimSpace(string(data[:])), nil
 }
+
+// UpdateStatus will refresh the status of a resource from kubernetes
+func (a K8ApiKubectl) UpdateStatus(r *ObjectResource) error {
+	args := []string{"get", kubectlRef(r), "-o", "yaml"}
+	cmd, err := newResourceKubeCmd(a.Cx, r, args, false)
+	if err != nil {
+		return err
+	}
+	cmd.Stderr = os.Stderr
+	stdout, _ := cmd.StdoutPipe()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	data, _ := ioutil.ReadAll(stdout)
+	if err := yaml.Unmarshal(data, r); err != nil {
+		return err
+	}
+	if err := cmd.Wait(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Exists checks if a resource exists in kubernetes
+func (a K8ApiKubectl) Exists(r *ObjectResource) (bool, error) {
+	args := []string{"get", kubectlRef(r), "-o", "custom-columns=:.metadata.name", "--no-headers"}
+
+	cmd, err := newResourceKubeCmd(a.Cx, r, args, false)
+	if err != nil {
+		return false, err
+	}
+	stderr, _ := cmd.StderrPipe()
+	stdout, _ := cmd.StdoutPipe()
+	if err := cmd.Start(); err != nil {
+		logDebug.Printf("error starting kubectl: %s", err)
+		return false, err
+	}
+	data, _ := ioutil.ReadAll(stdout)
+	if err := cmd.Wait(); err != nil {
+		logDebug.Printf(
+			"error with kubectl: %s. kubectl arguments: %q",
+			err,
+			strings.Join(cmd.Args, " "))
+		errData, _ := ioutil.ReadAll(stderr)
+		if strings.Contains("NotFound", string(errData[:])) {
+			return false, nil
+		}
+		return false, err
+	}
+	if strings.TrimSpace(string(data[:])) == r.Name {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// kubectlRef is the kind/name of a resource for kubectl, qualified with the api
+// version and group (kind.version.group/name) so custom resources sharing a kind
+// with another group aren't ambiguous
+func kubectlRef(r *ObjectResource) string {
+	parts := strings.SplitN(r.APIVersion, "/", 2)
+	if len(parts) != 2 {
+		// Core resources have no group and are never ambiguous
+		return r.Kind + "/" + r.Name
+	}
+	return r.Kind + "." + parts[1] + "." + parts[0] + "/" + r.Name
+}
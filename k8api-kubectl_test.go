@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestKubectlRef(t *testing.T) {
+	cases := []struct {
+		apiVersion string
+		kind       string
+		want       string
+	}{
+		{"", "Job", "Job/app"},
+		{"v1", "Service", "Service/app"},
+		{"apps/v1", "Deployment", "Deployment.v1.apps/app"},
+		{"cert-manager.io/v1", "Certificate", "Certificate.v1.cert-manager.io/app"},
+		{"networking.gke.io/v1beta1", "Certificate", "Certificate.v1beta1.networking.gke.io/app"},
+	}
+	for _, c := range cases {
+		r := &ObjectResource{APIVersion: c.apiVersion, Kind: c.kind, ObjectMeta: ObjectMeta{Name: "app"}}
+		if got := kubectlRef(r); got != c.want {
+			t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package main
 
+import "context"
+
 // K8ApiNoop is a noop API runner used when not connected to a server
 type K8ApiNoop struct {
 	K8Api
@@ -11,6 +13,16 @@ func NewK8ApiNoop() K8Api {
 }
 
 // Lookup will pretentd to get data from a specified kubernetes object
-func (a K8ApiNoop) Lookup(kind, name, path string) (string, error) {
+func (a K8ApiNoop) Lookup(ctx context.Context, kind, name, path string) (string, error) {
 	return "noop", nil
 }
+
+// Exists will pretend a resource doesn't exist
+func (a K8ApiNoop) Exists(r *ObjectResource) (bool, error) {
+	return false, nil
+}
+
+// UpdateStatus will pretend to refresh the status of a resource
+func (a K8ApiNoop) UpdateStatus(r *ObjectResource) error {
+	return nil
+}
@@ -1,7 +1,14 @@
 package main
 
+import "context"
+
 // K8Api is an abstraction to allow the migration to the real API not kubectl
 type K8Api interface {
-	// Lookup abstract interface for finding kuberneets api data by kind, name and path
-	Lookup(kind, name, path string) (string, error)
+	// Lookup abstract interface for finding kuberneets api data by kind, name and path,
+	// giving up when the context is done
+	Lookup(ctx context.Context, kind, name, path string) (string, error)
+	// Exists checks if a resource exists
+	Exists(r *ObjectResource) (bool, error)
+	// UpdateStatus refreshes the status of a resource
+	UpdateStatus(r *ObjectResource) error
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+// kustomizeResources builds each kustomization directory and returns the resources,
+// rendered as templates when asked
+func kustomizeResources(c *cli.Context, conf interface{}) ([]*ObjectResource, error) {
+	var resources []*ObjectResource
+	if len(c.StringSlice(FlagKustomize)) > 0 {
+		if err := requireKubectl(c, minKustomizeMinor, "--"+FlagKustomize); err != nil {
+			return nil, err
+		}
+	}
+	for _, dir := range c.StringSlice(FlagKustomize) {
+		out, err := kustomizeBuild(dir)
+		if err != nil {
+			return nil, err
+		}
+		built, err := builtResources(c, conf, dir, out, c.Bool(FlagKustomizeRender))
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, built...)
+	}
+	return resources, nil
+}
+
+// builtResources are the resources in the manifests built by a tool (such as
+// kustomize or helm) from a source, rendered as templates when asked
+func builtResources(c *cli.Context, conf interface{}, source, out string, render bool) ([]*ObjectResource, error) {
+	var resources []*ObjectResource
+	for i, d := range splitYamlDocs(out) {
+		if len(strings.TrimSpace(d)) == 0 {
+			continue
+		}
+		genSecret := false
+		if render {
+			var k8api K8Api = NewK8ApiNoop()
+			if !dryRun {
+				k8api = NewK8ApiKubectl(c)
+			}
+			templateFile = source
+			var err error
+			if d, genSecret, err = Render(k8api, d, conf); err != nil {
+				return nil, err
+			}
+		}
+		if err := checkStrictYaml([]byte(d)); err != nil {
+			return nil, fmt.Errorf("invalid yaml in document %d built from %q: %s", i+1, source, err)
+		}
+		resources = append(resources, &ObjectResource{
+			FileName:   source,
+			Template:   []byte(d),
+			CreateOnly: genSecret,
+		})
+	}
+	return resources, nil
+}
+
+// kustomizeBuild builds a kustomization with kubectl's built in kustomize, which
+// doesn't use the cluster
+func kustomizeBuild(dir string) (string, error) {
+	logDebug.Printf("building kustomization %s", dir)
+	var stderr bytes.Buffer
+	cmd := exec.Command("kubectl", "kustomize", dir)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("problem building kustomization:%q: %s %s", dir, err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli"
+)
+
+// fakeKustomize is a kubectl which builds a kustomization of a ConfigMap and a
+// Deployment, failing for any other directory
+const fakeKustomize = `#!/bin/sh
+if [ "$1" != "kustomize" ]; then
+  exit 1
+fi
+case "$2" in
+*/base)
+  echo "apiVersion: v1"
+  echo "kind: ConfigMap"
+  echo "metadata:"
+  echo '  name: "{{ .APP }}-config"'
+  echo "---"
+  echo "apiVersion: apps/v1"
+  echo "kind: Deployment"
+  echo "metadata:"
+  echo '  name: "{{ .APP }}"'
+  ;;
+*)
+  echo "error: unable to find one of 'kustomization.yaml' in directory '$2'" >&2
+  exit 1 ;;
+esac
+`
+
+func TestKustomizeResources(t *testing.T) {
+	defer withFakeKubectl(t, fakeKustomize)()
+	defer func(d bool) { dryRun = d }(dryRun)
+	dryRun = true
+
+	cases := []struct {
+		name    string
+		dir     string
+		render  bool
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "Check the built resources are deployed as they are",
+			dir:  "overlays/base",
+			want: []string{
+				"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: \"{{ .APP }}-config\"\n",
+				"apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: \"{{ .APP }}\"\n",
+			},
+		},
+		{
+			name:   "Check the built resources are rendered when asked",
+			dir:    "overlays/base",
+			render: true,
+			want: []string{
+				"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: \"api-config\"\n",
+				"apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: \"api\"\n",
+			},
+		},
+		{
+			name:    "Check kustomize errors are returned",
+			dir:     "overlays/missing",
+			wantErr: "unable to find one of 'kustomization.yaml'",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			set := flag.NewFlagSet("kd", flag.ContinueOnError)
+			set.Var(&cli.StringSlice{tc.dir}, FlagKustomize, "")
+			set.Bool(FlagKustomizeRender, tc.render, "")
+			c := cli.NewContext(nil, set, nil)
+
+			resources, err := kustomizeResources(c, map[string]interface{}{"APP": "api"})
+			if len(tc.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("got: %#v\nwant: %#v\n", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			var got []string
+			for _, r := range resources {
+				got = append(got, string(r.Template))
+				if r.FileName != tc.dir {
+					t.Errorf("got: %#v\nwant: %#v\n", r.FileName, tc.dir)
+				}
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got: %#v\nwant: %#v\n", got, tc.want)
+			}
+		})
+	}
+}
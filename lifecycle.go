@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// DefaultTerminationGracePeriod is the grace period of pods which don't set one
+const DefaultTerminationGracePeriod = 30
+
+// sleepPattern finds the seconds a preStop exec hook sleeps for
+var sleepPattern = regexp.MustCompile(`(?:^|\s)sleep\s+(\d+)(?:s|\s|$)`)
+
+// validateProbes warns about probe and lifecycle settings which cause failed watches
+// or dropped traffic during a rollout
+func validateProbes(c *cli.Context, resources []*ObjectResource) error {
+	problems, err := probeProblems(resources, c.Duration("timeout"), c.Duration(FlagHookTimeout))
+	if err != nil {
+		return err
+	}
+	for _, problem := range problems {
+		logWarn.Print(problem)
+	}
+	return nil
+}
+
+// probeProblems describes the probe and lifecycle problems of each workload
+func probeProblems(resources []*ObjectResource, timeout, hookTimeout time.Duration) ([]string, error) {
+	var problems []string
+	for _, r := range resources {
+		if !contains(rolloutKinds, r.Kind) {
+			continue
+		}
+		// Compared with the timeout before it allows for the probe delays
+		limit, err := baseTimeout(r, timeout, hookTimeout)
+		if err != nil {
+			return nil, err
+		}
+		var doc map[interface{}]interface{}
+		if err := yaml.Unmarshal(r.Template, &doc); err != nil {
+			return nil, err
+		}
+		podSpec := lookupPath(doc, podTemplatePath(r.Kind, "spec")...)
+		grace := DefaultTerminationGracePeriod
+		if v, ok := lookupPath(podSpec, "terminationGracePeriodSeconds").(int); ok {
+			grace = v
+		}
+		source := fmt.Sprintf("%s/%s (from file:%q)", r.Kind, r.Name, r.FileName)
+		if grace == 0 {
+			problems = append(problems, fmt.Sprintf("%s has a terminationGracePeriodSeconds of 0, pods are killed before they are removed from the service endpoints", source))
+		}
+		for _, ctr := range listAt(podSpec, "containers") {
+			name, _ := lookupPath(ctr, "name").(string)
+			readiness := lookupPath(ctr, "readinessProbe")
+			if readiness == nil {
+				problems = append(problems, fmt.Sprintf("%s container %s has no readinessProbe, kd considers it ready (and it gets traffic) as soon as it starts", source, name))
+			} else if delay, ok := lookupPath(readiness, "initialDelaySeconds").(int); ok && time.Duration(delay)*time.Second >= limit {
+				problems = append(problems, fmt.Sprintf("%s container %s readinessProbe initialDelaySeconds of %ds isn't less than kd's timeout of %s", source, name, delay, limit))
+			}
+			if sleep, ok := preStopSleep(lookupPath(ctr, "lifecycle", "preStop", "exec", "command")); ok && sleep >= grace && grace > 0 {
+				problems = append(problems, fmt.Sprintf("%s container %s preStop sleeps for %ds, not less than the terminationGracePeriodSeconds of %d, so it is killed before it can shut down", source, name, sleep, grace))
+			}
+		}
+	}
+	return problems, nil
+}
+
+// preStopSleep returns the seconds a preStop exec command sleeps for, e.g. ["sleep", "10"]
+// or ["/bin/sh", "-c", "sleep 10 && nginx -s quit"]
+func preStopSleep(command interface{}) (int, bool) {
+	list, ok := command.([]interface{})
+	if !ok {
+		return 0, false
+	}
+	var args []string
+	for _, arg := range list {
+		args = append(args, fmt.Sprint(arg))
+	}
+	m := sleepPattern.FindStringSubmatch(strings.Join(args, " "))
+	if m == nil {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(m[1])
+	return seconds, err == nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestProbeProblems(t *testing.T) {
+	fn := "test/TestProbeProblems/workloads.yaml"
+	workloads, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var resources []*ObjectResource
+	for _, d := range splitYamlDocs(string(workloads)) {
+		r := &ObjectResource{FileName: fn, Template: []byte(d)}
+		if err := yaml.Unmarshal(r.Template, r); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		resources = append(resources, r)
+	}
+
+	got, err := probeProblems(resources, 3*time.Minute, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []string{
+		`Deployment/api (from file:"test/TestProbeProblems/workloads.yaml") container proxy readinessProbe initialDelaySeconds of 300s isn't less than kd's timeout of 3m0s`,
+		`Deployment/api (from file:"test/TestProbeProblems/workloads.yaml") container proxy preStop sleeps for 30s, not less than the terminationGracePeriodSeconds of 20, so it is killed before it can shut down`,
+		`StatefulSet/db (from file:"test/TestProbeProblems/workloads.yaml") has a terminationGracePeriodSeconds of 0, pods are killed before they are removed from the service endpoints`,
+		`StatefulSet/db (from file:"test/TestProbeProblems/workloads.yaml") container db has no readinessProbe, kd considers it ready (and it gets traffic) as soon as it starts`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// resourcePattern finds the kind/name of a resource in a log message
+var resourcePattern = regexp.MustCompile(`(?:^|\s)([a-z]+)(?:\.[a-z0-9.-]+)?/([a-z0-9][-a-z0-9.]*)`)
+
+// jsonLogWriter writes each log message as a structured json record
+type jsonLogWriter struct {
+	level string
+	out   io.Writer
+}
+
+// logRecord is a structured log message
+type logRecord struct {
+	Level   string `json:"level"`
+	Time    string `json:"time"`
+	Kind    string `json:"kind,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Phase   string `json:"phase,omitempty"`
+	Message string `json:"message"`
+}
+
+// setLogFormat switches the loggers to the text or json format
+func setLogFormat(format string) error {
+	switch format {
+	case "", "text":
+		return nil
+	case "json":
+		debugEnabled := logDebug == logDebugIf
+		logInfo = log.New(&jsonLogWriter{level: "info", out: logOutput.writer(os.Stdout)}, "", 0)
+		logWarn = log.New(&jsonLogWriter{level: "warn", out: logOutput.writer(os.Stderr)}, "", 0)
+		logError = log.New(&jsonLogWriter{level: "error", out: logOutput.writer(os.Stderr)}, "", 0)
+		logDebugIf = log.New(&jsonLogWriter{level: "debug", out: logOutput.writer(os.Stderr)}, "", 0)
+		logHeartbeat = log.New(&jsonLogWriter{level: "info", out: os.Stdout}, "", 0)
+		if debugEnabled {
+			logDebug = logDebugIf
+		}
+		logSummary = logInfo
+		return nil
+	}
+	return fmt.Errorf("invalid %s %q, expecting text or json", FlagLogFormat, format)
+}
+
+// setLogLevel discards the log messages less severe than the level, quiet only
+// keeps errors and the summary
+func setLogLevel(level string, quiet bool) error {
+	if quiet {
+		level = "error"
+	}
+	discard := log.New(ioutil.Discard, "", 0)
+	switch level {
+	case "error":
+		logWarn = discard
+		fallthrough
+	case "warn":
+		logInfo = discard
+		fallthrough
+	case "info":
+		logDebug = discard
+	case "debug":
+		logDebug = logDebugIf
+	default:
+		return fmt.Errorf("invalid %s %q, expecting error, warn, info or debug", FlagLogLevel, level)
+	}
+	return nil
+}
+
+// Write formats a log message as a json record
+func (w *jsonLogWriter) Write(p []byte) (int, error) {
+	rec := logRecord{
+		Level:   w.level,
+		Time:    time.Now().UTC().Format(time.RFC3339),
+		Message: strings.TrimSpace(string(p)),
+	}
+	if m := resourcePattern.FindStringSubmatch(rec.Message); m != nil {
+		rec.Kind, rec.Name = m[1], m[2]
+	}
+	// Messages about a resource start with what is happening e.g. deploying
+	if words := strings.Fields(rec.Message); len(words) > 0 && strings.HasSuffix(words[0], "ing") {
+		rec.Phase = strings.ToLower(words[0])
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := w.out.Write(append(b, '\n')); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
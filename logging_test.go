@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestJSONLogWriter(t *testing.T) {
+	cases := []struct {
+		name    string
+		message string
+		want    logRecord
+	}{
+		{
+			name:    "Check a resource and phase are recorded",
+			message: "deploying deployment/api-server\n",
+			want:    logRecord{Level: "info", Kind: "deployment", Name: "api-server", Phase: "deploying", Message: "deploying deployment/api-server"},
+		},
+		{
+			name:    "Check other messages are recorded",
+			message: "Loaded config data from ./config/values.yaml\n",
+			want:    logRecord{Level: "info", Message: "Loaded config data from ./config/values.yaml"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := &jsonLogWriter{level: "info", out: &buf}
+			if _, err := w.Write([]byte(c.message)); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			var got logRecord
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(got.Time) == 0 {
+				t.Error("expected a time to be recorded")
+			}
+			got.Time = ""
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSetLogLevel(t *testing.T) {
+	info, warn, debug := logInfo, logWarn, logDebug
+	defer func() { logInfo, logWarn, logDebug = info, warn, debug }()
+
+	if err := setLogLevel("warn", false); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if logInfo == info || logWarn != warn {
+		t.Error("expected only info messages to be discarded at warn level")
+	}
+	if err := setLogLevel("info", true); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if logWarn == warn {
+		t.Error("expected warnings to be discarded when quiet")
+	}
+	if err := setLogLevel("verbose", false); err == nil {
+		t.Error("expected an error for an invalid level")
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// quotedResourcePattern finds a resource named as Kind "name" in a log message
+var quotedResourcePattern = regexp.MustCompile(`(?:^|\s)([A-Z][A-Za-z]+) "([a-z0-9][-a-z0-9.]*)"`)
+
+// logOutput serializes the log messages, keeping together the messages about each
+// resource deployed or watched in parallel
+var logOutput = &logGroups{}
+
+// logGroups tracks the resources being deployed or watched in parallel and, for
+// the CI log section styles, their messages until they are done
+type logGroups struct {
+	lock   sync.Mutex
+	style  string
+	active map[string][]logEntry
+}
+
+// logEntry is a log message waiting to be written to a stream
+type logEntry struct {
+	out io.Writer
+	p   []byte
+}
+
+// groupedWriter is a log stream written through the log groups
+type groupedWriter struct {
+	groups *logGroups
+	out    io.Writer
+}
+
+// setLogGroups sets how the messages about resources in parallel are kept together,
+// prefixed with the resource or collapsible github or gitlab CI log sections
+func setLogGroups(style string) error {
+	switch style {
+	case "", "prefix", "github", "gitlab":
+		logOutput.style = style
+		return nil
+	}
+	return fmt.Errorf("invalid %s %q, expecting prefix, github or gitlab", FlagLogGroups, style)
+}
+
+// writer returns a log stream written through the groups
+func (g *logGroups) writer(out io.Writer) io.Writer {
+	return &groupedWriter{groups: g, out: out}
+}
+
+// Write writes a log message, or keeps it until its resource is done
+func (w *groupedWriter) Write(p []byte) (int, error) {
+	g := w.groups
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	ref := messageResource(string(p))
+	if entries, found := g.active[ref]; found && len(ref) > 0 {
+		if g.style == "github" || g.style == "gitlab" {
+			g.active[ref] = append(entries, logEntry{out: w.out, p: append([]byte{}, p...)})
+			return len(p), nil
+		}
+		return len(p), writeAll(w.out, prefixLines("["+ref+"] ", p))
+	}
+	return len(p), writeAll(w.out, p)
+}
+
+// start groups the messages of resources deployed or watched in parallel
+func (g *logGroups) start(resources []*ObjectResource) {
+	if len(resources) < 2 {
+		return
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.active = map[string][]logEntry{}
+	for _, r := range resources {
+		g.active[resourceRef(r)] = nil
+	}
+}
+
+// end writes the messages kept for a resource which is done
+func (g *logGroups) end(r *ObjectResource) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	ref := resourceRef(r)
+	entries, found := g.active[ref]
+	if !found {
+		return
+	}
+	g.active[ref] = nil
+	if len(entries) == 0 {
+		return
+	}
+	header, footer := sectionMarkers(g.style, ref, time.Now())
+	writeAll(entries[0].out, []byte(header))
+	for _, e := range entries {
+		writeAll(e.out, e.p)
+	}
+	writeAll(entries[0].out, []byte(footer))
+}
+
+// stop writes any messages still kept and stops grouping
+func (g *logGroups) stop(resources []*ObjectResource) {
+	for _, r := range resources {
+		g.end(r)
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.active = nil
+}
+
+// messageResource is the kind/name of the resource a log message is about
+func messageResource(msg string) string {
+	if m := resourcePattern.FindStringSubmatch(msg); m != nil {
+		return m[1] + "/" + m[2]
+	}
+	if m := quotedResourcePattern.FindStringSubmatch(msg); m != nil {
+		return strings.ToLower(m[1]) + "/" + m[2]
+	}
+	return ""
+}
+
+// sectionMarkers are the lines around a collapsible CI log section
+func sectionMarkers(style, name string, now time.Time) (string, string) {
+	switch style {
+	case "github":
+		return "::group::" + name + "\n", "::endgroup::\n"
+	case "gitlab":
+		id := strings.NewReplacer("/", "_", ".", "_").Replace(name)
+		return fmt.Sprintf("\x1b[0Ksection_start:%d:%s[collapsed=true]\r\x1b[0K%s\n", now.Unix(), id, name),
+			fmt.Sprintf("\x1b[0Ksection_end:%d:%s\r\x1b[0K\n", now.Unix(), id)
+	}
+	return "", ""
+}
+
+// prefixLines adds a prefix to each line of a message
+func prefixLines(prefix string, p []byte) []byte {
+	var buf bytes.Buffer
+	for _, line := range strings.SplitAfter(string(p), "\n") {
+		if len(line) > 0 {
+			buf.WriteString(prefix + line)
+		}
+	}
+	return buf.Bytes()
+}
+
+// writeAll writes a message, ignoring the count as log messages are written whole
+func writeAll(out io.Writer, p []byte) error {
+	_, err := out.Write(p)
+	return err
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestMessageResource(t *testing.T) {
+	cases := map[string]string{
+		"deploying deployment/api":                                    "deployment/api",
+		"deployment.apps/api configured":                              "deployment/api",
+		`Deployment "api" update in progress. Waiting for 1 objects.`: "deployment/api",
+		"Loaded config data from ./config/values.yaml":                "",
+	}
+	for msg, want := range cases {
+		if got := messageResource(msg); got != want {
+			t.Errorf("got: %#v\nwant: %#v\n", got, want)
+		}
+	}
+}
+
+func TestLogGroups(t *testing.T) {
+	api := &ObjectResource{Kind: "Deployment", ObjectMeta: ObjectMeta{Name: "api"}}
+	web := &ObjectResource{Kind: "Deployment", ObjectMeta: ObjectMeta{Name: "web"}}
+	cases := []struct {
+		style string
+		want  string
+	}{
+		{
+			style: "prefix",
+			want: "[deployment/api] deploying deployment/api\n[deployment/web] deploying deployment/web\n" +
+				"watching 2 resources\n[deployment/web] Deployment \"web\" is complete\n[deployment/api] Deployment \"api\" is complete\n",
+		},
+		{
+			style: "github",
+			want: "watching 2 resources\n::group::deployment/web\ndeploying deployment/web\nDeployment \"web\" is complete\n::endgroup::\n" +
+				"::group::deployment/api\ndeploying deployment/api\nDeployment \"api\" is complete\n::endgroup::\n",
+		},
+	}
+	for _, c := range cases {
+		var buf bytes.Buffer
+		groups := &logGroups{}
+		logger := log.New(groups.writer(&buf), "", 0)
+		groups.style = c.style
+		groups.start([]*ObjectResource{api, web})
+		logger.Print("deploying deployment/api")
+		logger.Print("deploying deployment/web")
+		logger.Print("watching 2 resources")
+		logger.Print(`Deployment "web" is complete`)
+		groups.end(web)
+		logger.Print(`Deployment "api" is complete`)
+		groups.stop([]*ObjectResource{api, web})
+		if got := buf.String(); got != c.want {
+			t.Errorf("%s\ngot: %#v\nwant: %#v\n", c.style, got, c.want)
+		}
+	}
+}
+
+func TestSectionMarkers(t *testing.T) {
+	header, footer := sectionMarkers("gitlab", "deployment/api", time.Unix(1546344000, 0))
+	if want := "\x1b[0Ksection_start:1546344000:deployment_api[collapsed=true]\r\x1b[0Kdeployment/api\n"; header != want {
+		t.Errorf("got: %#v\nwant: %#v\n", header, want)
+	}
+	if want := "\x1b[0Ksection_end:1546344000:deployment_api\r\x1b[0K\n"; footer != want {
+		t.Errorf("got: %#v\nwant: %#v\n", footer, want)
+	}
+}
@@ -12,21 +12,22 @@ import (
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/cavaliercoder/grab"
-	"github.com/joho/godotenv"
 	"github.com/urfave/cli"
 	yaml "gopkg.in/yaml.v2"
 )
 
 const (
-	// DeployDelaySeconds - delay between deployments
+	// DeployDelaySeconds - default delay between deployments
 	DeployDelaySeconds = 3
-	// MaxHealthcheckRetries - Amount of times to retry checking of resource after deployment
+	// MaxHealthcheckRetries - default amount of times to retry checking of resource after deployment
 	MaxHealthcheckRetries = 3
-	// HealthCheckSleepDuration - the amount of time to sleep (seconds) between healthcehck retries
+	// HealthCheckSleepDuration - the default amount of time to sleep (seconds) before the first healthcheck retry
 	HealthCheckSleepDuration = time.Duration(int64(2)) * time.Second
 	// FlagConfigData for specifying config namespaced data
 	FlagConfigData = "config-data"
@@ -42,6 +43,20 @@ const (
 	// FlagCaFile is the sytax to specify a CA file when FlagCa specifies a URL or
 	// when FlagCaData is set
 	FlagCaFile = "certificate-authority-file"
+	// FlagKubeConfig specifies the path to a kubeconfig file
+	FlagKubeConfig = "kubeconfig"
+	// FlagFileOrder deploys resources in the order of the files rather than by kind
+	FlagFileOrder = "file-order"
+	// FlagConcurrency is the number of workloads to deploy and watch in parallel
+	FlagConcurrency = "concurrency"
+	// FlagReproducible makes rendered output byte for byte the same between runs
+	FlagReproducible = "reproducible"
+	// FlagKubeExecCommand specifies a client-go credential plugin to authenticate with
+	FlagKubeExecCommand = "kube-exec-command"
+	// FlagKubeExecArgs are the arguments for the credential plugin
+	FlagKubeExecArgs = "kube-exec-args"
+	// FlagKubeExecAPIVersion is the api version of the credential plugin
+	FlagKubeExecAPIVersion = "kube-exec-api-version"
 	// FlagKubeConfigData allows an entire kubeconfig to be specified by flag or environment
 	FlagKubeConfigData = "kube-config-data"
 	// FlagReplace allows the resources to be re-created rather than patched
@@ -50,6 +65,186 @@ const (
 	FlagDelete = "delete"
 	// FlagAllowMissing indicates whether missing property values are allowed (replaced with <no value> if not provided)
 	FlagAllowMissing = "allow-missing"
+	// FlagEnvName is the name of the environment (and namespace) for the env commands
+	FlagEnvName = "name"
+	// FlagTTL is how long resources or an environment should live before they can be reaped
+	FlagTTL = "ttl"
+	// FlagReapKinds specifies which kinds of resources the reap command will check
+	FlagReapKinds = "reap-kinds"
+	// FlagRelease is the name of the release resources are labelled with
+	FlagRelease = "release"
+	// FlagValidateReferences checks the references between resources after rendering
+	FlagValidateReferences = "validate-references"
+	// FlagOutputDir is the directory manifests are written to
+	FlagOutputDir = "output-dir"
+	// FlagStateFile is where the progress of a release is recorded so it can be resumed
+	FlagStateFile = "state-file"
+	// FlagTriggerCronJob creates a job from each cronjob deployed and watches it
+	FlagTriggerCronJob = "trigger-cronjob"
+	// FlagTestCronJob triggers each cronjob deployed like FlagTriggerCronJob and deletes the job afterwards
+	FlagTestCronJob = "test-cronjob"
+	// FlagReadOnly refuses to run any kubectl command which could change the cluster
+	FlagReadOnly = "read-only"
+	// FlagKindPlugin runs a command to check a kind of resource instead of watching it
+	FlagKindPlugin = "kind-plugin"
+	// FlagWaitForURL waits for a url to be available before deploying
+	FlagWaitForURL = "wait-for-url"
+	// FlagWaitForTCP waits for a tcp address to accept connections before deploying
+	FlagWaitForTCP = "wait-for-tcp"
+	// FlagWaitForTimeout is how long to wait for the urls and tcp addresses
+	FlagWaitForTimeout = "wait-for-timeout"
+	// FlagPodLogLines is the number of log lines shown for each pod which isn't ready when a watch fails
+	FlagPodLogLines = "pod-log-lines"
+	// FlagHookTimeout is how long to wait for jobs (e.g. migrations) rather than the timeout
+	FlagHookTimeout = "hook-timeout"
+	// FlagRestartDependents restarts workloads using configmaps or secrets which changed
+	FlagRestartDependents = "restart-dependents"
+	// FlagPrune deletes resources with the prune selector which weren't deployed
+	FlagPrune = "prune"
+	// FlagPruneSelector is the label selector added to resources to find them when pruning
+	FlagPruneSelector = "prune-selector"
+	// FlagPruneKinds specifies which kinds of resources are checked when pruning
+	FlagPruneKinds = "prune-kinds"
+	// DefaultKinds are the kinds of resources checked by the reap command and when pruning
+	DefaultKinds = "namespaces,deployments,statefulsets,daemonsets,jobs,cronjobs,services,ingresses,configmaps,secrets"
+	// FlagRenderTimeout limits how long rendering, including any lookups, can take
+	FlagRenderTimeout = "render-timeout"
+	// FlagServerSide uses server side apply rather than client side apply
+	FlagServerSide = "server-side"
+	// FlagFieldManager is the name of the field manager used with server side apply
+	FlagFieldManager = "field-manager"
+	// FlagValidation is how kubectl validates the resources applied
+	FlagValidation = "validation"
+	// FlagValidatePlatforms warns when images don't support the architectures of the nodes
+	FlagValidatePlatforms = "validate-platforms"
+	// FlagPlatforms are the node architectures to check images against instead of the live nodes
+	FlagPlatforms = "platforms"
+	// FlagLogFormat is the format of the log output, text or json
+	FlagLogFormat = "log-format"
+	// FlagLogGroups is how the messages about resources deployed or watched in parallel are kept together
+	FlagLogGroups = "log-groups"
+	// FlagLogLevel is the least severe level of log messages shown
+	FlagLogLevel = "log-level"
+	// FlagQuiet only shows failures and a final summary
+	FlagQuiet = "quiet"
+	// FlagValidateProbes warns about probe and lifecycle settings which cause failed rollouts
+	FlagValidateProbes = "validate-probes"
+	// FlagValidateScheduling warns when pods can't be scheduled on any live node
+	FlagValidateScheduling = "validate-scheduling"
+	// FlagValidateStorage checks the storage classes and quota for the storage requested
+	FlagValidateStorage = "validate-storage"
+	// FlagValidate checks the rendered resources against the kubernetes json schemas
+	FlagValidate = "validate"
+	// FlagKubernetesVersion is the kubernetes version of the schemas to validate against
+	FlagKubernetesVersion = "kubernetes-version"
+	// FlagSchemaLocation is the base url or directory of the kubernetes json schemas
+	FlagSchemaLocation = "schema-location"
+	// FlagNotes is a template rendered and printed after a successful deploy
+	FlagNotes = "notes"
+	// FlagPromoteFrom is the environment the promote command reads the running images from
+	FlagPromoteFrom = "from"
+	// FlagPromoteTo is the environment the promote command deploys to
+	FlagPromoteTo = "to"
+	// FlagYes rolls back without asking for confirmation
+	FlagYes = "yes"
+	// FlagRevision is the revision of the release to roll back to
+	FlagRevision = "revision"
+	// FlagHistoryLimit is how many revisions of a release's manifests are kept
+	FlagHistoryLimit = "history-limit"
+	// FlagImagePolicy rejects mutable image tags (or any tag) when deploying to protected contexts
+	FlagImagePolicy = "image-policy"
+	// FlagMutableTags matches the image tags rejected by the immutable-tags image policy
+	FlagMutableTags = "mutable-tags"
+	// FlagProtectedContexts are the contexts the image policy applies to (all when empty)
+	FlagProtectedContexts = "protected-contexts"
+	// FlagValues are yaml or json files of values available to templates as .Values
+	FlagValues = "values"
+	// FlagTemplateDelims are the left and right template delimiters used instead of {{ and }}
+	FlagTemplateDelims = "template-delims"
+	// FlagWatchEngine is how workloads are watched, kd's own status checks or kubectl rollout status
+	FlagWatchEngine = "watch-engine"
+	// FlagApplyRetries is the number of times a transient kubectl failure is retried for a resource
+	FlagApplyRetries = "apply-retries"
+	// FlagApplyRetryInterval is the delay before the first retry, doubling for each one after
+	FlagApplyRetryInterval = "apply-retry-interval"
+	// FlagDeployDelay is the delay before the status of a resource is first checked
+	FlagDeployDelay = "deploy-delay"
+	// FlagHealthCheckRetries is the number of times fetching the status of a resource is tried
+	FlagHealthCheckRetries = "health-check-retries"
+	// FlagHealthCheckInterval is the delay before the first retry of a status check, doubling for each one after
+	FlagHealthCheckInterval = "health-check-interval"
+	// FlagWatchAfterApply applies every resource before watching the workloads at the same time
+	FlagWatchAfterApply = "watch-after-apply"
+	// FlagWorkspace is the directory the workspace of each run (for generated files) is created in
+	FlagWorkspace = "workspace"
+	// FlagNoWait applies the resources without watching them
+	FlagNoWait = "no-wait"
+	// FlagCreateNamespace creates the namespace resources are deployed to when it doesn't exist
+	FlagCreateNamespace = "create-namespace"
+	// FlagNamespaceLabels are the labels set on the namespace created with FlagCreateNamespace
+	FlagNamespaceLabels = "namespace-labels"
+	// FlagStatusFixtures is the directory of recorded statuses the simulate command watches
+	FlagStatusFixtures = "status-fixtures"
+	// FlagDiffFormat is how the diff command shows the changes, unified, json-patch or semantic
+	FlagDiffFormat = "diff-format"
+	// FlagDiffIgnore are the fields left out when comparing resources with the cluster
+	FlagDiffIgnore = "diff-ignore"
+	// FlagLabel is a label added to every resource
+	FlagLabel = "label"
+	// FlagAnnotate is an annotation added to every resource
+	FlagAnnotate = "annotate"
+	// FlagLabelPodTemplates also adds the FlagLabel labels and FlagAnnotate annotations to pod templates
+	FlagLabelPodTemplates = "label-pod-templates"
+	// FlagSet is a template variable set on the command line, overriding the environment and config files
+	FlagSet = "set"
+	// FlagExportEnv is the file the outputs of a deploy are written to for later pipeline steps
+	FlagExportEnv = "export-env"
+	// FlagPostDeployHook is a command run after a successful deploy with the results of each resource
+	FlagPostDeployHook = "post-deploy-hook"
+	// FlagResumePaused resumes paused Deployments after applying them
+	FlagResumePaused = "resume-paused"
+	// FlagPreserveReplicas keeps the running replicas of workloads which leave them out of their manifests
+	FlagPreserveReplicas = "preserve-replicas"
+	// FlagKustomize is a kustomization directory built and deployed along with the files
+	FlagKustomize = "kustomize"
+	// FlagKustomizeRender renders the resources built by kustomize as templates
+	FlagKustomizeRender = "kustomize-render"
+	// FlagValidatePodSecurity warns when pods break the pod security level of their namespace
+	FlagValidatePodSecurity = "validate-pod-security"
+	// FlagNetworkDependency is the pods workloads need to reach past network policies
+	FlagNetworkDependency = "network-dependency"
+	// FlagChart is a helm chart rendered locally and deployed along with the files
+	FlagChart = "chart"
+	// FlagChartValues is a values file for the helm chart
+	FlagChartValues = "chart-values"
+	// FlagScaleUpTimeout extends the timeout of a watch while the cluster autoscaler adds nodes and pods become available
+	FlagScaleUpTimeout = "scale-up-timeout"
+	// FlagExclude is a pattern of the files and directories skipped in a directory
+	FlagExclude = "exclude"
+	// FlagDerive is a value computed from other variables before rendering
+	FlagDerive = "derive"
+	// FlagHeartbeat is the interval of the progress lines written while deploying
+	FlagHeartbeat = "heartbeat"
+	// FlagURLHeader is a header sent when fetching --file urls
+	FlagURLHeader = "url-header"
+	// FlagNamePrefix is added to the names of the resources and the references between them
+	FlagNamePrefix = "name-prefix"
+	// FlagNameSuffix is added to the end of the names of the resources and the references between them
+	FlagNameSuffix = "name-suffix"
+	// FlagGitRepo is a git repo the manifests are deployed from
+	FlagGitRepo = "git-repo"
+	// FlagGitRef is the branch, tag or commit of the git repo
+	FlagGitRef = "git-ref"
+	// FlagGitPath is the file or directory of manifests in the git repo
+	FlagGitPath = "git-path"
+	// FlagProject is the project file of default settings for each environment
+	FlagProject = "project"
+	// FlagEnv selects the environment settings of the project file
+	FlagEnv = "env"
+	// FlagBatchParallel is the number of batch jobs run at the same time
+	FlagBatchParallel = "batch-parallel"
+	// FlagDebugRender prints the template context at a file and line instead of deploying
+	FlagDebugRender = "debug-render"
 )
 
 var (
@@ -57,13 +252,18 @@ var (
 	Version string
 
 	logInfo    *log.Logger
+	logWarn    *log.Logger
 	logError   *log.Logger
 	logDebug   *log.Logger
 	logDebugIf *log.Logger
+	logSummary *log.Logger
 
 	// dryRun Defaults to false
 	dryRun bool
 
+	// readOnly refuses any kubectl command which could change the cluster
+	readOnly bool
+
 	// deleteReources bool
 	deleteResources bool
 
@@ -73,15 +273,26 @@ var (
 	// caFile
 	caFile string
 
+	// kubeconfig generated for a credential plugin
+	execKubeConfigFile string
+
+	// kubeCmdLock protects the files generated when creating kubectl commands
+	kubeCmdLock sync.Mutex
+
 	// Allow missing variables to be tolerated
 	allowMissingVariables bool
+
+	// Render templates without anything which changes between runs
+	reproducible bool
 )
 
 func init() {
-	logInfo = log.New(os.Stdout, "[INFO] ", log.Ldate|log.Ltime|log.Lshortfile)
-	logError = log.New(os.Stderr, "[ERROR] ", log.Ldate|log.Ltime|log.Lshortfile)
-	logDebugIf = log.New(os.Stderr, "[DEBUG] ", log.Ldate|log.Ltime|log.Lshortfile)
+	logInfo = log.New(logOutput.writer(os.Stdout), "[INFO] ", log.Ldate|log.Ltime|log.Lshortfile)
+	logWarn = log.New(logOutput.writer(os.Stderr), "[WARN] ", log.Ldate|log.Ltime|log.Lshortfile)
+	logError = log.New(logOutput.writer(os.Stderr), "[ERROR] ", log.Ldate|log.Ltime|log.Lshortfile)
+	logDebugIf = log.New(logOutput.writer(os.Stderr), "[DEBUG] ", log.Ldate|log.Ltime|log.Lshortfile)
 	logDebug = log.New(ioutil.Discard, "", log.Lshortfile)
+	logSummary = logInfo
 }
 
 func main() {
@@ -94,20 +305,54 @@ func main() {
 	app.Flags = []cli.Flag{
 		cli.BoolFlag{
 			Name:   "debug",
-			Usage:  "debug output",
+			Usage:  "debug output, the same as --log-level debug",
 			EnvVar: "DEBUG,PLUGIN_DEBUG",
 		},
+		cli.StringFlag{
+			Name:   FlagLogLevel,
+			Usage:  "the `LEVEL` of log messages shown, one of error, warn, info or debug",
+			Value:  "info",
+			EnvVar: "KD_LOG_LEVEL,PLUGIN_KD_LOG_LEVEL",
+		},
+		cli.BoolFlag{
+			Name:   FlagQuiet,
+			Usage:  "if true, only failures and a final summary are shown",
+			EnvVar: "KD_QUIET,PLUGIN_KD_QUIET",
+		},
+		cli.StringFlag{
+			Name:   FlagLogFormat,
+			Usage:  "the log `FORMAT`, text or json for structured records",
+			Value:  "text",
+			EnvVar: "KD_LOG_FORMAT,PLUGIN_KD_LOG_FORMAT",
+		},
+		cli.StringFlag{
+			Name:   FlagLogGroups,
+			Usage:  "how the messages about resources deployed or watched in parallel are kept together, `STYLE` prefix (with the resource), github or gitlab (collapsible sections)",
+			Value:  "prefix",
+			EnvVar: "KD_LOG_GROUPS,PLUGIN_KD_LOG_GROUPS",
+		},
 		cli.BoolFlag{
 			Name:   "debug-templates",
 			Usage:  "debug template output",
 			EnvVar: "DEBUG_TEMPLATES,PLUGIN_DEBUG_TEMPLATES",
 		},
+		cli.StringFlag{
+			Name:   FlagDebugRender,
+			Usage:  "print the template context at `FILE:LINE` and exit prior to deployment",
+			EnvVar: "KD_DEBUG_RENDER,PLUGIN_KD_DEBUG_RENDER",
+		},
 		cli.BoolFlag{
 			Name:        "dryrun",
 			Usage:       "if true, kd will exit prior to deployment",
 			EnvVar:      "DRY_RUN",
 			Destination: &dryRun,
 		},
+		cli.BoolFlag{
+			Name:        FlagReadOnly,
+			Usage:       "if true, kd fails rather than running any kubectl command which could change the cluster",
+			EnvVar:      "KD_READ_ONLY,PLUGIN_KD_READ_ONLY",
+			Destination: &readOnly,
+		},
 		cli.BoolFlag{
 			Name:        "delete",
 			Usage:       "instead of applying the resources we are deleting them",
@@ -118,6 +363,11 @@ func main() {
 			Usage:  "if true, the server's certificate will not be checked for validity",
 			EnvVar: "INSECURE_SKIP_TLS_VERIFY,PLUGIN_INSECURE_SKIP_TLS_VERIFY",
 		},
+		cli.StringFlag{
+			Name:   FlagKubeConfig,
+			Usage:  "the path to the kubernetes config file `PATH`",
+			EnvVar: "KUBECONFIG,PLUGIN_KUBECONFIG",
+		},
 		cli.StringFlag{
 			Name:   FlagKubeConfigData,
 			Usage:  "Kubernetes config file data",
@@ -133,6 +383,22 @@ func main() {
 			Usage:  "kubernetes auth `TOKEN`",
 			EnvVar: "KUBE_TOKEN,PLUGIN_KUBE_TOKEN",
 		},
+		cli.StringFlag{
+			Name:   FlagKubeExecCommand,
+			Usage:  "kubernetes auth credential plugin `COMMAND` e.g. aws or aws-iam-authenticator",
+			EnvVar: "KUBE_EXEC_COMMAND,PLUGIN_KUBE_EXEC_COMMAND",
+		},
+		cli.StringSliceFlag{
+			Name:   FlagKubeExecArgs,
+			Usage:  "the arguments for the credential plugin e.g. 'eks,get-token,--cluster-name,my-cluster'",
+			EnvVar: "KUBE_EXEC_ARGS,PLUGIN_KUBE_EXEC_ARGS",
+		},
+		cli.StringFlag{
+			Name:   FlagKubeExecAPIVersion,
+			Usage:  "the credential plugin `API_VERSION`",
+			Value:  "client.authentication.k8s.io/v1beta1",
+			EnvVar: "KUBE_EXEC_API_VERSION,PLUGIN_KUBE_EXEC_API_VERSION",
+		},
 		cli.StringFlag{
 			Name:   "kube-username, u",
 			Usage:  "kubernetes auth `USERNAME`",
@@ -143,14 +409,24 @@ func main() {
 			Usage:  "kubernetes auth `PASSWORD`",
 			EnvVar: "KUBE_PASSWORD,PLUGIN_KUBE_PASSWORD",
 		},
-		cli.StringFlag{
+		cli.StringSliceFlag{
 			Name:   "config",
-			Usage:  "Env file location",
+			Usage:  "Env file location (decrypted with sops if encrypted), can be repeated with later files overriding earlier ones",
 			EnvVar: "CONFIG_FILE,PLUGIN_CONFIG_FILE",
 		},
+		cli.StringFlag{
+			Name:   FlagTemplateDelims,
+			Usage:  "the `LEFT,RIGHT` template delimiters used instead of {{ and }} e.g. '[[,]]'",
+			EnvVar: "KD_TEMPLATE_DELIMS,PLUGIN_KD_TEMPLATE_DELIMS",
+		},
+		cli.StringSliceFlag{
+			Name:   FlagValues,
+			Usage:  "a yaml or json `FILE` of values available to templates as .Values, can be repeated with later files merged over earlier ones",
+			EnvVar: "KD_VALUES,PLUGIN_KD_VALUES",
+		},
 		cli.StringSliceFlag{
 			Name:   FlagConfigData,
-			Usage:  "Config data e.g. '--config-data Chart=./Chart.yaml' or '--config-data ./data.yaml'",
+			Usage:  "Config data e.g. '--config-data Chart=./Chart.yaml' or '--config-data ./data.yaml' (decrypted with sops if encrypted)",
 			EnvVar: "KD_CONFIG_DATA,PLUGIN_KD_CONFIG_DATA",
 			Value:  nil,
 		},
@@ -170,6 +446,23 @@ func main() {
 			Usage:  "use replace instead of apply for updating objects",
 			EnvVar: "KUBE_REPLACE,PLUGIN_KUBE_REPLACE",
 		},
+		cli.BoolFlag{
+			Name:   FlagServerSide,
+			Usage:  "use server side apply, avoiding the size limit of the last applied configuration annotation",
+			EnvVar: "KD_SERVER_SIDE,PLUGIN_KD_SERVER_SIDE",
+		},
+		cli.StringFlag{
+			Name:   FlagValidation,
+			Usage:  "how kubectl validates resources, 'strict' rejects unknown and duplicate fields, 'warn' or 'ignore' (kubectl before 1.25 only supports strict or ignore)",
+			Value:  "strict",
+			EnvVar: "KD_VALIDATION,PLUGIN_KD_VALIDATION",
+		},
+		cli.StringFlag{
+			Name:   FlagFieldManager,
+			Usage:  "the field manager `NAME` recorded for fields set with server side apply",
+			Value:  "kd",
+			EnvVar: "KD_FIELD_MANAGER,PLUGIN_KD_FIELD_MANAGER",
+		},
 		cli.StringFlag{
 			Name:   "context, c",
 			Usage:  "kube config `CONTEXT`",
@@ -197,8 +490,7 @@ func main() {
 		},
 		cli.StringFlag{
 			Name:   FlagCaFile,
-			Usage:  "the path to save certificate authority data to when data or a URL is specified",
-			Value:  "/tmp/kube-ca.pem",
+			Usage:  "the path to save certificate authority data to when data or a URL is specified, by default in the workspace",
 			EnvVar: "KUBE_CERTIFICATE_AUTHORITY_FILE,PLUGIN_KUBE_CERTIFICATE_AUTHORITY_FILE",
 		},
 		cli.StringSliceFlag{
@@ -206,23 +498,400 @@ func main() {
 			Usage:  "the path to a file or directory containing kubernetes resources `PATH`",
 			EnvVar: "FILES,PLUGIN_FILES",
 		},
+		cli.StringSliceFlag{
+			Name:   FlagKustomize + ", k",
+			Usage:  "a kustomization `DIR` built with kustomize and deployed like the files, can be repeated",
+			EnvVar: "KD_KUSTOMIZE,PLUGIN_KD_KUSTOMIZE",
+		},
+		cli.BoolFlag{
+			Name:   FlagKustomizeRender,
+			Usage:  "if true, the resources built by kustomize are rendered as templates",
+			EnvVar: "KD_KUSTOMIZE_RENDER,PLUGIN_KD_KUSTOMIZE_RENDER",
+		},
+		cli.StringSliceFlag{
+			Name:   FlagURLHeader,
+			Usage:  "a `NAME: VALUE` header, e.g. for authorization, sent when fetching --file urls, can be repeated",
+			EnvVar: "KD_URL_HEADER,PLUGIN_KD_URL_HEADER",
+		},
+		cli.StringSliceFlag{
+			Name:   FlagExclude,
+			Usage:  "skip the files matching a glob `PATTERN`, or directories with a trailing /, in a --file directory, can be repeated",
+			EnvVar: "KD_EXCLUDE,PLUGIN_KD_EXCLUDE",
+		},
+		cli.StringFlag{
+			Name:   FlagGitRepo,
+			Usage:  "a git repo `URL` to fetch and deploy manifests from, along with any files",
+			EnvVar: "KD_GIT_REPO,PLUGIN_KD_GIT_REPO",
+		},
+		cli.StringFlag{
+			Name:   FlagGitRef,
+			Usage:  "the branch, tag or commit `REF` of the --git-repo",
+			Value:  "HEAD",
+			EnvVar: "KD_GIT_REF,PLUGIN_KD_GIT_REF",
+		},
+		cli.StringFlag{
+			Name:   FlagGitPath,
+			Usage:  "the file, directory or pattern `PATH` of the manifests in the --git-repo",
+			Value:  ".",
+			EnvVar: "KD_GIT_PATH,PLUGIN_KD_GIT_PATH",
+		},
+		cli.StringFlag{
+			Name:   FlagChart,
+			Usage:  "a helm chart `PATH` or repo/name rendered with helm template and deployed like the files",
+			EnvVar: "KD_CHART,PLUGIN_KD_CHART",
+		},
+		cli.StringSliceFlag{
+			Name:   FlagChartValues,
+			Usage:  "a values `FILE` for the --chart, can be repeated",
+			EnvVar: "KD_CHART_VALUES,PLUGIN_KD_CHART_VALUES",
+		},
+		cli.DurationFlag{
+			Name:   FlagScaleUpTimeout,
+			Usage:  "extend the timeout by `DURATION` when the cluster autoscaler is adding nodes for pending pods, and again while more pods become available",
+			EnvVar: "KD_SCALE_UP_TIMEOUT,PLUGIN_KD_SCALE_UP_TIMEOUT",
+		},
+		cli.DurationFlag{
+			Name:   FlagHeartbeat,
+			Usage:  "write a progress line every `INTERVAL` while deploying, for CI systems which stop jobs without output",
+			EnvVar: "KD_HEARTBEAT,PLUGIN_KD_HEARTBEAT",
+		},
 		cli.DurationFlag{
 			Name:   "timeout, T",
 			Usage:  "the amount of time to wait for a successful deployment `TIMEOUT`",
 			EnvVar: "TIMEOUT,PLUGIN_TIMEOUT",
 			Value:  time.Duration(3) * time.Minute,
 		},
+		cli.DurationFlag{
+			Name:   FlagRenderTimeout,
+			Usage:  "the amount of time rendering the templates, including any lookups, can take `TIMEOUT`",
+			EnvVar: "KD_RENDER_TIMEOUT,PLUGIN_KD_RENDER_TIMEOUT",
+		},
+		cli.DurationFlag{
+			Name:   FlagHookTimeout,
+			Usage:  "the amount of time to wait for jobs, e.g. migrations, instead of --timeout `TIMEOUT`",
+			EnvVar: "KD_HOOK_TIMEOUT,PLUGIN_KD_HOOK_TIMEOUT",
+		},
+		cli.BoolFlag{
+			Name:   FlagFileOrder,
+			Usage:  "if true, resources are deployed in the order of the files rather than namespaces, configmaps etc. first",
+			EnvVar: "KD_FILE_ORDER,PLUGIN_KD_FILE_ORDER",
+		},
+		cli.IntFlag{
+			Name:   FlagConcurrency,
+			Usage:  "the number of consecutive deployments, statefulsets, daemonsets or jobs to deploy and watch in parallel `N`",
+			EnvVar: "KD_CONCURRENCY,PLUGIN_KD_CONCURRENCY",
+			Value:  1,
+		},
+		cli.StringSliceFlag{
+			Name:   FlagWaitForURL,
+			Usage:  "wait for a `URL` to respond without an error status before deploying",
+			EnvVar: "KD_WAIT_FOR_URL,PLUGIN_KD_WAIT_FOR_URL",
+		},
+		cli.StringSliceFlag{
+			Name:   FlagWaitForTCP,
+			Usage:  "wait for a `HOST:PORT` to accept connections before deploying",
+			EnvVar: "KD_WAIT_FOR_TCP,PLUGIN_KD_WAIT_FOR_TCP",
+		},
+		cli.DurationFlag{
+			Name:   FlagWaitForTimeout,
+			Usage:  "the amount of time to wait for the urls and tcp addresses `TIMEOUT`",
+			EnvVar: "KD_WAIT_FOR_TIMEOUT,PLUGIN_KD_WAIT_FOR_TIMEOUT",
+			Value:  time.Duration(1) * time.Minute,
+		},
+		cli.IntFlag{
+			Name:   FlagPodLogLines,
+			Usage:  "the number of log `LINES` shown for each pod which isn't ready when a rollout fails, 0 to disable",
+			EnvVar: "KD_POD_LOG_LINES,PLUGIN_KD_POD_LOG_LINES",
+			Value:  50,
+		},
 		cli.DurationFlag{
 			Name:   "check-interval",
 			Usage:  "deployment status check interval `INTERVAL`",
 			EnvVar: "CHECK_INTERVAL,PLUGIN_CHECK_INTERVAL",
 			Value:  time.Duration(1000) * time.Millisecond,
 		},
+		cli.DurationFlag{
+			Name:   FlagDeployDelay,
+			Usage:  "the `DELAY` after applying a resource before checking its status for the first time",
+			Value:  DeployDelaySeconds * time.Second,
+			EnvVar: "KD_DEPLOY_DELAY,PLUGIN_KD_DEPLOY_DELAY",
+		},
+		cli.IntFlag{
+			Name:   FlagHealthCheckRetries,
+			Usage:  "the `NUMBER` of times fetching the status of a resource is tried before the watch fails",
+			Value:  MaxHealthcheckRetries,
+			EnvVar: "KD_HEALTH_CHECK_RETRIES,PLUGIN_KD_HEALTH_CHECK_RETRIES",
+		},
+		cli.DurationFlag{
+			Name:   FlagHealthCheckInterval,
+			Usage:  "the `INTERVAL` before retrying a failed status check, doubling (with jitter) for each retry after",
+			Value:  HealthCheckSleepDuration,
+			EnvVar: "KD_HEALTH_CHECK_INTERVAL,PLUGIN_KD_HEALTH_CHECK_INTERVAL",
+		},
+		cli.StringSliceFlag{
+			Name:   FlagKindPlugin,
+			Usage:  "run a command after deploying a kind of resource, instead of watching it, e.g. 'FlinkDeployment=./check-flink.sh'",
+			EnvVar: "KD_KIND_PLUGINS,PLUGIN_KD_KIND_PLUGINS",
+		},
+		cli.BoolFlag{
+			Name:   FlagRestartDependents,
+			Usage:  "if true, unchanged deployments, statefulsets and daemonsets using a configmap or secret which changed are restarted",
+			EnvVar: "KD_RESTART_DEPENDENTS,PLUGIN_KD_RESTART_DEPENDENTS",
+		},
+		cli.BoolFlag{
+			Name:   FlagTriggerCronJob,
+			Usage:  "if true, a job is created from each cronjob deployed and watched to completion",
+			EnvVar: "KD_TRIGGER_CRONJOB,PLUGIN_KD_TRIGGER_CRONJOB",
+		},
+		cli.BoolFlag{
+			Name:   FlagTestCronJob,
+			Usage:  "if true, a job is created from each cronjob deployed, watched to completion and deleted",
+			EnvVar: "KD_TEST_CRONJOB,PLUGIN_KD_TEST_CRONJOB",
+		},
 		cli.BoolFlag{
 			Name:   FlagAllowMissing,
 			Usage:  "if true, missing variables will be replaced with <no value> instead of generating an error",
 			EnvVar: "ALLOW_MISSING",
 		},
+		cli.StringFlag{
+			Name:   FlagRelease,
+			Usage:  "label the resources as managed by kd for the release `NAME`",
+			EnvVar: "KD_RELEASE,PLUGIN_KD_RELEASE",
+		},
+		cli.IntFlag{
+			Name:   FlagHistoryLimit,
+			Usage:  "the number of revisions of a release's manifests kept to roll back to, 0 to keep every revision",
+			Value:  10,
+			EnvVar: "KD_HISTORY_LIMIT,PLUGIN_KD_HISTORY_LIMIT",
+		},
+		cli.BoolFlag{
+			Name:   FlagReproducible,
+			Usage:  "if true, the now template function returns SOURCE_DATE_EPOCH (or zero) so rendered output is the same for every run",
+			EnvVar: "KD_REPRODUCIBLE,PLUGIN_KD_REPRODUCIBLE",
+		},
+		cli.BoolFlag{
+			Name:   FlagValidateReferences,
+			Usage:  "check services, ingresses, configmaps and secrets referenced by resources exist in the files or the cluster",
+			EnvVar: "KD_VALIDATE_REFERENCES,PLUGIN_KD_VALIDATE_REFERENCES",
+		},
+		cli.StringFlag{
+			Name:   FlagOutputDir,
+			Usage:  "write each rendered resource to `DIR` as kind-name.yaml (import writes to the current directory by default)",
+			EnvVar: "KD_OUTPUT_DIR,PLUGIN_KD_OUTPUT_DIR",
+		},
+		cli.StringFlag{
+			Name:   FlagStateFile,
+			Usage:  "record the resources completed at `PATH` so an interrupted release can be resumed, see the resume command",
+			EnvVar: "KD_STATE_FILE,PLUGIN_KD_STATE_FILE",
+		},
+		cli.BoolFlag{
+			Name:   FlagPrune,
+			Usage:  "if true, resources with the --prune-selector labels which weren't deployed are deleted after a successful deploy",
+			EnvVar: "KD_PRUNE,PLUGIN_KD_PRUNE",
+		},
+		cli.StringFlag{
+			Name:   FlagPruneSelector,
+			Usage:  "the labels added to every resource to find them when pruning e.g. 'app=foo' `SELECTOR`",
+			EnvVar: "KD_PRUNE_SELECTOR,PLUGIN_KD_PRUNE_SELECTOR",
+		},
+		cli.StringFlag{
+			Name:   FlagPruneKinds,
+			Usage:  "the comma separated `KINDS` of resources to check when pruning",
+			Value:  DefaultKinds,
+			EnvVar: "KD_PRUNE_KINDS,PLUGIN_KD_PRUNE_KINDS",
+		},
+		cli.BoolFlag{
+			Name:   FlagValidatePlatforms,
+			Usage:  "warn when an image (checked with docker manifest inspect) has no manifest for an architecture of the nodes",
+			EnvVar: "KD_VALIDATE_PLATFORMS,PLUGIN_KD_VALIDATE_PLATFORMS",
+		},
+		cli.BoolFlag{
+			Name:   FlagValidateProbes,
+			Usage:  "warn when workloads have no readiness probe, a probe delay longer than the timeout or a preStop hook longer than the grace period",
+			EnvVar: "KD_VALIDATE_PROBES,PLUGIN_KD_VALIDATE_PROBES",
+		},
+		cli.BoolFlag{
+			Name:   FlagValidateScheduling,
+			Usage:  "warn when the nodeSelector, node affinity and tolerations of pods don't match any schedulable node",
+			EnvVar: "KD_VALIDATE_SCHEDULING,PLUGIN_KD_VALIDATE_SCHEDULING",
+		},
+		cli.BoolFlag{
+			Name:   FlagValidatePodSecurity,
+			Usage:  "warn when pods would be rejected by the pod security admission level of their namespace",
+			EnvVar: "KD_VALIDATE_POD_SECURITY,PLUGIN_KD_VALIDATE_POD_SECURITY",
+		},
+		cli.StringSliceFlag{
+			Name:   FlagNetworkDependency,
+			Usage:  "warn when network policies stop pods reaching the pods selected by `[NAMESPACE/]KEY=VALUE[,KEY=VALUE]`, can be repeated",
+			EnvVar: "KD_NETWORK_DEPENDENCY,PLUGIN_KD_NETWORK_DEPENDENCY",
+		},
+		cli.BoolFlag{
+			Name:   FlagValidateStorage,
+			Usage:  "check the storage classes used by claims exist and the storage requested fits in the quota",
+			EnvVar: "KD_VALIDATE_STORAGE,PLUGIN_KD_VALIDATE_STORAGE",
+		},
+		cli.BoolFlag{
+			Name:   FlagValidate,
+			Usage:  "check the rendered resources against the kubernetes json schemas before anything is applied",
+			EnvVar: "KD_VALIDATE,PLUGIN_KD_VALIDATE",
+		},
+		cli.StringFlag{
+			Name:   FlagKubernetesVersion,
+			Usage:  "the kubernetes `VERSION` of the schemas used by --validate e.g. '1.18.0'",
+			Value:  "master",
+			EnvVar: "KD_KUBERNETES_VERSION,PLUGIN_KD_KUBERNETES_VERSION",
+		},
+		cli.StringFlag{
+			Name:   FlagSchemaLocation,
+			Usage:  "the base url or `DIR` of the json schemas used by --validate (laid out as VERSION-standalone-strict/KIND.json)",
+			Value:  DefaultSchemaLocation,
+			EnvVar: "KD_SCHEMA_LOCATION,PLUGIN_KD_SCHEMA_LOCATION",
+		},
+		cli.StringSliceFlag{
+			Name:   FlagPlatforms,
+			Usage:  "the node architectures to check images against instead of querying the nodes e.g. 'amd64,arm64'",
+			EnvVar: "KD_PLATFORMS,PLUGIN_KD_PLATFORMS",
+		},
+		cli.StringFlag{
+			Name:   FlagImagePolicy,
+			Usage:  "'immutable-tags' rejects images without a tag or with a mutable tag (see --mutable-tags), 'digest' rejects images not referenced by digest",
+			Value:  "none",
+			EnvVar: "KD_IMAGE_POLICY,PLUGIN_KD_IMAGE_POLICY",
+		},
+		cli.StringFlag{
+			Name:   FlagMutableTags,
+			Usage:  "a `REGEX` matching the mutable image tags rejected by --image-policy immutable-tags",
+			Value:  DefaultMutableTags,
+			EnvVar: "KD_MUTABLE_TAGS,PLUGIN_KD_MUTABLE_TAGS",
+		},
+		cli.StringSliceFlag{
+			Name:   FlagProtectedContexts,
+			Usage:  "the comma separated kube `CONTEXTS` the --image-policy applies to, all contexts when not set",
+			EnvVar: "KD_PROTECTED_CONTEXTS,PLUGIN_KD_PROTECTED_CONTEXTS",
+		},
+		cli.StringFlag{
+			Name:   FlagNotes,
+			Usage:  "a template `FILE` (e.g. NOTES.txt) rendered with the config data and printed after a successful deploy",
+			EnvVar: "KD_NOTES,PLUGIN_KD_NOTES",
+		},
+		cli.StringFlag{
+			Name:   FlagWatchEngine,
+			Usage:  "how deployments, statefulsets and daemonsets are watched, `ENGINE` native (kd's status checks) or kubectl (kubectl rollout status)",
+			Value:  "native",
+			EnvVar: "KD_WATCH_ENGINE,PLUGIN_KD_WATCH_ENGINE",
+		},
+		cli.IntFlag{
+			Name:   FlagApplyRetries,
+			Usage:  "the `NUMBER` of times to retry applying a resource after a transient failure e.g. a webhook being unavailable or a conflict",
+			EnvVar: "KD_APPLY_RETRIES,PLUGIN_KD_APPLY_RETRIES",
+		},
+		cli.DurationFlag{
+			Name:   FlagApplyRetryInterval,
+			Usage:  "the `INTERVAL` before the first apply retry, doubling for each retry after",
+			Value:  2 * time.Second,
+			EnvVar: "KD_APPLY_RETRY_INTERVAL,PLUGIN_KD_APPLY_RETRY_INTERVAL",
+		},
+		cli.BoolFlag{
+			Name:   FlagWatchAfterApply,
+			Usage:  "if true, every resource is applied first and then the workloads are watched at the same time, jobs and dependencies are still waited for",
+			EnvVar: "KD_WATCH_AFTER_APPLY,PLUGIN_KD_WATCH_AFTER_APPLY",
+		},
+		cli.StringFlag{
+			Name:   FlagWorkspace,
+			Usage:  "the `DIR` a private workspace for the files generated by each run is created in, by default XDG_RUNTIME_DIR or TMPDIR",
+			EnvVar: "KD_WORKSPACE,PLUGIN_KD_WORKSPACE",
+		},
+		cli.BoolFlag{
+			Name:   FlagNoWait,
+			Usage:  "if true, resources are applied without waiting for them to complete",
+			EnvVar: "KD_NO_WAIT,PLUGIN_KD_NO_WAIT",
+		},
+		cli.BoolFlag{
+			Name:   FlagCreateNamespace,
+			Usage:  "if true, the --namespace is created before deploying when it doesn't exist",
+			EnvVar: "KD_CREATE_NAMESPACE,PLUGIN_KD_CREATE_NAMESPACE",
+		},
+		cli.StringFlag{
+			Name:   FlagNamespaceLabels,
+			Usage:  "the `LABELS` (key=value[,key=value]) set on the namespace with --create-namespace",
+			EnvVar: "KD_NAMESPACE_LABELS,PLUGIN_KD_NAMESPACE_LABELS",
+		},
+		cli.StringSliceFlag{
+			Name:   FlagDiffIgnore,
+			Usage:  "a `[KIND:]FIELD` left out when diffing with the cluster e.g. Deployment:spec.replicas or metadata.annotations[\"cert-manager.io/*\"], can be repeated",
+			EnvVar: "KD_DIFF_IGNORE,PLUGIN_KD_DIFF_IGNORE",
+		},
+		cli.StringSliceFlag{
+			Name:   FlagSet,
+			Usage:  "a `NAME=VALUE` template variable, overriding the environment and config files, can be repeated",
+			EnvVar: "KD_SET,PLUGIN_KD_SET",
+		},
+		cli.StringSliceFlag{
+			Name:   FlagLabel,
+			Usage:  "a `KEY=VALUE` label added to every resource e.g. a git sha or team, can be repeated",
+			EnvVar: "KD_LABEL,PLUGIN_KD_LABEL",
+		},
+		cli.StringSliceFlag{
+			Name:   FlagAnnotate,
+			Usage:  "a `KEY=VALUE` annotation added to every resource e.g. a build number, can be repeated",
+			EnvVar: "KD_ANNOTATE,PLUGIN_KD_ANNOTATE",
+		},
+		cli.BoolFlag{
+			Name:   FlagLabelPodTemplates,
+			Usage:  "if true, the --label labels and --annotate annotations are also added to the pod templates of workloads (changing them rolls out new pods)",
+			EnvVar: "KD_LABEL_POD_TEMPLATES,PLUGIN_KD_LABEL_POD_TEMPLATES",
+		},
+		cli.StringFlag{
+			Name:   FlagExportEnv,
+			Usage:  "after a deploy, write the generated names, service addresses, ingress hosts and release revision to `FILE` as dotenv (or json for a .json file)",
+			EnvVar: "KD_EXPORT_ENV,PLUGIN_KD_EXPORT_ENV",
+		},
+		cli.StringFlag{
+			Name:   FlagPostDeployHook,
+			Usage:  "a `COMMAND` run after a successful deploy, given the final name, namespace, uid and revision of each resource as json on stdin",
+			EnvVar: "KD_POST_DEPLOY_HOOK,PLUGIN_KD_POST_DEPLOY_HOOK",
+		},
+		cli.BoolFlag{
+			Name:   FlagResumePaused,
+			Usage:  "if true, Deployments with spec.paused are resumed after they are applied and watched, otherwise they aren't watched",
+			EnvVar: "KD_RESUME_PAUSED,PLUGIN_KD_RESUME_PAUSED",
+		},
+		cli.BoolFlag{
+			Name:   FlagPreserveReplicas,
+			Usage:  "if true, workloads without spec.replicas (e.g. scaled by an autoscaler) keep the replicas they are running with when applied",
+			EnvVar: "KD_PRESERVE_REPLICAS,PLUGIN_KD_PRESERVE_REPLICAS",
+		},
+		cli.StringFlag{
+			Name:   FlagNamePrefix,
+			Usage:  "a `PREFIX` added to the name of every resource and the references between them",
+			EnvVar: "KD_NAME_PREFIX,PLUGIN_KD_NAME_PREFIX",
+		},
+		cli.StringFlag{
+			Name:   FlagNameSuffix,
+			Usage:  "a `SUFFIX` added to the name of every resource and the references between them, e.g. -pr123",
+			EnvVar: "KD_NAME_SUFFIX,PLUGIN_KD_NAME_SUFFIX",
+		},
+		cli.StringSliceFlag{
+			Name:   FlagDerive,
+			Usage:  "a template variable `NAME=EXPRESSION` computed from others e.g. 'IMAGE=${REGISTRY}/${APP | lower}:${TAG}', can be repeated",
+			EnvVar: "KD_DERIVE,PLUGIN_KD_DERIVE",
+		},
+		cli.StringFlag{
+			Name:   FlagProject,
+			Usage:  "the project `FILE` setting the defaults for the files, namespace, context, config and variables of each environment",
+			Value:  DefaultProjectFile,
+			EnvVar: "KD_PROJECT,PLUGIN_KD_PROJECT",
+		},
+		cli.StringFlag{
+			Name:   FlagEnv,
+			Usage:  "the `NAME` of the environment in the project file to deploy to",
+			EnvVar: "KD_ENV,PLUGIN_KD_ENV",
+		},
+		cli.DurationFlag{
+			Name:   FlagTTL,
+			Usage:  "mark the resources (or environment) as expired after `TTL`, see the reap command",
+			EnvVar: "KD_TTL,PLUGIN_KD_TTL",
+		},
 	}
 	app.Commands = []cli.Command{
 		{
@@ -234,34 +903,232 @@ func main() {
 			SkipFlagParsing: true,
 			OnUsageError:    nil,
 		},
+		{
+			Action:      exitOnError(run),
+			Name:        "deploy",
+			Usage:       "deploy [kd flags] - renders and deploys the resources, the same as kd without a command",
+			Description: "renders the resources and deploys them, waiting for each to complete",
+			UsageText:   "deploy --env production",
+			Flags:       app.Flags,
+		},
+		{
+			Action:      exitOnError(diff),
+			Name:        "diff",
+			Usage:       "diff [kd flags] - renders the resources and shows what would change in the cluster",
+			Description: "renders the resources and compares them with the cluster, exiting non-zero when changes exist",
+			UsageText:   "diff -f PATH [-- kubectl args] - will show the changes for each resource",
+			Flags: withFlags(app.Flags,
+				cli.StringFlag{
+					Name:   FlagDiffFormat,
+					Usage:  "how the changes are shown, `FORMAT` unified (kubectl diff), json-patch or semantic (ignoring list order and server defaults)",
+					Value:  "unified",
+					EnvVar: "KD_DIFF_FORMAT,PLUGIN_KD_DIFF_FORMAT",
+				},
+			),
+		},
+		{
+			Action:      exitOnError(resume),
+			Name:        "resume",
+			Usage:       "resume --state-file PATH [kd flags] - continues an interrupted release",
+			Description: "deploys the resources which were not completed by the run which recorded the state file",
+			Flags:       app.Flags,
+		},
+		{
+			Action:      exitOnError(promote),
+			Name:        "promote",
+			Usage:       "promote --from CONTEXT[/NAMESPACE] --to CONTEXT[/NAMESPACE] -f PATH [kd flags] - deploys the images running in one environment to another",
+			Description: "renders the resources for the target environment with each container image pinned to the digest running in the source environment and deploys them",
+			UsageText:   "promote --from staging --to prod -f ./kube",
+			Flags: withFlags(app.Flags,
+				cli.StringFlag{
+					Name:   FlagPromoteFrom,
+					Usage:  "the `CONTEXT[/NAMESPACE]` to read the running image digests from",
+					EnvVar: "KD_PROMOTE_FROM,PLUGIN_KD_PROMOTE_FROM",
+				},
+				cli.StringFlag{
+					Name:   FlagPromoteTo,
+					Usage:  "the `CONTEXT[/NAMESPACE]` to deploy to",
+					EnvVar: "KD_PROMOTE_TO,PLUGIN_KD_PROMOTE_TO",
+				},
+			),
+		},
+		{
+			Action:      exitOnError(rollback),
+			Name:        "rollback",
+			Usage:       "rollback --release NAME [--revision N] [kd flags] - deploys the manifests of an earlier revision of the release again, after previewing the changes",
+			Description: "reads the manifests recorded for a revision of the release (the one before the last successful deploy by default), shows how the cluster would change (including resources created, recreated or deleted) and deploys them once confirmed",
+			UsageText:   "rollback --release api [--revision 3] [--yes]",
+			Flags: withFlags(app.Flags,
+				cli.BoolFlag{
+					Name:   FlagYes,
+					Usage:  "roll back without asking for confirmation, which is only asked when run interactively",
+					EnvVar: "KD_YES,PLUGIN_KD_YES",
+				},
+				cli.IntFlag{
+					Name:   FlagRevision,
+					Usage:  "the `REVISION` to roll back to, the one before the last successful deploy by default",
+					EnvVar: "KD_REVISION,PLUGIN_KD_REVISION",
+				},
+			),
+		},
+		{
+			Action:      exitOnError(simulate),
+			Name:        "simulate",
+			Usage:       "simulate --status-fixtures DIR -f PATH [kd flags] - watches the resources using recorded statuses instead of a cluster",
+			Description: "renders the resources and feeds the statuses recorded for each (in DIR/kind-name.yaml) to the watch, reporting if it would complete, fail or time out",
+			UsageText:   "simulate --status-fixtures ./fixtures -f ./kube",
+			Flags: withFlags(app.Flags,
+				cli.StringFlag{
+					Name:   FlagStatusFixtures,
+					Usage:  "the `DIR` of recorded statuses, a file kind-name.yaml of yaml documents for each resource, one for each check interval",
+					EnvVar: "KD_STATUS_FIXTURES,PLUGIN_KD_STATUS_FIXTURES",
+				},
+			),
+		},
+		{
+			Action:      exitOnError(renderManifests),
+			Name:        "render",
+			Usage:       "render -f PATH [kd flags] - renders the resources and writes them to stdout",
+			Description: "renders the resources in the same way as a deploy, without using the cluster, and writes the manifests to stdout",
+			Flags:       app.Flags,
+		},
+		{
+			Action:      exitOnError(evalTemplate),
+			Name:        "eval",
+			Usage:       "eval EXPRESSION [kd flags] - renders a single template expression with the config data",
+			Description: "renders a template expression using the same config data and functions as a deploy",
+			UsageText:   "eval '{{ .REPLICAS | default \"2\" }}' [--config-data Values=values.yaml]",
+			Flags:       app.Flags,
+		},
+		{
+			Action:      exitOnError(batch),
+			Name:        "batch",
+			Usage:       "batch JOBS_FILE [kd flags] - runs a deploy for each job in a file and reports the results",
+			Description: "runs kd for each job (files, values, namespace and context) in turn or in parallel, the kd flags apply to every job",
+			UsageText:   "batch [--batch-parallel N] jobs.yaml",
+			Flags: withFlags(app.Flags,
+				cli.IntFlag{
+					Name:   FlagBatchParallel,
+					Usage:  "the `NUMBER` of batch jobs to run at the same time",
+					Value:  1,
+					EnvVar: "KD_BATCH_PARALLEL,PLUGIN_KD_BATCH_PARALLEL",
+				},
+			),
+		},
+		{
+			Name:  "env",
+			Usage: "env create|destroy - manages short lived environments e.g. for reviewing changes",
+			Subcommands: []cli.Command{
+				{
+					Action:      exitOnError(envCreate),
+					Name:        "create",
+					Usage:       "create --name NAME [kd flags] - creates a namespace and deploys the resources into it",
+					Description: "creates (or updates) an environment namespace, marks it with any ttl and deploys the resources",
+					Flags: withFlags(app.Flags,
+						cli.StringFlag{
+							Name:   FlagEnvName,
+							Usage:  "the environment `NAME`, also used as the namespace",
+							EnvVar: "KD_ENV_NAME,PLUGIN_KD_ENV_NAME",
+						},
+					),
+				},
+				{
+					Action:      exitOnError(envDestroy),
+					Name:        "destroy",
+					Usage:       "destroy --name NAME [kd flags] - deletes an environment namespace and everything in it",
+					Description: "deletes an environment namespace which was created by kd env create",
+					Flags: withFlags(app.Flags,
+						cli.StringFlag{
+							Name:   FlagEnvName,
+							Usage:  "the environment `NAME`, also used as the namespace",
+							EnvVar: "KD_ENV_NAME,PLUGIN_KD_ENV_NAME",
+						},
+					),
+				},
+			},
+		},
+		{
+			Action:      exitOnError(adopt),
+			Name:        "adopt",
+			Usage:       "adopt kind/name... --release NAME [kd flags] - marks existing resources as managed by a kd release",
+			Description: "labels and annotates existing resources so they are managed by a kd release without being recreated",
+			UsageText:   "adopt --release NAME kind/name [kind/name...]",
+			Flags:       app.Flags,
+		},
+		{
+			Action:      exitOnError(importResources),
+			Name:        "import",
+			Usage:       "import kind/name... [kd flags] - exports live resources as kd manifests",
+			Description: "fetches live resources, strips server populated fields and writes manifests with suggested template variables",
+			UsageText:   "import [--output-dir DIR] kind/name [kind/name...]",
+			Flags:       app.Flags,
+		},
+		{
+			Action:      exitOnError(reap),
+			Name:        "reap",
+			Usage:       "reap [kd flags] - deletes kd managed resources and environments which have expired",
+			Description: "deletes resources labelled as managed by kd which have an expiry (see --ttl) in the past",
+			Flags: withFlags(app.Flags,
+				cli.StringFlag{
+					Name:   FlagReapKinds,
+					Usage:  "the comma separated `KINDS` of resources to check for expiry",
+					Value:  DefaultKinds,
+					EnvVar: "KD_REAP_KINDS,PLUGIN_KD_REAP_KINDS",
+				},
+			),
+		},
 	}
 
-	app.Action = func(cx *cli.Context) error {
-		if err := run(cx); err != nil {
+	app.Action = exitOnError(run)
+	defer cleanup()
+	if err := app.Run(os.Args); err != nil {
+		logError.Fatal(err)
+	}
+}
+
+// exitOnError will log any error from an action and exit non-zero
+func exitOnError(action func(*cli.Context) error) func(*cli.Context) error {
+	return func(cx *cli.Context) error {
+		if err := setLogFormat(cx.String(FlagLogFormat)); err != nil {
+			logError.Print(err)
+			return cli.NewExitError("", 1)
+		}
+		if err := setLogLevel(cx.String(FlagLogLevel), cx.Bool(FlagQuiet)); err != nil {
+			logError.Print(err)
+			return cli.NewExitError("", 1)
+		}
+		if err := setLogGroups(cx.String(FlagLogGroups)); err != nil {
+			logError.Print(err)
+			return cli.NewExitError("", 1)
+		}
+		if err := applyProject(cx); err != nil {
+			logError.Print(err)
+			return cli.NewExitError("", 1)
+		}
+		workspaceRoot = cx.String(FlagWorkspace)
+		if err := action(cx); err != nil {
 			logError.Print(err)
+			// Exiting skips the deferred cleanup
+			cleanup()
 			return cli.NewExitError("", 1)
 		}
 
 		return nil
 	}
-	defer cleanup()
-	if err := app.Run(os.Args); err != nil {
-		logError.Fatal(err)
-	}
 }
 
-// Delete any temparay files
-func cleanup() {
-	if len(tmpDir) > 0 {
-		logDebug.Printf("cleaning up %s", tmpDir)
-		os.RemoveAll(tmpDir)
-	}
+// withFlags returns a copy of the flags with any extra command flags added
+func withFlags(flags []cli.Flag, extra ...cli.Flag) []cli.Flag {
+	all := make([]cli.Flag, 0, len(flags)+len(extra))
+	all = append(all, flags...)
+	return append(all, extra...)
 }
 
 func runKubectl(c *cli.Context) error {
 	if c.Parent().Bool("debug") {
 		logDebug = logDebugIf
 	}
+	workspaceRoot = c.Parent().String(FlagWorkspace)
 	if c.Parent().IsSet(FlagCreateOnlyResources) {
 		if len(c.Parent().StringSlice(FlagCreateOnlyResources)) > 1 {
 			return fmt.Errorf("can only specify a single resource when using run")
@@ -274,7 +1141,7 @@ func runKubectl(c *cli.Context) error {
 		}
 		name := resParts[1]
 		kind := resParts[0]
-		exists, err := checkResourceExist(c.Parent(), &ObjectResource{
+		exists, err := NewK8ApiKubectl(c.Parent()).Exists(&ObjectResource{
 			Kind: kind,
 			ObjectMeta: ObjectMeta{
 				Name: name,
@@ -314,30 +1181,243 @@ func run(c *cli.Context) error {
 	if c.Bool("debug") {
 		logDebug = logDebugIf
 	}
+	if err := checkWatchEngine(c.String(FlagWatchEngine)); err != nil {
+		return err
+	}
+	if c.Bool(FlagNoWait) && c.Bool(FlagTestCronJob) {
+		return fmt.Errorf("--%s can't clean up the jobs it creates with --%s", FlagTestCronJob, FlagNoWait)
+	}
+	var resources []*ObjectResource
+	var err error
+	if rollingBack {
+		resources, err = rollbackResources(c)
+	} else {
+		resources, err = renderResources(c)
+	}
+	if err != nil {
+		return err
+	}
+	if promoteFrom != nil {
+		if err := promoteImages(c, resources, *promoteFrom); err != nil {
+			return err
+		}
+		if err := validateImagePolicy(c, resources); err != nil {
+			return err
+		}
+	}
+	// Only perform deploy if dry-run is not set to true
+	if dryRun || c.IsSet(FlagDebugRender) {
+		if c.IsSet(FlagOutputDir) {
+			return writeManifests(c.String(FlagOutputDir), resources)
+		}
+		return nil
+	}
+	if c.IsSet(FlagRelease) {
+		if err := markRelease(resources, c.String(FlagRelease)); err != nil {
+			return err
+		}
+	}
+	if c.IsSet(FlagTTL) {
+		if err := markExpiry(resources, c.Duration(FlagTTL)); err != nil {
+			return err
+		}
+	}
+	if c.Bool(FlagPrune) {
+		if !c.IsSet(FlagPruneSelector) {
+			return fmt.Errorf("a selector must be specified with --%s to prune resources", FlagPruneSelector)
+		}
+		labels, err := parseSelector(c.String(FlagPruneSelector))
+		if err != nil {
+			return err
+		}
+		if err := markPrune(resources, labels); err != nil {
+			return err
+		}
+	}
+	if rollingBack {
+		if err := previewRollback(c, resources); err != nil {
+			return err
+		}
+	}
+	// Write the resources exactly as they are deployed
+	if c.IsSet(FlagOutputDir) {
+		if err := writeManifests(c.String(FlagOutputDir), resources); err != nil {
+			return err
+		}
+	}
+	if c.Bool(FlagValidateScheduling) {
+		if err := validateScheduling(c, resources); err != nil {
+			return err
+		}
+	}
+	if c.Bool(FlagValidatePodSecurity) || len(c.StringSlice(FlagNetworkDependency)) > 0 {
+		if err := validateAdmission(c, resources); err != nil {
+			return err
+		}
+	}
+	if c.Bool(FlagValidateStorage) {
+		if err := validateStorage(c, resources); err != nil {
+			return err
+		}
+	}
+	if c.Bool(FlagValidatePlatforms) {
+		if err := validatePlatforms(c, resources); err != nil {
+			return err
+		}
+	}
+	if _, err := kindPlugins(c.StringSlice(FlagKindPlugin)); err != nil {
+		return err
+	}
+	if err := waitForGates(c.StringSlice(FlagWaitForURL), c.StringSlice(FlagWaitForTCP),
+		c.Duration(FlagWaitForTimeout), c.Duration("check-interval")); err != nil {
+		return err
+	}
+	if c.Bool(FlagCreateNamespace) && !c.Bool(FlagDelete) {
+		if err := createNamespace(c, resources); err != nil {
+			return err
+		}
+	}
+	if c.IsSet(FlagStateFile) {
+		if deployState, err = loadState(c.String(FlagStateFile), resuming); err != nil {
+			return err
+		}
+	}
+	if interval := c.Duration(FlagHeartbeat); interval > 0 {
+		defer startHeartbeat(interval)()
+	}
+	start := time.Now()
+	if err := deployAll(c, resources, c.Int(FlagConcurrency)); err != nil {
+		return err
+	}
+	if err := watchPending(c); err != nil {
+		return err
+	}
+	if c.Bool(FlagRestartDependents) && !c.Bool(FlagDelete) {
+		if err := restartDependents(c, resources); err != nil {
+			return err
+		}
+	}
+	if c.Bool(FlagPrune) && !c.Bool(FlagDelete) {
+		if err := prune(c, resources); err != nil {
+			return err
+		}
+	}
+	if rollingBack {
+		if err := deleteRolledBack(c); err != nil {
+			return err
+		}
+	}
+	if c.IsSet(FlagRelease) && !c.Bool(FlagDelete) {
+		if err := recordRelease(c, resources); err != nil {
+			return err
+		}
+	}
+	if c.IsSet(FlagExportEnv) && !c.Bool(FlagDelete) {
+		if err := exportEnv(c, resources); err != nil {
+			return err
+		}
+	}
+	if (c.IsSet(FlagPostDeployHook) || c.IsSet(FlagNotes)) && !c.Bool(FlagDelete) {
+		results, err := deployResults(c, resources)
+		if err != nil {
+			return err
+		}
+		renderConf = withResults(renderConf, results)
+		if c.IsSet(FlagPostDeployHook) {
+			if err := runPostDeployHook(c, c.String(FlagPostDeployHook), results); err != nil {
+				return err
+			}
+		}
+	}
+	action := "deployed"
+	if c.Bool(FlagDelete) {
+		action = "deleted"
+	}
+	logSummary.Printf("%s %d resources in %s", action, len(resources)-skippedResources.resourceCount(), time.Since(start).Round(time.Second))
+	if skippedResources.count() > 0 {
+		logSummary.Printf("skipped %d resources and files:%s", skippedResources.count(), skippedResources.report())
+	}
+	if c.IsSet(FlagNotes) && !c.Bool(FlagDelete) {
+		if err := printNotes(c, c.String(FlagNotes)); err != nil {
+			return err
+		}
+	}
+	return deployState.finish()
+}
+
+// renderResources will render all the files specified and return the resources
+func renderResources(c *cli.Context) ([]*ObjectResource, error) {
 	// Check we have some files to process
-	if len(c.StringSlice("file")) == 0 {
-		return errors.New("no kubernetes resource files specified")
+	if len(c.StringSlice("file")) == 0 && len(c.StringSlice(FlagKustomize)) == 0 && !c.IsSet(FlagChart) && !c.IsSet(FlagGitRepo) {
+		return nil, errors.New("no kubernetes resource files specified")
 	}
 
 	// Get config data from env or files
 	conf, err := GetAnyConfigData(c)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	previous, err := previousRelease(c)
+	if err != nil {
+		return nil, err
 	}
+	if m, ok := conf.(map[string]interface{}); ok {
+		releaseValues = m["Values"]
+	}
+	releaseRevision = previous["Revision"].(int) + 1
+	conf = withPrevious(conf, previous)
+	renderConf = conf
 
 	// Check if all files exist first - fail early on building up a list of files
-	var files []string
-	for _, fn := range c.StringSlice("file") {
+	var names, files []string
+	fileNames := c.StringSlice("file")
+	if c.IsSet(FlagGitRepo) {
+		path, err := gitRepoManifests(c.String(FlagGitRepo), c.String(FlagGitRef), c.String(FlagGitPath))
+		if err != nil {
+			return nil, err
+		}
+		fileNames = append([]string{path}, fileNames...)
+	}
+	for _, fn := range fileNames {
+		if isRemoteManifest(fn) {
+			if isJsonnet(fn) {
+				return nil, fmt.Errorf("jsonnet can't be read from a url, file:%q", fn)
+			}
+			name, err := fetchRemoteManifest(fn, c.StringSlice(FlagURLHeader))
+			if err != nil {
+				return nil, err
+			}
+			names = append(names, name)
+			continue
+		}
+		matches, err := expandFileGlob(fn)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, matches...)
+	}
+	for _, fn := range names {
+		if fn == stdinFile {
+			if contains(files, stdinFile) {
+				return nil, errors.New("stdin can only be given once with --file -")
+			}
+			files = append(files, fn)
+			continue
+		}
+		if _, fetched := remoteManifests[fn]; fetched {
+			files = append(files, fn)
+			continue
+		}
 		logDebug.Printf("about to open file:%s\n", fn)
 		stat, err := os.Stat(fn)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		switch stat.IsDir() {
 		case true:
-			fileList, err := ListDirectory(fn)
+			fileList, err := ListDirectory(fn, c.StringSlice(FlagExclude)...)
 			if err != nil {
-				return err
+				return nil, err
 			}
 			files = append(files, fileList...)
 		default:
@@ -348,52 +1428,149 @@ func run(c *cli.Context) error {
 	if c.IsSet(FlagAllowMissing) {
 		allowMissingVariables = true
 	}
+	if c.IsSet(FlagReproducible) {
+		reproducible = true
+	}
+	if c.IsSet(FlagTemplateDelims) {
+		if leftDelim, rightDelim, err = parseDelims(c.String(FlagTemplateDelims)); err != nil {
+			return nil, err
+		}
+	}
+	if c.IsSet(FlagRenderTimeout) {
+		renderDeadline = time.Now().Add(c.Duration(FlagRenderTimeout))
+	}
+	var debugFile string
+	var debugLine int
+	if c.IsSet(FlagDebugRender) {
+		debugRenderAt = c.String(FlagDebugRender)
+		if debugFile, debugLine, err = parseDebugRender(debugRenderAt); err != nil {
+			return nil, err
+		}
+	}
 
+	resources, err := chartResources(c, conf)
+	if err != nil {
+		return nil, err
+	}
+	built, err := kustomizeResources(c, conf)
+	if err != nil {
+		return nil, err
+	}
+	resources = append(resources, built...)
 	// Iterate the list of files and add rendered templates to resources list - fail early.
-	resources := []*ObjectResource{}
 	for _, fn := range files {
 		logDebug.Printf("parsing file:%s\n", fn)
-		data, err := ioutil.ReadFile(fn)
+		if isJsonnet(fn) {
+			out, err := evaluateJsonnet(fn, EnvToMap())
+			if err != nil {
+				return nil, err
+			}
+			items, err := jsonnetResources(out)
+			if err != nil {
+				return nil, fmt.Errorf("invalid output from jsonnet for file:%q: %s", fn, err)
+			}
+			for _, item := range items {
+				resources = append(resources, &ObjectResource{FileName: fn, Template: []byte(item)})
+			}
+			continue
+		}
+		data, err := readManifest(fn)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		// Normalise line endings so output is the same whichever platform the files came from
+		data = bytes.Replace(data, []byte("\r\n"), []byte("\n"), -1)
+		if filepath.Clean(fn) == debugFile {
+			if data, err = insertDebugMarker(data, debugLine); err != nil {
+				return nil, err
+			}
 		}
-		for _, d := range splitYamlDocs(string(data)) {
+		docs := splitYamlDocs(string(data))
+		if isJSONManifest(fn) {
+			// A json manifest is a single document, which can be a List of resources
+			docs = []string{string(data)}
+		}
+		for i, d := range docs {
 			var k8api K8Api
 			if dryRun {
 				k8api = NewK8ApiNoop()
 			} else {
 				k8api = NewK8ApiKubectl(c)
 			}
+			templateFile = fn
 			rendered, genSecret, err := Render(k8api, string(d), conf)
 			if err != nil {
-				return err
+				return nil, err
+			}
+			if !renderDeadline.IsZero() && time.Now().After(renderDeadline) {
+				return nil, fmt.Errorf("rendering file:%q took longer than the render timeout of %s",
+					fn, c.Duration(FlagRenderTimeout))
 			}
-			r := &ObjectResource{
-				FileName:   fn,
-				Template:   []byte(rendered),
-				CreateOnly: genSecret,
+			items := []string{rendered}
+			if isJSONManifest(fn) {
+				if items, err = splitJSONList(rendered); err != nil {
+					return nil, fmt.Errorf("invalid json in file:%q: %s", fn, err)
+				}
+			}
+			for _, item := range items {
+				if err := checkStrictYaml([]byte(item)); err != nil {
+					return nil, fmt.Errorf("invalid yaml in document %d of file:%q (line numbers are of the rendered document): %s", i+1, fn, err)
+				}
+				resources = append(resources, &ObjectResource{
+					FileName:   fn,
+					Template:   []byte(item),
+					CreateOnly: genSecret,
+				})
 			}
-			resources = append(resources, r)
 		}
 	}
+	if err := addFlagMetadata(c, resources); err != nil {
+		return nil, err
+	}
+	if err := renameResources(resources, c.String(FlagNamePrefix), c.String(FlagNameSuffix)); err != nil {
+		return nil, err
+	}
 	for _, r := range resources {
 		if c.Bool("debug-templates") {
 			logInfo.Printf("Template:\n" + string(r.Template[:]))
 		}
 		if err := yaml.Unmarshal(r.Template, &r); err != nil {
-			return err
+			return nil, err
 		}
 		// Add any flag specific settings for resources
 		updateResFromFlags(c, r)
-
-		// Only perform deploy if dry-run is not set to true
-		if !dryRun {
-			if err := deploy(c, r); err != nil {
-				return err
-			}
+	}
+	if err := checkDuplicates(resources, c.String("namespace")); err != nil {
+		return nil, err
+	}
+	if c.Bool(FlagValidate) {
+		if err := validateSchemas(c, resources); err != nil {
+			return nil, err
 		}
 	}
-	return nil
+	// Promoted images are checked once they are pinned to the digests promoted
+	if promoteFrom == nil {
+		if err := validateImagePolicy(c, resources); err != nil {
+			return nil, err
+		}
+	}
+	if c.Bool(FlagValidateProbes) {
+		if err := validateProbes(c, resources); err != nil {
+			return nil, err
+		}
+	}
+	if !c.Bool(FlagFileOrder) {
+		sortResources(resources, c.Bool(FlagDelete))
+	}
+	if resources, err = orderDependencies(resources, c.Bool(FlagDelete)); err != nil {
+		return nil, err
+	}
+	if c.Bool(FlagValidateReferences) {
+		if err := validateReferences(c, resources); err != nil {
+			return nil, err
+		}
+	}
+	return resources, nil
 }
 
 // GetAnyConfigData get config data from env or files
@@ -401,17 +1578,24 @@ func GetAnyConfigData(c *cli.Context) (interface{}, error) {
 	// Make a map we can use:
 	confMap := make(map[string]interface{})
 	var conf interface{}
+	sets, err := setVariables(c.StringSlice(FlagSet))
+	if err != nil {
+		return nil, err
+	}
 	if c.IsSet("config") {
 		if c.IsSet(FlagConfigData) {
 			return nil, fmt.Errorf("cannot set %s if --config flag is set", FlagConfigData)
 		}
 		// Load Environment file overrides into the OS Environment Scope
-		err := godotenv.Load(c.String("config"))
+		err := loadDotenv(c.StringSlice("config"))
 		if err != nil {
 			return nil, fmt.Errorf("Error loading .env file:%s", err)
 		}
 		// Now get any environment data (as set from above)
 	}
+	if err := setDerivedValues(c.StringSlice(FlagDerive)); err != nil {
+		return nil, err
+	}
 	// Copy environment to new untyped map:
 	for k, v := range EnvToMap() {
 		confMap[k] = v
@@ -421,10 +1605,10 @@ func GetAnyConfigData(c *cli.Context) (interface{}, error) {
 		fields := strings.Split(cd, "=")
 		switch len(fields) {
 		case 1:
-			if len(c.StringSlice(FlagConfigData)) > 1 {
+			if len(c.StringSlice(FlagConfigData)) > 1 || c.IsSet(FlagValues) {
 				return nil, fmt.Errorf(
-					"only support a single unscoped %s or multiple scoped entries",
-					FlagConfigData)
+					"only support a single unscoped %s (without --%s) or multiple scoped entries",
+					FlagConfigData, FlagValues)
 			}
 			var err error
 			// --flag file.yaml
@@ -432,6 +1616,11 @@ func GetAnyConfigData(c *cli.Context) (interface{}, error) {
 			if err != nil {
 				return nil, err
 			}
+			if confTyped, ok := conf.(map[interface{}]interface{}); ok {
+				for k, v := range sets {
+					confTyped[k] = v
+				}
+			}
 			// Only support a single top scoped data item today
 			return conf, nil
 		case 2:
@@ -440,25 +1629,47 @@ func GetAnyConfigData(c *cli.Context) (interface{}, error) {
 			if err != nil {
 				return nil, err
 			}
-			// Update the scoped data:
-			confMap[fields[0]] = scopedConf
+			// Update the scoped data, layering over any earlier data for the scope:
+			confMap[fields[0]] = mergeValues(confMap[fields[0]], scopedConf)
 		default:
 			return nil, fmt.Errorf(
 				"%s flag cannot be parsed; data.yaml or scope=data.yaml expected",
 				cd)
 		}
 	}
+	for _, fn := range c.StringSlice(FlagValues) {
+		values, err := GetConfigData(fn, false)
+		if err != nil {
+			return nil, err
+		}
+		confMap["Values"] = mergeValues(confMap["Values"], values)
+	}
 	// Cast the typed map to generic interface
 	conf = confMap
 	return conf, nil
 }
 
+// setVariables sets the --set template variables in the environment, so they take
+// precedence over the config files and any existing environment variables
+func setVariables(list []string) (map[string]string, error) {
+	sets, err := parseKeyValues(FlagSet, list)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range sets {
+		if err := os.Setenv(k, v); err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %s", FlagSet, k, err)
+		}
+	}
+	return sets, nil
+}
+
 // GetConfigData gets data from a file
 func GetConfigData(f string, mergeEnv bool) (interface{}, error) {
 	var conf interface{}
 	// First load any env data from files
 	logDebug.Printf("Loading config file:%s\n", f)
-	b, err := ioutil.ReadFile(f)
+	b, err := readConfigFile(f, sopsYaml)
 	if err != nil {
 		return nil, fmt.Errorf("error reading file '%s':%s", f, err)
 	}
@@ -483,6 +1694,26 @@ func GetConfigData(f string, mergeEnv bool) (interface{}, error) {
 	return conf, nil
 }
 
+// mergeValues layers the overlay values over the base, merging nested maps
+func mergeValues(base, overlay interface{}) interface{} {
+	baseMap, ok := base.(map[interface{}]interface{})
+	if !ok {
+		return overlay
+	}
+	overlayMap, ok := overlay.(map[interface{}]interface{})
+	if !ok {
+		return overlay
+	}
+	merged := make(map[interface{}]interface{}, len(baseMap))
+	for k, v := range baseMap {
+		merged[k] = v
+	}
+	for k, v := range overlayMap {
+		merged[k] = mergeValues(merged[k], v)
+	}
+	return merged
+}
+
 // EnvToMap - creates a map of all environment variables
 func EnvToMap() map[string]string {
 	m := map[string]string{}
@@ -537,18 +1768,18 @@ func deploy(c *cli.Context, r *ObjectResource) error {
 	exists := false
 	if r.CreateOnly || c.Bool(FlagReplace) || c.Bool(FlagDelete) {
 		var err error
-		exists, err = checkResourceExist(c, r)
+		exists, err = NewK8ApiKubectl(c).Exists(r)
 		if err != nil {
 			return fmt.Errorf("problem checking if resource %s/%s exists", r.Kind, r.Name)
 		}
 
 		if r.CreateOnly && exists {
-			log.Printf("skipping deploy for resource (%s/%s) marked as create only.", r.Kind, r.Name)
+			skippedResources.skip(r, "marked as create only and already exists")
 			return nil
 		}
 
 		if c.Bool(FlagDelete) && !exists {
-			log.Printf("skipping delete for resource (%s/%s) as it does not exist.", r.Kind, r.Name)
+			skippedResources.skip(r, "can't be deleted as it does not exist")
 			return nil
 		}
 	}
@@ -575,51 +1806,81 @@ func deploy(c *cli.Context, r *ObjectResource) error {
 		command = "create"
 	}
 
-	logDebug.Printf("%s resource %s/%s (from file:%q)", action, r.Kind, name, r.FileName)
-	args := []string{command, "-f", "-"}
-	cmd, err := newKubeCmd(c, args, true)
-	if err != nil {
-		return err
+	if c.Bool(FlagPreserveReplicas) && command != "delete" {
+		if err := preserveReplicas(c, r); err != nil {
+			return err
+		}
 	}
 
-	if c.Bool("debug") {
-		logDebug.Printf("kubectl arguments: %q", strings.Join(cmd.Args, " "))
+	logDebug.Printf("%s resource %s/%s (from file:%q)", action, r.Kind, name, r.FileName)
+	args := []string{command, "-f", "-"}
+	if command == "apply" {
+		serverSide, err := serverSideArgs(c)
+		if err != nil {
+			return err
+		}
+		args = append(args, serverSide...)
 	}
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return err
+	if command != "delete" {
+		validation, err := validationArgs(c)
+		if err != nil {
+			return err
+		}
+		args = append(args, validation...)
 	}
-
-	var outbuf, errbuf bytes.Buffer
-	cmd.Stdout = &outbuf
-	cmd.Stderr = &errbuf
-
-	go func() {
-		defer stdin.Close()
-		stdin.Write(r.Template)
-	}()
-
 	logInfo.Printf("%s %s/%s", action, strings.ToLower(r.Kind), r.Name)
-	if err = cmd.Run(); err != nil {
-		if errbuf.Len() > 0 {
-			return fmt.Errorf(errbuf.String())
-		}
+	out, err := applyWithRetries(c, r, args)
+	if err != nil {
 		return err
 	}
-	logInfo.Print(outbuf.String())
+	logInfo.Print(out)
+	r.Changed = !strings.HasSuffix(strings.TrimSpace(out), " unchanged")
 
 	if r.GenerateName != "" {
 		//This gets the generated resource name from the output
-		resourceName := strings.TrimSuffix(outbuf.String(), " created\n")
+		resourceName := strings.TrimSuffix(out, " created\n")
 		r.Name = strings.Split(resourceName, "/")[1]
 	}
 
-	if !c.Bool(FlagDelete) && isWatchableResouce(r) {
-		return watchResource(c, r)
+	if isPaused(r) && c.Bool(FlagResumePaused) && !c.Bool(FlagDelete) {
+		if err := resumeRollout(c, r); err != nil {
+			return err
+		}
+	}
+
+	plugins, err := kindPlugins(c.StringSlice(FlagKindPlugin))
+	if err != nil {
+		return err
+	}
+	if command, found := plugins[r.Kind]; found && !c.Bool(FlagDelete) {
+		return runKindPlugin(c, r, command)
+	}
+	if !c.Bool(FlagDelete) && isWatchableResouce(r) && !c.Bool(FlagNoWait) {
+		if c.Bool(FlagWatchAfterApply) && canDeferWatch(r) {
+			deferWatch(r)
+			return nil
+		}
+		return watchWorkload(c, r)
+	}
+	if !c.Bool(FlagDelete) && r.Kind == "CronJob" && (c.Bool(FlagTriggerCronJob) || c.Bool(FlagTestCronJob)) {
+		return triggerCronJob(c, r, c.Bool(FlagTestCronJob))
 	}
 	return nil
 }
 
+// watchWorkload waits for a resource to complete using the --watch-engine
+func watchWorkload(c *cli.Context, r *ObjectResource) error {
+	defer trackWatch(r)()
+	if isPaused(r) {
+		logWarn.Printf("Deployment %q is paused, not watching it as it won't roll out until resumed (see --%s)", r.Name, FlagResumePaused)
+		return nil
+	}
+	if c.String(FlagWatchEngine) == "kubectl" && contains(rolloutKinds, r.Kind) && !isCustomResource(r) {
+		return watchRollout(c, r)
+	}
+	return watchResource(c, r)
+}
+
 func isWatchableResouce(r *ObjectResource) bool {
 	included := false
 	watchable := []string{"Deployment", "StatefulSet", "DaemonSet", "Job"}
@@ -629,16 +1890,23 @@ func isWatchableResouce(r *ObjectResource) bool {
 			break
 		}
 	}
-	return included
+	return included || isCustomResource(r)
 }
 
-func watchResource(c *cli.Context, r *ObjectResource) error {
+func watchResource(c *cli.Context, r *ObjectResource) (err error) {
+	defer func() {
+		if err != nil && !simulating {
+			dumpEvents(c, r)
+			dumpPodLogs(c, r, c.Int(FlagPodLogLines))
+		}
+	}()
 	if c.Bool("debug") {
-		logDebug.Printf("sleeping %d seconds before checking %s status for the first time", DeployDelaySeconds, r.Kind)
+		logDebug.Printf("sleeping %s before checking %s status for the first time", c.Duration(FlagDeployDelay), r.Kind)
 	}
-	time.Sleep(DeployDelaySeconds * time.Second)
+	time.Sleep(c.Duration(FlagDeployDelay))
 
-	if err := updateResourceStatus(c, r); err != nil {
+	api := statusAPI(c)
+	if err := api.UpdateStatus(r); err != nil {
 		return err
 	}
 
@@ -652,31 +1920,56 @@ func watchResource(c *cli.Context, r *ObjectResource) error {
 	}
 
 	ticker := time.NewTicker(c.Duration("check-interval"))
-	timeout := time.After(c.Duration("timeout"))
+	limit, err := watchTimeout(c, r)
+	if err != nil {
+		return err
+	}
+	timeout := time.After(limit)
 
 	og := r.DeploymentStatus.ObservedGeneration
+	extensions := 0
 	ready := false
 	var availableResourceCount int32
 	var unavailableResourceCount int32
+	var availableWhenExtended int32
 
 	for {
 		select {
 		case <-timeout:
-			return fmt.Errorf("%s rolling update %q timed out after %s", r.Kind, r.Name, c.Duration("timeout").String())
+			scalingUp, autoscaler := autoscalerStatus(c, r)
+			// Pods pending while nodes are added would be ready shortly after the timeout
+			if extension := c.Duration(FlagScaleUpTimeout); extension > 0 && extendForScaleUp(scalingUp, extensions, availableResourceCount, availableWhenExtended) {
+				if extensions == 0 {
+					logInfo.Printf("%s %q is waiting for nodes, extending the timeout by %s: %s\n", r.Kind, r.Name, extension, autoscaler)
+				} else {
+					logInfo.Printf("%s %q is still waiting for nodes, %d more objects are available (%d in all), extending the timeout by %s again: %s\n",
+						r.Kind, r.Name, availableResourceCount-availableWhenExtended, availableResourceCount, extension, autoscaler)
+				}
+				extensions++
+				availableWhenExtended = availableResourceCount
+				limit += extension
+				timeout = time.After(extension)
+				continue
+			}
+			return timeoutError(r, limit, autoscaler)
 		case <-ticker.C:
 			r.DeploymentStatus = DeploymentStatus{}
 
 			// Retry on error until max retries is met
-			for attempt := 0; attempt < MaxHealthcheckRetries; attempt++ {
-				if err := updateResourceStatus(c, r); err != nil {
+			retries := c.Int(FlagHealthCheckRetries)
+			if retries < 1 {
+				retries = 1
+			}
+			for attempt := 0; attempt < retries; attempt++ {
+				if err := api.UpdateStatus(r); err != nil {
 
 					// Return error on final try
-					if attempt == (MaxHealthcheckRetries - 1) {
+					if attempt == (retries - 1) {
 						return err
 					}
 
-					// Sleep between retries
-					time.Sleep(HealthCheckSleepDuration)
+					// Back off between retries
+					time.Sleep(jitter(retryDelay(c.Duration(FlagHealthCheckInterval), attempt)))
 
 				} else {
 					break
@@ -689,7 +1982,12 @@ func watchResource(c *cli.Context, r *ObjectResource) error {
 
 			ready = false
 
-			switch r.Kind {
+			kind := r.Kind
+			if isCustomResource(r) {
+				// Only the standard status conventions apply to custom resources
+				kind = ""
+			}
+			switch kind {
 			case "Deployment":
 				if (r.DeploymentStatus.UnavailableReplicas == 0 && r.DeploymentStatus.AvailableReplicas == r.DeploymentStatus.Replicas) &&
 					r.DeploymentStatus.Replicas == r.DeploymentStatus.UpdatedReplicas {
@@ -699,12 +1997,12 @@ func watchResource(c *cli.Context, r *ObjectResource) error {
 				unavailableResourceCount = r.DeploymentStatus.UnavailableReplicas
 
 			case "StatefulSet":
-				if (r.DeploymentStatus.ReadyReplicas == r.ObjectSpec.Replicas) &&
+				if (r.DeploymentStatus.ReadyReplicas == desiredReplicas(r)) &&
 					r.DeploymentStatus.CurrentRevision == r.DeploymentStatus.UpdateRevision {
 					ready = true
 				}
 				availableResourceCount = r.DeploymentStatus.ReadyReplicas
-				unavailableResourceCount = r.ObjectSpec.Replicas - r.DeploymentStatus.ReadyReplicas
+				unavailableResourceCount = desiredReplicas(r) - r.DeploymentStatus.ReadyReplicas
 
 			case "DaemonSet":
 				if (r.DeploymentStatus.DesiredNumberScheduled == r.DeploymentStatus.NumberAvailable) &&
@@ -720,13 +2018,39 @@ func watchResource(c *cli.Context, r *ObjectResource) error {
 					ready = true
 				}
 				unavailableResourceCount = 1
+
+			default:
+				complete, waiting, err := genericStatus(r)
+				if err != nil {
+					return err
+				}
+				if !complete {
+					logInfo.Printf("%s %q update in progress, %s\n", r.Kind, r.Name, waiting)
+					continue
+				}
+				logInfo.Printf("%s %q is ready\n", r.Kind, r.Name)
+				return nil
 			}
 
+			if ready && !simulating {
+				failures, err := probeWorkload(c, r)
+				if err != nil {
+					return err
+				}
+				if len(failures) > 0 {
+					logInfo.Printf("%s %q is available, waiting for probes: %s\n", r.Kind, r.Name, failures)
+					continue
+				}
+			}
 			if ready {
 				logInfo.Printf("%s %q is complete. Available objects: %d\n", r.Kind, r.Name, availableResourceCount)
 				return nil
 			}
-			logInfo.Printf("%s %q update in progress. Waiting for %d objects.\n", r.Kind, r.Name, unavailableResourceCount)
+			if progress := availabilityProgress(r); len(progress) > 0 {
+				logInfo.Printf("%s %q update in progress, %s\n", r.Kind, r.Name, progress)
+			} else {
+				logInfo.Printf("%s %q update in progress. Waiting for %d objects.\n", r.Kind, r.Name, unavailableResourceCount)
+			}
 
 			// Fail the deployment in case another deployment has started
 			if og != r.DeploymentStatus.ObservedGeneration && c.Bool("fail-superseded") {
@@ -736,67 +2060,82 @@ func watchResource(c *cli.Context, r *ObjectResource) error {
 	}
 }
 
-func updateResourceStatus(c *cli.Context, r *ObjectResource) error {
-	args := []string{"get", r.Kind + "/" + r.Name, "-o", "yaml"}
-	cmd, err := newKubeCmd(c, args, false)
-	if err != nil {
-		return err
-	}
-	cmd.Stderr = os.Stderr
-	stdout, _ := cmd.StdoutPipe()
-	if err := cmd.Start(); err != nil {
-		return err
+func newKubeCmd(c *cli.Context, args []string, addExtraFlags bool) (*exec.Cmd, error) {
+	return newKubeCmdSub(c, args, false, addExtraFlags)
+}
+
+// newResourceKubeCmd creates a kubectl command for a resource, leaving out the
+// namespace for cluster scoped kinds. The namespace in a resource's metadata takes
+// precedence over --namespace.
+func newResourceKubeCmd(c *cli.Context, r *ObjectResource, args []string, addExtraFlags bool) (*exec.Cmd, error) {
+	if len(r.Namespace) > 0 && !isClusterScoped(r.Kind) {
+		return newKubeCmdScoped(c, append([]string{"--namespace=" + r.Namespace}, args...), false, addExtraFlags, false)
 	}
-	data, _ := ioutil.ReadAll(stdout)
-	if err := yaml.Unmarshal(data, r); err != nil {
-		return err
+	return newKubeCmdScoped(c, args, false, addExtraFlags, !isClusterScoped(r.Kind))
+}
+
+// serverSideArgs returns the kubectl apply or diff arguments for server side apply
+func serverSideArgs(c *cli.Context) ([]string, error) {
+	if !c.Bool(FlagServerSide) {
+		return nil, nil
 	}
-	if err := cmd.Wait(); err != nil {
-		return err
+	if err := requireKubectl(c, minServerSideMinor, "--"+FlagServerSide); err != nil {
+		return nil, err
 	}
-	return nil
+	return []string{"--server-side", "--field-manager=" + c.String(FlagFieldManager)}, nil
 }
 
-func checkResourceExist(c *cli.Context, r *ObjectResource) (bool, error) {
-	args := []string{"get", r.Kind + "/" + r.Name, "-o", "custom-columns=:.metadata.name", "--no-headers"}
-
+// runKubeCmd will run kubectl with the args specified and return the output
+func runKubeCmd(c *cli.Context, args ...string) (string, error) {
 	cmd, err := newKubeCmd(c, args, false)
 	if err != nil {
-		return false, err
+		return "", err
 	}
-	stderr, _ := cmd.StderrPipe()
-	stdout, _ := cmd.StdoutPipe()
-	if err := cmd.Start(); err != nil {
-		logDebug.Printf("error starting kubectl: %s", err)
-		return false, err
+	return runCmdOutput(cmd)
+}
+
+// runResourceKubeCmd will run kubectl for a resource, in its namespace, and return the output
+func runResourceKubeCmd(c *cli.Context, r *ObjectResource, args ...string) (string, error) {
+	cmd, err := newResourceKubeCmd(c, r, args, false)
+	if err != nil {
+		return "", err
 	}
-	data, _ := ioutil.ReadAll(stdout)
-	if err := cmd.Wait(); err != nil {
-		logDebug.Printf(
-			"error with kubectl: %s. kubectl arguments: %q",
-			err,
-			strings.Join(cmd.Args, " "))
-		errData, _ := ioutil.ReadAll(stderr)
-		if strings.Contains("NotFound", string(errData[:])) {
-			return false, nil
+	return runCmdOutput(cmd)
+}
+
+// runCmdOutput runs a kubectl command and returns the output, or the error it reported
+func runCmdOutput(cmd *exec.Cmd) (string, error) {
+	logDebug.Printf("kubectl arguments: %q", strings.Join(cmd.Args, " "))
+
+	var outbuf, errbuf bytes.Buffer
+	cmd.Stdout = &outbuf
+	cmd.Stderr = &errbuf
+	if err := cmd.Run(); err != nil {
+		if errbuf.Len() > 0 {
+			return "", fmt.Errorf(errbuf.String())
 		}
-		return false, err
-	}
-	if strings.TrimSpace(string(data[:])) == r.Name {
-		return true, nil
+		return "", err
 	}
-
-	return false, nil
+	return outbuf.String(), nil
 }
 
-func newKubeCmd(c *cli.Context, args []string, addExtraFlags bool) (*exec.Cmd, error) {
-	return newKubeCmdSub(c, args, false, addExtraFlags)
+func newKubeCmdSub(c *cli.Context, args []string, subCommand bool, addExtraFlags bool) (*exec.Cmd, error) {
+	return newKubeCmdScoped(c, args, subCommand, addExtraFlags, true)
 }
 
-func newKubeCmdSub(c *cli.Context, args []string, subCommand bool, addExtraFlags bool) (*exec.Cmd, error) {
+// newKubeCmdScoped creates a kubectl command, setting the namespace when namespaced
+func newKubeCmdScoped(c *cli.Context, args []string, subCommand, addExtraFlags, namespaced bool) (*exec.Cmd, error) {
+	// Generated files are shared between concurrent deploys
+	kubeCmdLock.Lock()
+	defer kubeCmdLock.Unlock()
+	if readOnly {
+		if err := checkReadOnly(args); err != nil {
+			return nil, err
+		}
+	}
 
 	kube := "kubectl"
-	if c.IsSet("namespace") {
+	if c.IsSet("namespace") && namespaced {
 		args = append([]string{"--namespace=" + c.String("namespace")}, args...)
 	}
 	if c.IsSet("context") {
@@ -813,10 +2152,14 @@ func newKubeCmdSub(c *cli.Context, args []string, subCommand bool, addExtraFlags
 		}
 	}
 	if c.IsSet(FlagCaData) {
-		if err := createCertificateAuthority(c.String(FlagCaFile), c.String(FlagCaData)); err != nil {
+		path, err := caFilePath(c.String(FlagCaFile))
+		if err != nil {
 			return nil, err
 		}
-		args = append([]string{"--certificate-authority=" + c.String(FlagCaFile)}, args...)
+		if err := createCertificateAuthority(path, c.String(FlagCaData)); err != nil {
+			return nil, err
+		}
+		args = append([]string{"--certificate-authority=" + path}, args...)
 	}
 	if c.IsSet(FlagCa) {
 		caFile, err := getCaFileAndDownloadIfRequired(c)
@@ -831,6 +2174,25 @@ func newKubeCmdSub(c *cli.Context, args []string, subCommand bool, addExtraFlags
 	if c.IsSet("kube-server") {
 		args = append([]string{"--server=" + c.String("kube-server")}, args...)
 	}
+	if err := checkKubeConfig(c); err != nil {
+		return nil, err
+	}
+	if c.IsSet(FlagKubeExecCommand) {
+		configFile, err := createExecKubeConfigFile(c)
+		if err != nil {
+			return nil, err
+		}
+		args = append([]string{"--kubeconfig=" + configFile}, args...)
+	}
+	kubeConfigList := ""
+	if kubeConfig := kubeConfigPath(c); len(kubeConfig) > 0 {
+		// kubectl only supports a list of files through the environment
+		if strings.Contains(kubeConfig, string(os.PathListSeparator)) {
+			kubeConfigList = kubeConfig
+		} else {
+			args = append([]string{"--kubeconfig=" + kubeConfig}, args...)
+		}
+	}
 	if c.IsSet(FlagKubeConfigData) {
 		configFile := ""
 		var err error
@@ -862,7 +2224,11 @@ func newKubeCmdSub(c *cli.Context, args []string, subCommand bool, addExtraFlags
 		args = append(args, flags...)
 	}
 
-	return exec.Command(kube, args...), nil
+	cmd := exec.Command(kube, args...)
+	if len(kubeConfigList) > 0 {
+		cmd.Env = append(os.Environ(), "KUBECONFIG="+kubeConfigList)
+	}
+	return cmd, nil
 }
 
 // getCaFileAndDownloadIfRequired will obtain a CA file on disk - if required
@@ -880,12 +2246,11 @@ func getCaFileAndDownloadIfRequired(c *cli.Context) (string, error) {
 		}
 	}
 	// Where should we save the ca?
-	if c.IsSet(FlagCaFile) {
-		caFile = c.String(FlagCaFile)
-	} else {
-		// This is used by cleanup
-		caFile = filepath.Join(getKdTempDir(), "kube-ca.pem")
+	path, err := caFilePath(c.String(FlagCaFile))
+	if err != nil {
+		return "", err
 	}
+	caFile = path
 
 	// skip download if ca file already exists
 	if found, err := FilesExists(caFile); err != nil {
@@ -924,24 +2289,57 @@ func extraFlags(c *cli.Context, subCommand bool) ([]string, error) {
 }
 
 // ListDirectory returns a recursive list of all files under a directory, or an error
-func ListDirectory(path string) ([]string, error) {
+func ListDirectory(path string, exclude ...string) ([]string, error) {
 	var list []string
+	root := path
 	err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if rel, _ := filepath.Rel(root, path); rel != "." && isExcluded(rel, info.IsDir(), exclude) {
+			skippedResources.skipFile(path, "matches an --"+FlagExclude+" pattern")
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
 		if !info.IsDir() {
-			// We only support yaml at the moment, so we might well filter on it
+			// Only manifests, kubectl accepts both yaml and json, and jsonnet evaluated to json
 			switch filepath.Ext(path) {
-			case ".yaml":
-				fallthrough
-			case ".yml":
+			case ".yaml", ".yml", ".jsonnet":
+				list = append(list, path)
+			case ".json":
+				// Other json, e.g. a package.json, is often kept alongside the manifests
+				if !hasManifestKeys(path) {
+					skippedResources.skipFile(path, "is json without an apiVersion and kind")
+					return nil
+				}
 				list = append(list, path)
+			default:
+				skippedResources.skipFile(path, "isn't a manifest (.yaml, .yml, .json or .jsonnet)")
 			}
 		}
 		return nil
 	})
+	// Walk is lexical already but the order of deployment depends on this
+	sort.Strings(list)
 
 	return list, err
 }
 
+// caFilePath returns where certificate authority data is saved, the given path or
+// kube-ca.pem in the workspace so it is removed by cleanup
+func caFilePath(path string) (string, error) {
+	if len(path) > 0 {
+		return path, nil
+	}
+	dir, err := workspace()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "kube-ca.pem"), nil
+}
+
 // createCertificateAuthority creates if required a certificate-authority file
 func createCertificateAuthority(path, content string) error {
 	// This hardcoded certificate authority
@@ -959,18 +2357,13 @@ func createCertificateAuthority(path, content string) error {
 	return nil
 }
 
-// getKdTmpDir will get (or create a TempDir the first time)
-func getKdTempDir() string {
-	if len(tmpDir) < 1 {
-		// Update the global var used for cleanup
-		tmpDir, _ = ioutil.TempDir("", "kd")
-	}
-	return tmpDir
-}
-
 // createKubeConfigFile creates a kube config file
 func createKubeConfigFile(content string) (filePath string, err error) {
-	filePath = filepath.Join(getKdTempDir(), "kube-config")
+	dir, err := workspace()
+	if err != nil {
+		return "", err
+	}
+	filePath = filepath.Join(dir, "kube-config")
 
 	// Write the file to disk
 	if err := ioutil.WriteFile(filePath, []byte(content), 0444); err != nil {
@@ -980,6 +2373,82 @@ func createKubeConfigFile(content string) (filePath string, err error) {
 	return filePath, nil
 }
 
+// kubeConfigPath returns the kubeconfig to use, ignoring one only found in the
+// KUBECONFIG environment when other credentials are given, as they take precedence
+func kubeConfigPath(c *cli.Context) string {
+	if !c.IsSet(FlagKubeConfig) {
+		return ""
+	}
+	path := c.String(FlagKubeConfig)
+	if path == os.Getenv("KUBECONFIG") && (c.IsSet(FlagKubeConfigData) || c.IsSet(FlagKubeExecCommand)) {
+		return ""
+	}
+	return path
+}
+
+// checkKubeConfig checks the ways of specifying the cluster and credentials given
+// don't conflict
+func checkKubeConfig(c *cli.Context) error {
+	if c.IsSet(FlagKubeExecCommand) {
+		if !c.IsSet("kube-server") {
+			return fmt.Errorf("--kube-server must be set with %s", FlagKubeExecCommand)
+		}
+		if len(kubeConfigPath(c)) > 0 || c.IsSet(FlagKubeConfigData) || c.IsSet("context") {
+			return fmt.Errorf("cannot set %s with a kubeconfig or context", FlagKubeExecCommand)
+		}
+	}
+	if len(kubeConfigPath(c)) > 0 && c.IsSet(FlagKubeConfigData) {
+		return fmt.Errorf("cannot set both %s and %s", FlagKubeConfig, FlagKubeConfigData)
+	}
+	return nil
+}
+
+// createExecKubeConfigFile creates a kube config file which authenticates with a
+// credential plugin, as there are no kubectl flags for this
+func createExecKubeConfigFile(c *cli.Context) (string, error) {
+	// have we done this already?
+	if len(execKubeConfigFile) > 0 {
+		return execKubeConfigFile, nil
+	}
+	config := map[string]interface{}{
+		"apiVersion":      "v1",
+		"kind":            "Config",
+		"current-context": "kd",
+		"clusters": []map[string]interface{}{
+			{"name": "kd", "cluster": map[string]interface{}{}},
+		},
+		"contexts": []map[string]interface{}{
+			{"name": "kd", "context": map[string]string{"cluster": "kd", "user": "kd"}},
+		},
+		"users": []map[string]interface{}{
+			{
+				"name": "kd",
+				"user": map[string]interface{}{
+					"exec": map[string]interface{}{
+						"apiVersion": c.String(FlagKubeExecAPIVersion),
+						"command":    c.String(FlagKubeExecCommand),
+						"args":       c.StringSlice(FlagKubeExecArgs),
+					},
+				},
+			},
+		},
+	}
+	content, err := yaml.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	dir, err := workspace()
+	if err != nil {
+		return "", err
+	}
+	filePath := filepath.Join(dir, "kube-config-exec")
+	if err := ioutil.WriteFile(filePath, content, 0400); err != nil {
+		return "", err
+	}
+	execKubeConfigFile = filePath
+	return filePath, nil
+}
+
 // FilesExists checks if a file exists already
 func FilesExists(path string) (bool, error) {
 	stat, err := os.Stat(path)
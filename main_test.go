@@ -1,11 +1,15 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/urfave/cli"
 )
 
 func TestSplitYamlDocs(t *testing.T) {
@@ -48,20 +52,33 @@ func TestSplitYamlDocs(t *testing.T) {
 
 func TestListDirectory(t *testing.T) {
 	cases := []struct {
-		name  string
-		input string
-		want  []string
+		name    string
+		input   string
+		exclude []string
+		want    []string
 	}{
 		{
-			name:  "Check yaml files exist",
+			name:  "Check yaml and json manifests exist",
 			input: "test/TestListDirectory/",
-			want:  []string{"test/TestListDirectory/1-resource.yaml", "test/TestListDirectory/2-resource.yaml", "test/TestListDirectory/a.yaml", "test/TestListDirectory/b.yaml", "test/TestListDirectory/empty.yaml"},
+			want:  []string{"test/TestListDirectory/1-resource.yaml", "test/TestListDirectory/2-resource.yaml", "test/TestListDirectory/a.yaml", "test/TestListDirectory/b.yaml", "test/TestListDirectory/c.json", "test/TestListDirectory/empty.yaml"},
+		},
+		{
+			name:    "Check excluded files and directories are skipped",
+			input:   "test/TestListDirectoryExclude",
+			exclude: []string{"*-test.yaml", "partials/"},
+			want:    []string{"test/TestListDirectoryExclude/api/deployment.yaml", "test/TestListDirectoryExclude/deployment.yaml"},
+		},
+		{
+			name:    "Check excluded paths are relative to the directory",
+			input:   "test/TestListDirectoryExclude/",
+			exclude: []string{"api/*"},
+			want:    []string{"test/TestListDirectoryExclude/deployment.yaml", "test/TestListDirectoryExclude/partials/header.yaml", "test/TestListDirectoryExclude/service-test.yaml"},
 		},
 	}
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			got, err := ListDirectory(c.input)
+			got, err := ListDirectory(c.input, c.exclude...)
 			if err != nil {
 				fmt.Println("Testing if folder doesnt exist")
 			}
@@ -132,6 +149,30 @@ func TestEnvToMap(t *testing.T) {
 	}
 }
 
+func TestSetVariables(t *testing.T) {
+	os.Setenv("KD_TEST_SET_TAG", "from-env")
+	defer os.Unsetenv("KD_TEST_SET_TAG")
+	defer os.Unsetenv("KD_TEST_SET_URL")
+
+	sets, err := setVariables([]string{"KD_TEST_SET_TAG=abc123", "KD_TEST_SET_URL=https://example.com/?a=b"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := map[string]string{"KD_TEST_SET_TAG": "abc123", "KD_TEST_SET_URL": "https://example.com/?a=b"}
+	if !reflect.DeepEqual(sets, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", sets, want)
+	}
+	got := EnvToMap()
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("got: %#v\nwant: %#v\n", got[k], v)
+		}
+	}
+	if _, err := setVariables([]string{"=abc123"}); err == nil {
+		t.Errorf("got: nil\nwant: an error\n")
+	}
+}
+
 func TestGetConfigData(t *testing.T) {
 	type ConfigFile struct {
 		file  string
@@ -219,3 +260,121 @@ func TestGetConfigData(t *testing.T) {
 		})
 	}
 }
+
+func TestMergeValues(t *testing.T) {
+	base := map[interface{}]interface{}{
+		"replicas": 2,
+		"endpoints": map[interface{}]interface{}{
+			"api":   "https://api.example.com",
+			"cache": "redis:6379",
+		},
+	}
+	overlay := map[interface{}]interface{}{
+		"replicas": 6,
+		"endpoints": map[interface{}]interface{}{
+			"api": "https://api.eu.example.com",
+		},
+	}
+	want := map[interface{}]interface{}{
+		"replicas": 6,
+		"endpoints": map[interface{}]interface{}{
+			"api":   "https://api.eu.example.com",
+			"cache": "redis:6379",
+		},
+	}
+	got := mergeValues(base, overlay)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+	if got := mergeValues("env", overlay); !reflect.DeepEqual(got, overlay) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, overlay)
+	}
+}
+
+func TestGetConfigDataJSON(t *testing.T) {
+	got, err := GetConfigData("./test/TestConfigData/values.json", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := map[interface{}]interface{}{
+		"ingress":  map[interface{}]interface{}{"hosts": []interface{}{"a.example.com", "b.example.com"}},
+		"replicas": 3,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+}
+
+func TestCheckKubeConfig(t *testing.T) {
+	cases := []struct {
+		name       string
+		flags      map[string]string
+		kubeConfig string
+		want       string
+		wantErr    string
+	}{
+		{
+			name:       "Check the kubeconfig is used",
+			flags:      map[string]string{FlagKubeConfig: "/etc/kd/kubeconfig"},
+			kubeConfig: "/home/dev/.kube/config",
+			want:       "/etc/kd/kubeconfig",
+		},
+		{
+			name:       "Check the KUBECONFIG environment is used",
+			flags:      map[string]string{FlagKubeConfig: "/home/dev/.kube/config"},
+			kubeConfig: "/home/dev/.kube/config",
+			want:       "/home/dev/.kube/config",
+		},
+		{
+			name:       "Check the KUBECONFIG environment is ignored with kubeconfig data",
+			flags:      map[string]string{FlagKubeConfig: "/home/dev/.kube/config", FlagKubeConfigData: "data"},
+			kubeConfig: "/home/dev/.kube/config",
+		},
+		{
+			name:    "Check a kubeconfig conflicts with kubeconfig data",
+			flags:   map[string]string{FlagKubeConfig: "/etc/kd/kubeconfig", FlagKubeConfigData: "data"},
+			want:    "/etc/kd/kubeconfig",
+			wantErr: "cannot set both",
+		},
+		{
+			name:       "Check the KUBECONFIG environment is ignored with a credential plugin",
+			flags:      map[string]string{FlagKubeConfig: "/home/dev/.kube/config", FlagKubeExecCommand: "aws", "kube-server": "https://eks"},
+			kubeConfig: "/home/dev/.kube/config",
+		},
+		{
+			name:    "Check a kubeconfig conflicts with a credential plugin",
+			flags:   map[string]string{FlagKubeConfig: "/etc/kd/kubeconfig", FlagKubeExecCommand: "aws", "kube-server": "https://eks"},
+			want:    "/etc/kd/kubeconfig",
+			wantErr: "cannot set " + FlagKubeExecCommand,
+		},
+		{
+			name:    "Check a credential plugin requires the server",
+			flags:   map[string]string{FlagKubeExecCommand: "aws"},
+			wantErr: "--kube-server must be set",
+		},
+	}
+	defer os.Setenv("KUBECONFIG", os.Getenv("KUBECONFIG"))
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Setenv("KUBECONFIG", tc.kubeConfig)
+			set := flag.NewFlagSet("kd", flag.ContinueOnError)
+			for _, name := range []string{FlagKubeConfig, FlagKubeConfigData, FlagKubeExecCommand, "kube-server", "context"} {
+				set.String(name, "", "")
+			}
+			for name, value := range tc.flags {
+				set.Set(name, value)
+			}
+			c := cli.NewContext(nil, set, nil)
+			if got := kubeConfigPath(c); got != tc.want {
+				t.Errorf("got: %#v\nwant: %#v\n", got, tc.want)
+			}
+			err := checkKubeConfig(c)
+			if len(tc.wantErr) == 0 && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if len(tc.wantErr) > 0 && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Errorf("got: %#v\nwant error: %#v\n", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// addMetadata will add labels or annotations (the field) to the template of a resource
+func addMetadata(r *ObjectResource, field string, values map[string]string) error {
+	return addMetadataAt(r, nil, field, values)
+}
+
+// addMetadataAt will add labels or annotations to the object at a path in the template
+// of a resource, e.g. the pod template of a deployment
+func addMetadataAt(r *ObjectResource, path []string, field string, values map[string]string) error {
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(r.Template, &doc); err != nil {
+		return err
+	}
+	b, err := yaml.Marshal(withMetadata(doc, path, field, values))
+	if err != nil {
+		return err
+	}
+	r.Template = b
+	return nil
+}
+
+// withMetadata sets the labels or annotations of the object at a path in an ordered yaml map
+func withMetadata(doc yaml.MapSlice, path []string, field string, values map[string]string) yaml.MapSlice {
+	if len(path) > 0 {
+		child, _ := mapSliceGet(doc, path[0])
+		childSlice, _ := child.(yaml.MapSlice)
+		return mapSliceSet(doc, path[0], withMetadata(childSlice, path[1:], field, values))
+	}
+	meta, _ := mapSliceGet(doc, "metadata")
+	metaSlice, _ := meta.(yaml.MapSlice)
+	existing, _ := mapSliceGet(metaSlice, field)
+	fieldSlice, _ := existing.(yaml.MapSlice)
+
+	// Keep the output stable by adding the keys in order
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fieldSlice = mapSliceSet(fieldSlice, k, values[k])
+	}
+	metaSlice = mapSliceSet(metaSlice, field, fieldSlice)
+	return mapSliceSet(doc, "metadata", metaSlice)
+}
+
+// addFlagMetadata adds the --label labels and --annotate annotations to the resources
+func addFlagMetadata(c *cli.Context, resources []*ObjectResource) error {
+	labels, err := parseKeyValues(FlagLabel, c.StringSlice(FlagLabel))
+	if err != nil {
+		return err
+	}
+	annotations, err := parseKeyValues(FlagAnnotate, c.StringSlice(FlagAnnotate))
+	if err != nil {
+		return err
+	}
+	return addCommonMetadata(resources, labels, annotations, c.Bool(FlagLabelPodTemplates))
+}
+
+// addCommonMetadata adds the labels and annotations to every resource and, when
+// podTemplates is set, to the pod templates of workloads
+func addCommonMetadata(resources []*ObjectResource, labels, annotations map[string]string, podTemplates bool) error {
+	for _, r := range resources {
+		for _, field := range []string{"labels", "annotations"} {
+			values := labels
+			if field == "annotations" {
+				values = annotations
+			}
+			if len(values) == 0 {
+				continue
+			}
+			if err := addMetadata(r, field, values); err != nil {
+				return err
+			}
+			if path := podTemplatePath(r.Kind); podTemplates && len(path) > 0 && path[0] != "-" {
+				if err := addMetadataAt(r, path, field, values); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// parseKeyValues parses repeated key=value flag values, the values may contain = or ,
+func parseKeyValues(flag string, list []string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, item := range list {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 || len(strings.TrimSpace(kv[0])) == 0 {
+			return nil, fmt.Errorf("invalid %s %q, expecting key=value", flag, item)
+		}
+		values[strings.TrimSpace(kv[0])] = kv[1]
+	}
+	return values, nil
+}
+
+// mapSliceGet returns the value of a key from an ordered yaml map
+func mapSliceGet(m yaml.MapSlice, key string) (interface{}, bool) {
+	for _, item := range m {
+		if k, ok := item.Key.(string); ok && k == key {
+			return item.Value, true
+		}
+	}
+	return nil, false
+}
+
+// mapSliceSet updates (or appends) the value of a key in an ordered yaml map
+func mapSliceSet(m yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for i, item := range m {
+		if k, ok := item.Key.(string); ok && k == key {
+			m[i].Value = value
+			return m
+		}
+	}
+	return append(m, yaml.MapItem{Key: key, Value: value})
+}
+
+// mapSliceDelete removes a key from an ordered yaml map
+func mapSliceDelete(m yaml.MapSlice, key string) yaml.MapSlice {
+	for i, item := range m {
+		if k, ok := item.Key.(string); ok && k == key {
+			return append(m[:i], m[i+1:]...)
+		}
+	}
+	return m
+}
+
+// jsonPathKey escapes a label or annotation key for use in a kubectl jsonpath
+func jsonPathKey(key string) string {
+	return strings.Replace(key, ".", "\\.", -1)
+}
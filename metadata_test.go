@@ -0,0 +1,105 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestAddMetadata(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  string
+		field  string
+		values map[string]string
+		want   string
+	}{
+		{
+			name:   "Check labels are added when there is no metadata",
+			input:  "kind: ConfigMap\n",
+			field:  "labels",
+			values: map[string]string{"b": "2", "a": "1"},
+			want:   "kind: ConfigMap\nmetadata:\n  labels:\n    a: \"1\"\n    b: \"2\"\n",
+		},
+		{
+			name:   "Check existing annotations are kept and updated",
+			input:  "kind: ConfigMap\nmetadata:\n  name: foo\n  annotations:\n    keep: me\n    a: old\ndata:\n  x: z\n",
+			field:  "annotations",
+			values: map[string]string{"a": "new"},
+			want:   "kind: ConfigMap\nmetadata:\n  name: foo\n  annotations:\n    keep: me\n    a: new\ndata:\n  x: z\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := &ObjectResource{Template: []byte(c.input)}
+			if err := addMetadata(r, c.field, c.values); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			got := string(r.Template)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
+
+func TestAddCommonMetadata(t *testing.T) {
+	cases := []struct {
+		name         string
+		input        string
+		podTemplates bool
+		want         string
+	}{
+		{
+			name:  "Check only the metadata of the resource is changed by default",
+			input: "kind: Deployment\nmetadata:\n  name: api\nspec:\n  template:\n    metadata:\n      labels:\n        app: api\n",
+			want:  "kind: Deployment\nmetadata:\n  name: api\n  labels:\n    git-sha: abc123\n  annotations:\n    build: \"42\"\nspec:\n  template:\n    metadata:\n      labels:\n        app: api\n",
+		},
+		{
+			name:         "Check pod templates are changed when asked",
+			input:        "kind: CronJob\nmetadata:\n  name: report\nspec:\n  jobTemplate:\n    spec:\n      template:\n        metadata:\n          labels:\n            app: report\n",
+			podTemplates: true,
+			want:         "kind: CronJob\nmetadata:\n  name: report\n  labels:\n    git-sha: abc123\n  annotations:\n    build: \"42\"\nspec:\n  jobTemplate:\n    spec:\n      template:\n        metadata:\n          labels:\n            app: report\n            git-sha: abc123\n          annotations:\n            build: \"42\"\n",
+		},
+		{
+			name:         "Check kinds without pods only get the resource metadata",
+			input:        "kind: ConfigMap\nmetadata:\n  name: settings\n",
+			podTemplates: true,
+			want:         "kind: ConfigMap\nmetadata:\n  name: settings\n  labels:\n    git-sha: abc123\n  annotations:\n    build: \"42\"\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var r ObjectResource
+			r.Template = []byte(c.input)
+			if err := yaml.Unmarshal(r.Template, &r); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			err := addCommonMetadata([]*ObjectResource{&r}, map[string]string{"git-sha": "abc123"}, map[string]string{"build": "42"}, c.podTemplates)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			got := string(r.Template)
+			if got != c.want {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseKeyValues(t *testing.T) {
+	got, err := parseKeyValues(FlagAnnotate, []string{"team=payments", "docs=https://example.com/?a=b,c"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := map[string]string{"team": "payments", "docs": "https://example.com/?a=b,c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+	if _, err := parseKeyValues(FlagLabel, []string{"team"}); err == nil {
+		t.Errorf("got: nil\nwant: an error\n")
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// instanceLabel is the label added to the selectors and pods of renamed resources, so
+// copies of an app in a namespace don't select each other's pods
+const instanceLabel = "kd.uswitch.io/instance"
+
+// nameRef is a field referring to a resource by name, kind is empty for a reference
+// with a kind and name field (e.g. a roleRef) rather than a name
+type nameRef struct {
+	kind string
+	path []string
+}
+
+// podSpecRefs are the references to other resources in a pod spec
+var podSpecRefs = []nameRef{
+	{kind: "ConfigMap", path: []string{"containers", "[]", "envFrom", "[]", "configMapRef", "name"}},
+	{kind: "ConfigMap", path: []string{"initContainers", "[]", "envFrom", "[]", "configMapRef", "name"}},
+	{kind: "ConfigMap", path: []string{"containers", "[]", "env", "[]", "valueFrom", "configMapKeyRef", "name"}},
+	{kind: "ConfigMap", path: []string{"initContainers", "[]", "env", "[]", "valueFrom", "configMapKeyRef", "name"}},
+	{kind: "ConfigMap", path: []string{"volumes", "[]", "configMap", "name"}},
+	{kind: "ConfigMap", path: []string{"volumes", "[]", "projected", "sources", "[]", "configMap", "name"}},
+	{kind: "Secret", path: []string{"containers", "[]", "envFrom", "[]", "secretRef", "name"}},
+	{kind: "Secret", path: []string{"initContainers", "[]", "envFrom", "[]", "secretRef", "name"}},
+	{kind: "Secret", path: []string{"containers", "[]", "env", "[]", "valueFrom", "secretKeyRef", "name"}},
+	{kind: "Secret", path: []string{"initContainers", "[]", "env", "[]", "valueFrom", "secretKeyRef", "name"}},
+	{kind: "Secret", path: []string{"volumes", "[]", "secret", "secretName"}},
+	{kind: "Secret", path: []string{"volumes", "[]", "projected", "sources", "[]", "secret", "name"}},
+	{kind: "Secret", path: []string{"imagePullSecrets", "[]", "name"}},
+	{kind: "ServiceAccount", path: []string{"serviceAccountName"}},
+	{kind: "PersistentVolumeClaim", path: []string{"volumes", "[]", "persistentVolumeClaim", "claimName"}},
+}
+
+// kindRefs are the references to other resources of each kind, other than from pod specs
+var kindRefs = map[string][]nameRef{
+	"StatefulSet": {
+		{kind: "Service", path: []string{"spec", "serviceName"}},
+	},
+	"Ingress": {
+		{kind: "Service", path: []string{"spec", "backend", "serviceName"}},
+		{kind: "Service", path: []string{"spec", "defaultBackend", "service", "name"}},
+		{kind: "Service", path: []string{"spec", "rules", "[]", "http", "paths", "[]", "backend", "serviceName"}},
+		{kind: "Service", path: []string{"spec", "rules", "[]", "http", "paths", "[]", "backend", "service", "name"}},
+		{kind: "Secret", path: []string{"spec", "tls", "[]", "secretName"}},
+	},
+	"HorizontalPodAutoscaler": {
+		{path: []string{"spec", "scaleTargetRef"}},
+	},
+	"RoleBinding": {
+		{path: []string{"roleRef"}},
+		{path: []string{"subjects", "[]"}},
+	},
+	"ClusterRoleBinding": {
+		{path: []string{"roleRef"}},
+		{path: []string{"subjects", "[]"}},
+	},
+}
+
+// selectorPaths are the label selectors of each kind which select the pods of the release
+var selectorPaths = map[string][]string{
+	"Deployment":          {"spec", "selector", "matchLabels"},
+	"StatefulSet":         {"spec", "selector", "matchLabels"},
+	"DaemonSet":           {"spec", "selector", "matchLabels"},
+	"ReplicaSet":          {"spec", "selector", "matchLabels"},
+	"PodDisruptionBudget": {"spec", "selector", "matchLabels"},
+	"NetworkPolicy":       {"spec", "podSelector", "matchLabels"},
+	"Service":             {"spec", "selector"},
+}
+
+// renameResources adds a prefix and suffix to the names of the resources, and to the
+// references between them, so more than one copy of an app can be deployed to a
+// namespace. The pods and selectors of each copy are labelled with the instance.
+func renameResources(resources []*ObjectResource, prefix, suffix string) error {
+	if len(prefix) == 0 && len(suffix) == 0 {
+		return nil
+	}
+	docs := make([]yaml.MapSlice, len(resources))
+	renamed := map[string]string{}
+	for i, r := range resources {
+		if err := yaml.Unmarshal(r.Template, &docs[i]); err != nil {
+			return err
+		}
+		kind, _ := mapSliceGet(docs[i], "kind")
+		meta, _ := mapSliceGet(docs[i], "metadata")
+		name, _ := mapSliceGet(asMapSlice(meta), "name")
+		if n, ok := name.(string); ok && len(n) > 0 {
+			renamed[fmt.Sprint(kind)+"/"+n] = prefix + n + suffix
+		}
+	}
+	instance := strings.Trim(prefix+suffix, "-.")
+	for i, r := range resources {
+		doc, err := renamedDoc(docs[i], renamed, prefix, suffix, instance)
+		if err != nil {
+			return fmt.Errorf("problem renaming a resource in file:%q: %s", r.FileName, err)
+		}
+		b, err := yaml.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		r.Template = b
+	}
+	return nil
+}
+
+// renamedDoc renames a resource and the resources it refers to, labelling its pods and
+// selectors with the instance
+func renamedDoc(doc yaml.MapSlice, renamed map[string]string, prefix, suffix, instance string) (yaml.MapSlice, error) {
+	kindValue, _ := mapSliceGet(doc, "kind")
+	kind := fmt.Sprint(kindValue)
+	rename := func(kind string) func(interface{}) interface{} {
+		return func(value interface{}) interface{} {
+			if name, ok := value.(string); ok {
+				if to, found := renamed[kind+"/"+name]; found {
+					return to
+				}
+			}
+			return value
+		}
+	}
+	doc = updateAt(doc, []string{"metadata", "name"}, rename(kind)).(yaml.MapSlice)
+	doc = updateAt(doc, []string{"metadata", "generateName"}, func(value interface{}) interface{} {
+		if name, ok := value.(string); ok && len(name) > 0 {
+			return prefix + strings.TrimSuffix(name, "-") + suffix + "-"
+		}
+		return value
+	}).(yaml.MapSlice)
+
+	refs := kindRefs[kind]
+	if path := podTemplatePath(kind, "spec"); path[0] != "-" {
+		for _, ref := range podSpecRefs {
+			refs = append(refs, nameRef{kind: ref.kind, path: append(append([]string{}, path...), ref.path...)})
+		}
+	}
+	for _, ref := range refs {
+		if len(ref.kind) > 0 {
+			doc = updateAt(doc, ref.path, rename(ref.kind)).(yaml.MapSlice)
+			continue
+		}
+		// A reference with its own kind, e.g. the roleRef of a RoleBinding
+		doc = updateAt(doc, ref.path, func(value interface{}) interface{} {
+			obj, ok := value.(yaml.MapSlice)
+			if !ok {
+				return value
+			}
+			refKind, _ := mapSliceGet(obj, "kind")
+			return updateAt(obj, []string{"name"}, rename(fmt.Sprint(refKind)))
+		}).(yaml.MapSlice)
+	}
+
+	if len(instance) == 0 {
+		return doc, nil
+	}
+	labels := map[string]string{instanceLabel: instance}
+	doc = withMetadata(doc, nil, "labels", labels)
+	if path := podTemplatePath(kind); len(path) > 0 && path[0] != "-" {
+		doc = withMetadata(doc, path, "labels", labels)
+	}
+	// Only add to selectors which are set, an empty selector selects every pod
+	if path, found := selectorPaths[kind]; found && len(asMapSlice(lookupMapSlice(doc, path...))) > 0 {
+		doc = updateAt(doc, path, func(value interface{}) interface{} {
+			return mapSliceSet(asMapSlice(value), instanceLabel, instance)
+		}).(yaml.MapSlice)
+	}
+	return doc, nil
+}
+
+// updateAt replaces the value at a path in an ordered yaml document, where [] is every
+// item of a list. Missing fields are left missing.
+func updateAt(value interface{}, path []string, fn func(interface{}) interface{}) interface{} {
+	if len(path) == 0 {
+		return fn(value)
+	}
+	if path[0] == "[]" {
+		list, ok := value.([]interface{})
+		if !ok {
+			return value
+		}
+		for i := range list {
+			list[i] = updateAt(list[i], path[1:], fn)
+		}
+		return list
+	}
+	m, ok := value.(yaml.MapSlice)
+	if !ok {
+		return value
+	}
+	child, found := mapSliceGet(m, path[0])
+	if !found {
+		return value
+	}
+	return mapSliceSet(m, path[0], updateAt(child, path[1:], fn))
+}
+
+// lookupMapSlice returns the value at a path in an ordered yaml document
+func lookupMapSlice(value interface{}, path ...string) interface{} {
+	for _, p := range path {
+		value, _ = mapSliceGet(asMapSlice(value), p)
+	}
+	return value
+}
+
+// asMapSlice is a value as an ordered yaml map, empty if it isn't one
+func asMapSlice(value interface{}) yaml.MapSlice {
+	m, _ := value.(yaml.MapSlice)
+	return m
+}
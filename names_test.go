@@ -0,0 +1,54 @@
+package main
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestRenameResources(t *testing.T) {
+	data, err := ioutil.ReadFile("test/TestRenameResources/app.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	wantData, err := ioutil.ReadFile("test/TestRenameResources/want.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var resources []*ObjectResource
+	for _, d := range splitYamlDocs(string(data)) {
+		resources = append(resources, &ObjectResource{Template: []byte(d)})
+	}
+	if err := renameResources(resources, "", "-pr123"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := splitYamlDocs(string(wantData))
+	if len(resources) != len(want) {
+		t.Fatalf("got %d resources, want %d", len(resources), len(want))
+	}
+	for i, r := range resources {
+		var got, wantDoc yaml.MapSlice
+		if err := yaml.Unmarshal(r.Template, &got); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := yaml.Unmarshal([]byte(want[i]), &wantDoc); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(got, wantDoc) {
+			t.Errorf("got: %s\nwant: %s\n", r.Template, want[i])
+		}
+	}
+}
+
+func TestRenameResourcesUnchanged(t *testing.T) {
+	template := []byte("kind: ConfigMap\nmetadata:\n  name: api\n")
+	resources := []*ObjectResource{{Template: template}}
+	if err := renameResources(resources, "", ""); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := string(resources[0].Template); got != string(template) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, string(template))
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+// printNotes renders the notes template with the config data and prints it, with a
+// render timeout of its own as the deadline of the resources passed while deploying
+func printNotes(c *cli.Context, fn string) error {
+	renderDeadline = notesDeadline(time.Now(), c.Duration(FlagRenderTimeout))
+	notes, err := renderNotes(NewK8ApiKubectl(c), fn, renderConf)
+	if err != nil {
+		return err
+	}
+	if len(notes) > 0 {
+		logSummary.Printf("notes:\n%s", notes)
+	}
+	return nil
+}
+
+// notesDeadline is when rendering the notes must complete by (zero for no limit)
+func notesDeadline(now time.Time, timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return now.Add(timeout)
+}
+
+// renderNotes renders a notes template, which can use the same functions as resources
+func renderNotes(k K8Api, fn string, conf interface{}) (string, error) {
+	data, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return "", err
+	}
+	templateFile = fn
+	notes, _, err := Render(k, string(data), conf)
+	if err != nil {
+		return "", fmt.Errorf("problem rendering notes %s: %s", fn, err)
+	}
+	return strings.TrimSpace(notes), nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenderNotes(t *testing.T) {
+	conf := map[string]interface{}{"APP": "grafana", "DOMAIN": "example.com"}
+	got, err := renderNotes(NewK8ApiNoop(), "test/TestRenderNotes/NOTES.txt", conf)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := "grafana is available at https://grafana.example.com\nCredentials are in the grafana-admin Secret."
+	if got != want {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+}
+
+func TestRenderNotesResources(t *testing.T) {
+	results := []deployResult{
+		{Kind: "Deployment", Name: "api", Namespace: "prod"},
+		{Kind: "Job", Name: "migrate-x7k2p", GenerateName: "migrate-", Namespace: "prod"},
+	}
+	conf := withResults(map[string]interface{}{}, results)
+	got, err := renderNotes(NewK8ApiNoop(), "test/TestRenderNotes/RESOURCES.txt", conf)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := "follow the logs with: kubectl logs -f -n prod job/migrate-x7k2p"
+	if got != want {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+}
+
+func TestNotesDeadline(t *testing.T) {
+	now := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+	if got := notesDeadline(now, 0); !got.IsZero() {
+		t.Errorf("got: %#v\nwant: no deadline\n", got)
+	}
+	if got, want := notesDeadline(now, time.Minute), now.Add(time.Minute); !got.Equal(want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// installOrder is the order kinds are deployed in (the same as helm), any other
+// kinds are deployed afterwards
+var installOrder = []string{
+	"Namespace",
+	"NetworkPolicy",
+	"ResourceQuota",
+	"LimitRange",
+	"PodSecurityPolicy",
+	"PodDisruptionBudget",
+	"ServiceAccount",
+	"Secret",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"CustomResourceDefinition",
+	"ClusterRole",
+	"ClusterRoleBinding",
+	"Role",
+	"RoleBinding",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"HorizontalPodAutoscaler",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"Ingress",
+	"APIService",
+}
+
+// clusterScopedKinds are the built in kinds which don't belong to a namespace
+var clusterScopedKinds = []string{
+	"APIService",
+	"CertificateSigningRequest",
+	"ClusterRole",
+	"ClusterRoleBinding",
+	"CSIDriver",
+	"CSINode",
+	"CustomResourceDefinition",
+	"IngressClass",
+	"MutatingWebhookConfiguration",
+	"Namespace",
+	"Node",
+	"PersistentVolume",
+	"PodSecurityPolicy",
+	"PriorityClass",
+	"RuntimeClass",
+	"StorageClass",
+	"ValidatingWebhookConfiguration",
+	"VolumeAttachment",
+}
+
+// isClusterScoped checks if a kind is one of the cluster scoped kinds
+func isClusterScoped(kind string) bool {
+	for _, k := range clusterScopedKinds {
+		if strings.EqualFold(k, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortResources orders resources by kind so dependencies are deployed first (or
+// deleted last), keeping the file order for resources of the same kind
+func sortResources(resources []*ObjectResource, reverse bool) {
+	rank := func(kind string) int {
+		for i, k := range installOrder {
+			if k == kind {
+				return i
+			}
+		}
+		return len(installOrder)
+	}
+	sort.SliceStable(resources, func(i, j int) bool {
+		if reverse {
+			return rank(resources[i].Kind) > rank(resources[j].Kind)
+		}
+		return rank(resources[i].Kind) < rank(resources[j].Kind)
+	})
+}
+
+// orderDependencies moves resources after the resources they depend on (see
+// AnnotationDependsOn), otherwise keeping the order. When deleting, resources are
+// deleted before the resources they depend on.
+func orderDependencies(resources []*ObjectResource, reverse bool) ([]*ObjectResource, error) {
+	defined := map[string]bool{}
+	for _, r := range resources {
+		defined[resourceRef(r)] = true
+	}
+	for _, r := range resources {
+		for _, dep := range dependsOn(r) {
+			if !defined[dep] {
+				return nil, fmt.Errorf("%s (from file:%q) depends on %s which is not part of the release",
+					resourceRef(r), r.FileName, dep)
+			}
+		}
+	}
+	pending := make([]*ObjectResource, len(resources))
+	copy(pending, resources)
+	if reverse {
+		reverseResources(pending)
+	}
+	placed := map[string]bool{}
+	var ordered []*ObjectResource
+	for len(pending) > 0 {
+		next := -1
+		for i, r := range pending {
+			ready := true
+			for _, dep := range dependsOn(r) {
+				if !placed[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			var cycle []string
+			for _, r := range pending {
+				cycle = append(cycle, resourceRef(r))
+			}
+			return nil, fmt.Errorf("circular dependencies between %s", strings.Join(cycle, ", "))
+		}
+		placed[resourceRef(pending[next])] = true
+		ordered = append(ordered, pending[next])
+		pending = append(pending[:next], pending[next+1:]...)
+	}
+	if reverse {
+		reverseResources(ordered)
+	}
+	return ordered, nil
+}
+
+// dependsOn returns the kind/name of the resources a resource depends on
+func dependsOn(r *ObjectResource) []string {
+	var deps []string
+	for _, dep := range strings.Split(r.Annotations[AnnotationDependsOn], ",") {
+		parts := strings.SplitN(strings.TrimSpace(dep), "/", 2)
+		if len(parts) == 2 {
+			deps = append(deps, strings.ToLower(parts[0])+"/"+parts[1])
+		}
+	}
+	return deps
+}
+
+// dependsOnAny checks if a resource depends on any of the resources given
+func dependsOnAny(r *ObjectResource, resources []*ObjectResource) bool {
+	for _, dep := range dependsOn(r) {
+		for _, other := range resources {
+			if resourceRef(other) == dep {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resourceRef is the kind/name used to refer to a resource, e.g. deployment/app
+func resourceRef(r *ObjectResource) string {
+	return strings.ToLower(r.Kind) + "/" + r.Name
+}
+
+// reverseResources reverses the order of resources in place
+func reverseResources(resources []*ObjectResource) {
+	for i, j := 0, len(resources)-1; i < j; i, j = i+1, j-1 {
+		resources[i], resources[j] = resources[j], resources[i]
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSortResources(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   []string
+		reverse bool
+		want    []string
+	}{
+		{
+			name:  "Check dependencies are deployed first",
+			input: []string{"Deployment/a", "Certificate/a", "ConfigMap/a", "Service/a", "ConfigMap/b", "Namespace/a"},
+			want:  []string{"Namespace/a", "ConfigMap/a", "ConfigMap/b", "Service/a", "Deployment/a", "Certificate/a"},
+		},
+		{
+			name:    "Check dependencies are deleted last",
+			input:   []string{"Namespace/a", "ConfigMap/a", "Deployment/a"},
+			reverse: true,
+			want:    []string{"Deployment/a", "ConfigMap/a", "Namespace/a"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var resources []*ObjectResource
+			for _, in := range c.input {
+				r := &ObjectResource{}
+				r.Kind, r.Name = in[:len(in)-2], in[len(in)-1:]
+				resources = append(resources, r)
+			}
+			sortResources(resources, c.reverse)
+			var got []string
+			for _, r := range resources {
+				got = append(got, r.Kind+"/"+r.Name)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
+
+func TestOrderDependencies(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   []string
+		deps    map[string]string
+		reverse bool
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "Check resources are moved after their dependencies",
+			input: []string{"Deployment/a", "Deployment/b", "Job/c"},
+			deps:  map[string]string{"Deployment/a": "job/c", "Deployment/b": "deployment/a"},
+			want:  []string{"Job/c", "Deployment/a", "Deployment/b"},
+		},
+		{
+			name:    "Check dependents are deleted first",
+			input:   []string{"Deployment/b", "Deployment/a", "Job/c"},
+			deps:    map[string]string{"Deployment/a": "job/c, deployment/b"},
+			reverse: true,
+			want:    []string{"Deployment/a", "Deployment/b", "Job/c"},
+		},
+		{
+			name:    "Check circular dependencies are an error",
+			input:   []string{"Deployment/a", "Deployment/b"},
+			deps:    map[string]string{"Deployment/a": "deployment/b", "Deployment/b": "deployment/a"},
+			wantErr: true,
+		},
+		{
+			name:    "Check missing dependencies are an error",
+			input:   []string{"Deployment/a"},
+			deps:    map[string]string{"Deployment/a": "service/z"},
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var resources []*ObjectResource
+			for _, in := range c.input {
+				r := &ObjectResource{}
+				r.Kind, r.Name = in[:len(in)-2], in[len(in)-1:]
+				if dep, ok := c.deps[in]; ok {
+					r.Annotations = map[string]string{AnnotationDependsOn: dep}
+				}
+				resources = append(resources, r)
+			}
+			ordered, err := orderDependencies(resources, c.reverse)
+			if c.wantErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			var got []string
+			for _, r := range ordered {
+				got = append(got, r.Kind+"/"+r.Name)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
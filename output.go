@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+// renderManifests renders the resources and writes them to stdout without using the cluster
+func renderManifests(c *cli.Context) error {
+	if c.Bool("debug") {
+		logDebug = logDebugIf
+	}
+	// stdout is for the manifests only
+	logInfo.SetOutput(logOutput.writer(os.Stderr))
+	dryRun = true
+	resources, err := renderResources(c)
+	if err != nil {
+		return err
+	}
+	if c.IsSet(FlagOutputDir) {
+		return writeManifests(c.String(FlagOutputDir), resources)
+	}
+	for _, r := range resources {
+		fmt.Print(manifest(r))
+	}
+	return nil
+}
+
+// writeManifests writes each resource to a directory as kind-name.yaml
+func writeManifests(dir string, resources []*ObjectResource) error {
+	// Check for collisions first, so none of the manifests are written if there are any
+	written := map[string]*ObjectResource{}
+	for _, r := range resources {
+		fn := filepath.Join(dir, manifestFileName(r))
+		if first, found := written[fn]; found {
+			return fmt.Errorf("resources from file:%q and file:%q would both be written to %s", first.FileName, r.FileName, fn)
+		}
+		written[fn] = r
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, r := range resources {
+		fn := filepath.Join(dir, manifestFileName(r))
+		if err := ioutil.WriteFile(fn, []byte(manifest(r)), 0644); err != nil {
+			return err
+		}
+		logDebug.Printf("wrote %s/%s to %s", strings.ToLower(r.Kind), r.Name, fn)
+	}
+	logInfo.Printf("wrote %d manifests to %s", len(resources), dir)
+	return nil
+}
+
+// manifestFileName is the file name for a resource e.g. deployment-app.yaml
+func manifestFileName(r *ObjectResource) string {
+	name := r.Name
+	if len(name) == 0 {
+		name = strings.TrimSuffix(r.GenerateName, "-")
+	}
+	return strings.ToLower(r.Kind) + "-" + name + ".yaml"
+}
+
+// manifest is a rendered resource as a yaml document
+func manifest(r *ObjectResource) string {
+	doc := strings.TrimLeft(string(r.Template), "\n")
+	if !strings.HasSuffix(doc, "\n") {
+		doc += "\n"
+	}
+	return "---\n" + doc
+}
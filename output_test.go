@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	cases := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{
+			name:     "Check a document separator is added",
+			template: "\nkind: ConfigMap\n",
+			want:     "---\nkind: ConfigMap\n",
+		},
+		{
+			name:     "Check a trailing new line is added",
+			template: "kind: ConfigMap",
+			want:     "---\nkind: ConfigMap\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := manifest(&ObjectResource{Template: []byte(c.template)})
+			if got != c.want {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
+
+func TestWriteManifests(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kd-output")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	resources := []*ObjectResource{
+		{Kind: "Deployment", ObjectMeta: ObjectMeta{Name: "api"}, Template: []byte("kind: Deployment\n")},
+		{Kind: "Job", ObjectMeta: ObjectMeta{GenerateName: "migrate-"}, Template: []byte("kind: Job\n")},
+	}
+	if err := writeManifests(dir, resources); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got, err := ioutil.ReadFile(filepath.Join(dir, "job-migrate.yaml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "---\nkind: Job\n"; string(got) != want {
+		t.Errorf("got: %#v\nwant: %#v\n", string(got), want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "deployment-api.yaml")); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	resources = append(resources, &ObjectResource{Kind: "Deployment", ObjectMeta: ObjectMeta{Name: "api", Namespace: "other"}})
+	collisions := filepath.Join(dir, "collisions")
+	if err := writeManifests(collisions, resources); err == nil {
+		t.Error("expected an error when two resources have the same file name")
+	}
+	if _, err := os.Stat(collisions); !os.IsNotExist(err) {
+		t.Errorf("expected no manifests to be written, got: %v", err)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// validatePlatforms warns when an image has no manifest for an architecture of the nodes
+func validatePlatforms(c *cli.Context, resources []*ObjectResource) error {
+	var archs []string
+	for _, p := range c.StringSlice(FlagPlatforms) {
+		archs = append(archs, strings.Split(p, ",")...)
+	}
+	if len(archs) == 0 {
+		out, err := runKubeCmd(c, "get", "nodes", "--no-headers", "-o", "custom-columns=:.status.nodeInfo.architecture")
+		if err != nil {
+			return fmt.Errorf("problem finding the architectures of the nodes: %s", err)
+		}
+		archs = strings.Fields(out)
+	}
+	images, err := workloadImages(resources)
+	if err != nil {
+		return err
+	}
+	for _, image := range images {
+		available, err := imageArchitectures(image)
+		if err != nil {
+			logWarn.Printf("unable to check the architectures of image %s: %s", image, err)
+			continue
+		}
+		if missing := missingArchitectures(available, archs); len(missing) > 0 {
+			logWarn.Printf("image %s has no manifest for %s, pods on these nodes will fail (available: %s)",
+				image, strings.Join(missing, ", "), strings.Join(available, ", "))
+		}
+	}
+	return nil
+}
+
+// workloadImages returns the images used by the pod templates of the resources
+func workloadImages(resources []*ObjectResource) ([]string, error) {
+	seen := map[string]bool{}
+	var images []string
+	for _, r := range resources {
+		var doc map[interface{}]interface{}
+		if err := yaml.Unmarshal(r.Template, &doc); err != nil {
+			return nil, err
+		}
+		podSpec, _ := lookupPath(doc, podTemplatePath(r.Kind, "spec")...).(map[interface{}]interface{})
+		for _, field := range []string{"initContainers", "containers"} {
+			for _, ctr := range listAt(podSpec, field) {
+				if image, ok := lookupPath(ctr, "image").(string); ok && !seen[image] {
+					seen[image] = true
+					images = append(images, image)
+				}
+			}
+		}
+	}
+	return images, nil
+}
+
+// manifestTypes are the media types of the manifest lists of multi-arch images
+var manifestTypes = []string{
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.index.v1+json",
+}
+
+// imageArchitectures queries the registry of an image for the architectures it has
+// manifests for, so docker isn't needed
+func imageArchitectures(image string) ([]string, error) {
+	host, url := registryManifestURL(image)
+	return registryArchitectures(url, registryAuth(host))
+}
+
+// registryManifestURL returns the registry host of an image and the registry api url
+// of its manifest, docker hub when the image doesn't name a registry
+func registryManifestURL(image string) (string, string) {
+	repository, tag, digest := splitImage(image)
+	reference := digest
+	if len(reference) == 0 {
+		reference = tag
+	}
+	if len(reference) == 0 {
+		reference = "latest"
+	}
+	host := "registry-1.docker.io"
+	parts := strings.SplitN(repository, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		host, repository = parts[0], parts[1]
+	} else if len(parts) == 1 {
+		repository = "library/" + repository
+	}
+	if host == "docker.io" || host == "index.docker.io" {
+		host = "registry-1.docker.io"
+	}
+	return host, fmt.Sprintf("https://%s/v2/%s/manifests/%s", host, repository, reference)
+}
+
+// registryArchitectures fetches a manifest from the registry api, authenticating with
+// the token service of the registry when it asks for one
+func registryArchitectures(manifestURL, auth string) ([]string, error) {
+	resp, err := registryGet(manifestURL, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		token, err := registryToken(resp.Header.Get("WWW-Authenticate"), auth)
+		if err != nil {
+			return nil, err
+		}
+		if resp, err = registryGet(manifestURL, "Bearer "+token); err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("registry responded with status %d", resp.StatusCode)
+	}
+	return manifestArchitectures(data)
+}
+
+// registryGet requests a manifest list from the registry api
+func registryGet(url, authorization string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestTypes, ", "))
+	if len(authorization) > 0 {
+		req.Header.Set("Authorization", authorization)
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	return client.Do(req)
+}
+
+// registryToken gets a token for the bearer challenge of a registry, using basic
+// auth credentials when there are any (anonymous otherwise)
+func registryToken(challenge, auth string) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", fmt.Errorf("registry requires unsupported authentication %q", challenge)
+	}
+	params := map[string]string{}
+	for _, param := range strings.Split(strings.TrimPrefix(challenge, "Bearer "), ",") {
+		if kv := strings.SplitN(strings.TrimSpace(param), "=", 2); len(kv) == 2 {
+			params[kv[0]] = strings.Trim(kv[1], `"`)
+		}
+	}
+	query := url.Values{}
+	for _, key := range []string{"service", "scope"} {
+		if len(params[key]) > 0 {
+			query.Set(key, params[key])
+		}
+	}
+	req, err := http.NewRequest("GET", params["realm"]+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	if len(auth) > 0 {
+		req.Header.Set("Authorization", "Basic "+auth)
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry token service responded with status %d", resp.StatusCode)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if len(body.Token) == 0 {
+		return body.AccessToken, nil
+	}
+	return body.Token, nil
+}
+
+// registryAuth returns the basic auth credentials for a registry from the docker
+// config (DOCKER_CONFIG or ~/.docker), empty when there are none
+func registryAuth(host string) string {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if len(dir) == 0 {
+		dir = filepath.Join(os.Getenv("HOME"), ".docker")
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		return ""
+	}
+	var config struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return ""
+	}
+	if host == "registry-1.docker.io" {
+		host = "https://index.docker.io/v1/"
+	}
+	for _, key := range []string{host, "https://" + host} {
+		if auth, found := config.Auths[key]; found {
+			return auth.Auth
+		}
+	}
+	return ""
+}
+
+// manifestArchitectures returns the architectures of a manifest list
+func manifestArchitectures(data []byte) ([]string, error) {
+	var list struct {
+		Manifests []struct {
+			Platform struct {
+				Architecture string `json:"architecture"`
+			} `json:"platform"`
+		} `json:"manifests"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	if len(list.Manifests) == 0 {
+		return nil, fmt.Errorf("not a multi-arch image, the architecture can't be checked")
+	}
+	var archs []string
+	for _, m := range list.Manifests {
+		if a := m.Platform.Architecture; len(a) > 0 && a != "unknown" && !contains(archs, a) {
+			archs = append(archs, a)
+		}
+	}
+	sort.Strings(archs)
+	return archs, nil
+}
+
+// missingArchitectures returns the required architectures which aren't available
+func missingArchitectures(available, required []string) []string {
+	var missing []string
+	for _, a := range required {
+		if !contains(available, a) && !contains(missing, a) {
+			missing = append(missing, a)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestManifestArchitectures(t *testing.T) {
+	data := []byte(`{
+  "schemaVersion": 2,
+  "mediaType": "application/vnd.docker.distribution.manifest.list.v2+json",
+  "manifests": [
+    {"digest": "sha256:1", "platform": {"architecture": "arm64", "os": "linux"}},
+    {"digest": "sha256:2", "platform": {"architecture": "amd64", "os": "linux"}},
+    {"digest": "sha256:3", "platform": {"architecture": "unknown", "os": "unknown"}}
+  ]
+}`)
+	got, err := manifestArchitectures(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []string{"amd64", "arm64"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+	if _, err := manifestArchitectures([]byte(`{"schemaVersion": 2, "config": {}}`)); err == nil {
+		t.Error("expected an error for a single architecture manifest")
+	}
+}
+
+func TestMissingArchitectures(t *testing.T) {
+	got := missingArchitectures([]string{"amd64"}, []string{"amd64", "arm64", "arm64"})
+	want := []string{"arm64"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+}
+
+func TestWorkloadImages(t *testing.T) {
+	resources := []*ObjectResource{
+		{Kind: "Deployment", Template: []byte("kind: Deployment\nspec:\n  template:\n    spec:\n      initContainers:\n      - image: busybox\n      containers:\n      - image: nginx:1.15\n      - image: busybox\n")},
+		{Kind: "CronJob", Template: []byte("kind: CronJob\nspec:\n  jobTemplate:\n    spec:\n      template:\n        spec:\n          containers:\n          - image: backup:2\n")},
+		{Kind: "ConfigMap", Template: []byte("kind: ConfigMap\ndata:\n  image: ignored\n")},
+	}
+	got, err := workloadImages(resources)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []string{"busybox", "nginx:1.15", "backup:2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+}
+
+func TestRegistryManifestURL(t *testing.T) {
+	cases := []struct {
+		name     string
+		image    string
+		wantHost string
+		wantURL  string
+	}{
+		{name: "Check an official image is from docker hub", image: "nginx:1.15",
+			wantHost: "registry-1.docker.io", wantURL: "https://registry-1.docker.io/v2/library/nginx/manifests/1.15"},
+		{name: "Check an image without a tag is latest", image: "uswitch/kd",
+			wantHost: "registry-1.docker.io", wantURL: "https://registry-1.docker.io/v2/uswitch/kd/manifests/latest"},
+		{name: "Check a registry with a port is used", image: "localhost:5000/app@sha256:abc",
+			wantHost: "localhost:5000", wantURL: "https://localhost:5000/v2/app/manifests/sha256:abc"},
+		{name: "Check a named registry is used", image: "quay.io/ukhomeofficedigital/kd:v1",
+			wantHost: "quay.io", wantURL: "https://quay.io/v2/ukhomeofficedigital/kd/manifests/v1"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			host, url := registryManifestURL(c.image)
+			if host != c.wantHost || url != c.wantURL {
+				t.Errorf("got: %#v %#v\nwant: %#v %#v\n", host, url, c.wantHost, c.wantURL)
+			}
+		})
+	}
+}
+
+func TestRegistryArchitectures(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			if r.URL.Query().Get("scope") != "repository:app:pull" || r.Header.Get("Authorization") != "Basic dXNlcjpwYXNz" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, `{"token": "secret"}`)
+		case r.Header.Get("Authorization") != "Bearer secret":
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="registry",scope="repository:app:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			fmt.Fprint(w, `{"manifests": [{"platform": {"architecture": "amd64"}}]}`)
+		}
+	}))
+	defer server.Close()
+
+	got, err := registryArchitectures(server.URL+"/v2/app/manifests/v1", "dXNlcjpwYXNz")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := []string{"amd64"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+	if _, err := registryArchitectures(server.URL+"/v2/app/manifests/v1", ""); err == nil {
+		t.Error("expected an error without credentials")
+	}
+}
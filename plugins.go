@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+// kindPlugins returns the command to run for each kind with a plugin, from
+// values of the form Kind=command
+func kindPlugins(values []string) (map[string][]string, error) {
+	plugins := map[string][]string{}
+	for _, p := range values {
+		fields := strings.SplitN(p, "=", 2)
+		if len(fields) != 2 || len(strings.Fields(fields[1])) == 0 {
+			return nil, fmt.Errorf("invalid %s %q, expecting Kind=command", FlagKindPlugin, p)
+		}
+		plugins[fields[0]] = strings.Fields(fields[1])
+	}
+	return plugins, nil
+}
+
+// runKindPlugin runs the plugin for a resource with the manifest on stdin, the
+// plugin is responsible for checking the resource has been deployed successfully
+func runKindPlugin(c *cli.Context, r *ObjectResource, command []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.Duration("timeout"))
+	defer cancel()
+
+	namespace := r.Namespace
+	if c.IsSet("namespace") {
+		namespace = c.String("namespace")
+	}
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = append(os.Environ(),
+		"KD_KIND="+r.Kind,
+		"KD_NAME="+r.Name,
+		"KD_NAMESPACE="+namespace,
+		"KD_FILE="+r.FileName,
+	)
+	cmd.Stdin = bytes.NewReader(r.Template)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	logInfo.Printf("running %s plugin %q for %s/%s", r.Kind, command[0], strings.ToLower(r.Kind), r.Name)
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("%s plugin for %q timed out after %s", r.Kind, r.Name, c.Duration("timeout"))
+		}
+		return fmt.Errorf("%s plugin for %q failed: %s", r.Kind, r.Name, err)
+	}
+	return nil
+}
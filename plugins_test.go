@@ -0,0 +1,25 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKindPlugins(t *testing.T) {
+	got, err := kindPlugins([]string{"FlinkDeployment=./check-flink.sh --wait", "Database=dbctl rollout"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := map[string][]string{
+		"FlinkDeployment": {"./check-flink.sh", "--wait"},
+		"Database":        {"dbctl", "rollout"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+	for _, invalid := range []string{"FlinkDeployment", "FlinkDeployment= "} {
+		if _, err := kindPlugins([]string{invalid}); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+// podState is the readiness of a pod's containers
+type podState struct {
+	Name     string
+	Ready    bool
+	Restarts int
+}
+
+// dumpPodLogs logs the output of the pods of a workload which aren't ready
+func dumpPodLogs(c *cli.Context, r *ObjectResource, lines int) {
+	selector := podSelector(r)
+	if lines < 1 || len(selector) == 0 {
+		return
+	}
+	out, err := runResourceKubeCmd(c, r, "get", "pods", "-l", selector, "--no-headers", "-o",
+		"custom-columns=NAME:.metadata.name,READY:.status.containerStatuses[*].ready,RESTARTS:.status.containerStatuses[*].restartCount")
+	if err != nil {
+		logError.Printf("unable to list the pods for %s %q: %s", r.Kind, r.Name, err)
+		return
+	}
+	for _, pod := range parsePodStates(out) {
+		if pod.Ready {
+			continue
+		}
+		args := []string{"logs", pod.Name, "--all-containers", "--tail", strconv.Itoa(lines)}
+		if pod.Restarts > 0 {
+			// The current container may not have logged anything yet
+			args = append(args, "--previous")
+		}
+		logs, err := runResourceKubeCmd(c, r, args...)
+		if err != nil {
+			logError.Printf("unable to get the logs for pod %s: %s", pod.Name, err)
+			continue
+		}
+		logError.Printf("logs for pod %s which isn't ready (restarts: %d):\n%s", pod.Name, pod.Restarts, logs)
+	}
+}
+
+// podSelector returns the label selector for the pods of a workload
+func podSelector(r *ObjectResource) string {
+	if r.Kind == "Job" {
+		return "job-name=" + r.Name
+	}
+	labels := r.Selector.MatchLabels
+	if !contains([]string{"Deployment", "StatefulSet", "DaemonSet"}, r.Kind) || len(labels) == 0 {
+		return ""
+	}
+	var parts []string
+	for k, v := range labels {
+		parts = append(parts, k+"="+v)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// parsePodStates parses the pod name, container readiness and restart columns
+func parsePodStates(out string) []podState {
+	var pods []podState
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		pod := podState{Name: fields[0], Ready: true}
+		for _, ready := range strings.Split(fields[1], ",") {
+			if ready != "true" {
+				pod.Ready = false
+			}
+		}
+		for _, count := range strings.Split(fields[2], ",") {
+			n, _ := strconv.Atoi(count)
+			pod.Restarts += n
+		}
+		pods = append(pods, pod)
+	}
+	return pods
+}
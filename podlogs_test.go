@@ -0,0 +1,54 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePodStates(t *testing.T) {
+	out := "app-1   true,true    0,0\napp-2   true,false   0,3\napp-3   <none>       <none>\n"
+	want := []podState{
+		{Name: "app-1", Ready: true},
+		{Name: "app-2", Restarts: 3},
+		{Name: "app-3"},
+	}
+	got := parsePodStates(out)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+}
+
+func TestPodSelector(t *testing.T) {
+	cases := []struct {
+		name string
+		r    *ObjectResource
+		want string
+	}{
+		{
+			name: "Check a job uses the job name",
+			r:    &ObjectResource{Kind: "Job", ObjectMeta: ObjectMeta{Name: "migrate"}},
+			want: "job-name=migrate",
+		},
+		{
+			name: "Check a deployment uses the match labels",
+			r: &ObjectResource{Kind: "Deployment", ObjectSpec: ObjectSpec{
+				Selector: LabelSelector{MatchLabels: map[string]string{"tier": "web", "app": "api"}},
+			}},
+			want: "app=api,tier=web",
+		},
+		{
+			name: "Check other kinds have no selector",
+			r:    &ObjectResource{Kind: "Certificate"},
+			want: "",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := podSelector(c.r)
+			if got != c.want {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
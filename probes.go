@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+// probe is a health endpoint kd checks on each pod of a workload
+type probe struct {
+	port int
+	// path is the http path or grpc service name
+	path string
+}
+
+// readyPod is a pod of a workload with all of its containers ready
+type readyPod struct {
+	name      string
+	namespace string
+}
+
+// workloadProbes returns the http and grpc probes set by annotations on a workload
+func workloadProbes(r *ObjectResource) (httpProbe, grpcProbe *probe, err error) {
+	if value, found := r.Annotations[AnnotationProbeHTTP]; found {
+		if httpProbe, err = parseProbe(value, "/"); err != nil {
+			return nil, nil, fmt.Errorf("invalid %s annotation on %s: %s", AnnotationProbeHTTP, resourceRef(r), err)
+		}
+	}
+	if value, found := r.Annotations[AnnotationProbeGRPC]; found {
+		if grpcProbe, err = parseProbe(value, ""); err != nil {
+			return nil, nil, fmt.Errorf("invalid %s annotation on %s: %s", AnnotationProbeGRPC, resourceRef(r), err)
+		}
+	}
+	return httpProbe, grpcProbe, nil
+}
+
+// parseProbe parses a probe annotation of the form PORT[/PATH]
+func parseProbe(value, defaultPath string) (*probe, error) {
+	parts := strings.SplitN(strings.TrimSpace(value), "/", 2)
+	port, err := strconv.Atoi(parts[0])
+	if err != nil || port < 1 || port > 65535 {
+		return nil, fmt.Errorf("expecting PORT[/PATH] but found %q", value)
+	}
+	p := &probe{port: port, path: defaultPath}
+	if len(parts) == 2 {
+		if len(defaultPath) > 0 {
+			p.path = "/" + parts[1]
+		} else {
+			p.path = parts[1]
+		}
+	}
+	return p, nil
+}
+
+// probeWorkload checks the probes set on a workload against each of its ready pods,
+// returning a description of any failures
+func probeWorkload(c *cli.Context, r *ObjectResource) (string, error) {
+	httpProbe, grpcProbe, err := workloadProbes(r)
+	if err != nil || (httpProbe == nil && grpcProbe == nil) {
+		return "", err
+	}
+	selector := podSelector(r)
+	if len(selector) == 0 {
+		return "", nil
+	}
+	out, err := runResourceKubeCmd(c, r, "get", "pods", "-l", selector, "--no-headers", "-o",
+		"custom-columns=NAME:.metadata.name,NAMESPACE:.metadata.namespace,READY:.status.containerStatuses[*].ready")
+	if err != nil {
+		return "", err
+	}
+	var failures []string
+	for _, pod := range parseReadyPods(out) {
+		if httpProbe != nil {
+			if _, err := runKubeCmd(c, "get", "--raw", proxyPath(pod, httpProbe)); err != nil {
+				failures = append(failures, fmt.Sprintf("pod %s http probe on port %d: %s", pod.name, httpProbe.port, strings.TrimSpace(err.Error())))
+			}
+		}
+		if grpcProbe != nil {
+			if err := probeGRPC(c, pod, grpcProbe); err != nil {
+				failures = append(failures, fmt.Sprintf("pod %s grpc probe on port %d: %s", pod.name, grpcProbe.port, err))
+			}
+		}
+	}
+	return strings.Join(failures, ", "), nil
+}
+
+// parseReadyPods parses the pod name, namespace and container readiness columns,
+// returning the pods with every container ready
+func parseReadyPods(out string) []readyPod {
+	var pods []readyPod
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		ready := true
+		for _, r := range strings.Split(fields[2], ",") {
+			if r != "true" {
+				ready = false
+			}
+		}
+		if ready {
+			pods = append(pods, readyPod{name: fields[0], namespace: fields[1]})
+		}
+	}
+	return pods
+}
+
+// proxyPath is the api server path proxying an http request to a pod
+func proxyPath(pod readyPod, p *probe) string {
+	return fmt.Sprintf("/api/v1/namespaces/%s/pods/%s:%d/proxy%s", pod.namespace, pod.name, p.port, p.path)
+}
+
+// probeGRPC port forwards to a pod and checks it with the grpc health checking protocol
+func probeGRPC(c *cli.Context, pod readyPod, p *probe) error {
+	cmd, err := newKubeCmd(c, []string{"port-forward", "--namespace=" + pod.namespace,
+		"pod/" + pod.name, ":" + strconv.Itoa(p.port)}, false)
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+	forwarded := make(chan string, 1)
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			if addr := forwardedAddress(scanner.Text()); len(addr) > 0 {
+				forwarded <- addr
+				return
+			}
+		}
+	}()
+	var addr string
+	select {
+	case addr = <-forwarded:
+	case <-time.After(10 * time.Second):
+		return fmt.Errorf("port forward to pod %s was not ready after 10s", pod.name)
+	}
+	args := []string{"-addr=" + addr}
+	if len(p.path) > 0 {
+		args = append(args, "-service="+p.path)
+	}
+	out, err := exec.Command("grpc_health_probe", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// forwardedAddress returns the local address from kubectl port-forward output e.g.
+// "Forwarding from 127.0.0.1:54321 -> 9090"
+func forwardedAddress(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || fields[0] != "Forwarding" || !strings.HasPrefix(fields[2], "127.0.0.1:") {
+		return ""
+	}
+	return fields[2]
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseProbe(t *testing.T) {
+	cases := []struct {
+		name        string
+		input       string
+		defaultPath string
+		want        *probe
+		wantErr     bool
+	}{
+		{
+			name:        "Check an http port and path are parsed",
+			input:       "8080/healthz/ready",
+			defaultPath: "/",
+			want:        &probe{port: 8080, path: "/healthz/ready"},
+		},
+		{
+			name:        "Check an http port defaults to the root path",
+			input:       "8080",
+			defaultPath: "/",
+			want:        &probe{port: 8080, path: "/"},
+		},
+		{
+			name:  "Check a grpc port and service are parsed",
+			input: "9090/orders.v1.Orders",
+			want:  &probe{port: 9090, path: "orders.v1.Orders"},
+		},
+		{
+			name:    "Check a named port is an error",
+			input:   "http/healthz",
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parseProbe(c.input, c.defaultPath)
+			if c.wantErr {
+				if err == nil {
+					t.Errorf("expected an error for %q", c.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseReadyPods(t *testing.T) {
+	out := "api-1   apps   true,true\napi-2   apps   true,false\napi-3   apps   <none>\n"
+	got := parseReadyPods(out)
+	want := []readyPod{{name: "api-1", namespace: "apps"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+	if path, want := proxyPath(got[0], &probe{port: 8080, path: "/healthz"}), "/api/v1/namespaces/apps/pods/api-1:8080/proxy/healthz"; path != want {
+		t.Errorf("got: %#v\nwant: %#v\n", path, want)
+	}
+}
+
+func TestForwardedAddress(t *testing.T) {
+	cases := map[string]string{
+		"Forwarding from 127.0.0.1:54321 -> 9090": "127.0.0.1:54321",
+		"Forwarding from [::1]:54321 -> 9090":     "",
+		"Handling connection for 54321":           "",
+	}
+	for line, want := range cases {
+		if got := forwardedAddress(line); got != want {
+			t.Errorf("got: %#v\nwant: %#v\n", got, want)
+		}
+	}
+}
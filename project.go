@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// DefaultProjectFile is the project file read from the working directory when it exists
+const DefaultProjectFile = "kd.yaml"
+
+// projectSettings are the defaults a project file sets for the kd flags
+type projectSettings struct {
+	Files     []string          `yaml:"files"`
+	Namespace string            `yaml:"namespace"`
+	Context   string            `yaml:"context"`
+	Config    []string          `yaml:"config"`
+	Values    []string          `yaml:"values"`
+	Timeout   string            `yaml:"timeout"`
+	Variables map[string]string `yaml:"variables"`
+	Ignore    []string          `yaml:"ignore"`
+	// Derived are values computed from the variables, in order
+	Derived yaml.MapSlice `yaml:"derived"`
+	// Extends is the environment these settings are layered on
+	Extends string `yaml:"extends"`
+}
+
+// projectFile is a kd.yaml, the settings for a project with any overrides per environment
+type projectFile struct {
+	projectSettings `yaml:",inline"`
+	Environments    map[string]projectSettings `yaml:"environments"`
+}
+
+// projectFlag is the value(s) a project sets for a flag
+type projectFlag struct {
+	name   string
+	values []string
+}
+
+// applyProject sets the flags which aren't set on the command line (or environment)
+// from the project file and the selected environment
+func applyProject(c *cli.Context) error {
+	p, err := loadProject(c.String(FlagProject), c.IsSet(FlagProject))
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		if c.IsSet(FlagEnv) {
+			return fmt.Errorf("no project file %s to select the environment %q from", c.String(FlagProject), c.String(FlagEnv))
+		}
+		return nil
+	}
+	settings, err := p.environment(c.String(FlagEnv))
+	if err != nil {
+		return err
+	}
+	for _, f := range settings.flags() {
+		if c.IsSet(f.name) {
+			continue
+		}
+		for _, v := range f.values {
+			if err := c.Set(f.name, v); err != nil {
+				return fmt.Errorf("invalid %s %q in project file %s: %s", f.name, v, c.String(FlagProject), err)
+			}
+		}
+	}
+	// The environment is available to templates as .KD_ENV
+	if c.IsSet(FlagEnv) {
+		os.Setenv("KD_ENV", c.String(FlagEnv))
+	}
+	// Like the config files, variables from the environment take precedence
+	for k, v := range settings.Variables {
+		if _, set := os.LookupEnv(k); !set {
+			os.Setenv(k, v)
+		}
+	}
+	return nil
+}
+
+// loadProject reads a project file, paths in it are relative to the file. A missing
+// or unreadable file is only an error when it was asked for, a kd.yaml found in the
+// working directory may belong to something else.
+func loadProject(path string, required bool) (*projectFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !required {
+			if !os.IsNotExist(err) {
+				logWarn.Printf("ignoring the project file %s: %s", path, err)
+			}
+			return nil, nil
+		}
+		return nil, err
+	}
+	p := &projectFile{}
+	if err := yaml.UnmarshalStrict(data, p); err != nil {
+		if !required {
+			logWarn.Printf("ignoring the project file %s, set --%s to use it: %s", path, FlagProject, err)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("problem reading project file %s: %s", path, err)
+	}
+	dir := filepath.Dir(path)
+	p.projectSettings.resolve(dir)
+	for name, env := range p.Environments {
+		env.resolve(dir)
+		p.Environments[name] = env
+	}
+	return p, nil
+}
+
+// resolve makes the paths in the settings relative to a directory
+func (s *projectSettings) resolve(dir string) {
+	for _, paths := range [][]string{s.Files, s.Config, s.Values} {
+		for i, fn := range paths {
+			if !filepath.IsAbs(fn) {
+				paths[i] = filepath.Join(dir, fn)
+			}
+		}
+	}
+}
+
+// environment returns the project settings with those of an environment, and any
+// environments it extends, layered on top
+func (p *projectFile) environment(name string) (projectSettings, error) {
+	if len(name) == 0 {
+		return p.projectSettings, nil
+	}
+	var chain []projectSettings
+	seen := map[string]bool{}
+	for next := name; len(next) > 0; next = chain[len(chain)-1].Extends {
+		if seen[next] {
+			return projectSettings{}, fmt.Errorf("environment %q extends itself through %q", name, next)
+		}
+		seen[next] = true
+		env, found := p.Environments[next]
+		if !found {
+			var names []string
+			for n := range p.Environments {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			return projectSettings{}, fmt.Errorf("unknown environment %q, the project defines: %v", next, names)
+		}
+		chain = append(chain, env)
+	}
+	settings := p.projectSettings
+	for i := len(chain) - 1; i >= 0; i-- {
+		settings = settings.layer(chain[i])
+	}
+	return settings, nil
+}
+
+// layer returns the settings with any set in the overlay replacing them, the
+// variables are merged
+func (s projectSettings) layer(overlay projectSettings) projectSettings {
+	if len(overlay.Files) > 0 {
+		s.Files = overlay.Files
+	}
+	if len(overlay.Namespace) > 0 {
+		s.Namespace = overlay.Namespace
+	}
+	if len(overlay.Context) > 0 {
+		s.Context = overlay.Context
+	}
+	if len(overlay.Config) > 0 {
+		s.Config = overlay.Config
+	}
+	if len(overlay.Values) > 0 {
+		s.Values = overlay.Values
+	}
+	if len(overlay.Timeout) > 0 {
+		s.Timeout = overlay.Timeout
+	}
+	if len(overlay.Ignore) > 0 {
+		s.Ignore = overlay.Ignore
+	}
+	variables := map[string]string{}
+	for k, v := range s.Variables {
+		variables[k] = v
+	}
+	for k, v := range overlay.Variables {
+		variables[k] = v
+	}
+	s.Variables = variables
+	derived := append(yaml.MapSlice{}, s.Derived...)
+	for _, item := range overlay.Derived {
+		derived = mapSliceSet(derived, fmt.Sprint(item.Key), item.Value)
+	}
+	s.Derived = derived
+	return s
+}
+
+// flags are the flag values for the settings which are set
+func (s projectSettings) flags() []projectFlag {
+	var flags []projectFlag
+	add := func(name string, values ...string) {
+		if len(values) > 0 && len(values[0]) > 0 {
+			flags = append(flags, projectFlag{name: name, values: values})
+		}
+	}
+	add("file", s.Files...)
+	add("namespace", s.Namespace)
+	add("context", s.Context)
+	add("config", s.Config...)
+	add(FlagValues, s.Values...)
+	add("timeout", s.Timeout)
+	add(FlagDiffIgnore, s.Ignore...)
+	var derived []string
+	for _, item := range s.Derived {
+		derived = append(derived, fmt.Sprintf("%v=%v", item.Key, item.Value))
+	}
+	add(FlagDerive, derived...)
+	return flags
+}
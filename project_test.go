@@ -0,0 +1,87 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLoadProject(t *testing.T) {
+	if p, err := loadProject("test/TestLoadProject/missing.yaml", false); p != nil || err != nil {
+		t.Errorf("expected an optional missing project to be ignored, got: %v %v", p, err)
+	}
+	if _, err := loadProject("test/TestLoadProject/missing.yaml", true); err == nil {
+		t.Error("expected an error for a required missing project")
+	}
+	if p, err := loadProject("test/TestLoadProject/other.yaml", false); p != nil || err != nil {
+		t.Errorf("expected an optional project which isn't a kd project to be ignored, got: %v %v", p, err)
+	}
+	if _, err := loadProject("test/TestLoadProject/other.yaml", true); err == nil {
+		t.Error("expected an error for a required project which isn't a kd project")
+	}
+	p, err := loadProject("test/TestLoadProject/kd.yaml", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	cases := []struct {
+		env       string
+		flags     []projectFlag
+		variables map[string]string
+	}{
+		{
+			env: "",
+			flags: []projectFlag{
+				{name: "file", values: []string{"test/TestLoadProject/kube"}},
+				{name: "namespace", values: []string{"app"}},
+				{name: "config", values: []string{"test/TestLoadProject/dev.env"}},
+				{name: "timeout", values: []string{"5m"}},
+				{name: FlagDiffIgnore, values: []string{"Deployment:spec.replicas"}},
+				{name: FlagDerive, values: []string{"IMAGE=${REGISTRY}/${APP | lower}:${TAG}", "RELEASE_ID=${IMAGE | sha256 | trunc 8}"}},
+			},
+			variables: map[string]string{"REPLICAS": "1", "LOG_LEVEL": "debug"},
+		},
+		{
+			env: "production",
+			flags: []projectFlag{
+				{name: "file", values: []string{"test/TestLoadProject/kube"}},
+				{name: "namespace", values: []string{"app"}},
+				{name: "context", values: []string{"prod"}},
+				{name: "config", values: []string{"test/TestLoadProject/prod.env"}},
+				{name: "timeout", values: []string{"15m"}},
+				{name: FlagDiffIgnore, values: []string{"Deployment:spec.replicas"}},
+				{name: FlagDerive, values: []string{"IMAGE=${REGISTRY}/${APP | lower}:${TAG}-prod", "RELEASE_ID=${IMAGE | sha256 | trunc 8}"}},
+			},
+			variables: map[string]string{"REPLICAS": "6", "LOG_LEVEL": "debug"},
+		},
+		{
+			env: "canary",
+			flags: []projectFlag{
+				{name: "file", values: []string{"test/TestLoadProject/kube"}},
+				{name: "namespace", values: []string{"canary"}},
+				{name: "context", values: []string{"prod"}},
+				{name: "config", values: []string{"test/TestLoadProject/prod.env"}},
+				{name: "timeout", values: []string{"15m"}},
+				{name: FlagDiffIgnore, values: []string{"Deployment:spec.replicas"}},
+				{name: FlagDerive, values: []string{"IMAGE=${REGISTRY}/${APP | lower}:${TAG}-prod", "RELEASE_ID=${IMAGE | sha256 | trunc 8}"}},
+			},
+			variables: map[string]string{"REPLICAS": "1", "LOG_LEVEL": "debug"},
+		},
+	}
+	for _, c := range cases {
+		settings, err := p.environment(c.env)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got := settings.flags(); !reflect.DeepEqual(got, c.flags) {
+			t.Errorf("got: %#v\nwant: %#v\n", got, c.flags)
+		}
+		if !reflect.DeepEqual(settings.Variables, c.variables) {
+			t.Errorf("got: %#v\nwant: %#v\n", settings.Variables, c.variables)
+		}
+	}
+	if _, err := p.environment("staging"); err == nil {
+		t.Error("expected an error for an unknown environment")
+	}
+	if _, err := p.environment("loop"); err == nil {
+		t.Error("expected an error for an environment extending itself")
+	}
+}
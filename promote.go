@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// kubeEnv is an environment to promote between, a kube context and optional namespace
+type kubeEnv struct {
+	context   string
+	namespace string
+}
+
+// promoteFrom is the environment images are promoted from (nil when not promoting)
+var promoteFrom *kubeEnv
+
+// promote deploys the resources to an environment with the image digests running in another
+func promote(c *cli.Context) error {
+	if !c.IsSet(FlagPromoteFrom) || !c.IsSet(FlagPromoteTo) {
+		return fmt.Errorf("the environments must be specified with --%s and --%s", FlagPromoteFrom, FlagPromoteTo)
+	}
+	from, err := parseKubeEnv(c.String(FlagPromoteFrom))
+	if err != nil {
+		return err
+	}
+	to, err := parseKubeEnv(c.String(FlagPromoteTo))
+	if err != nil {
+		return err
+	}
+	if err := c.Set("context", to.context); err != nil {
+		return err
+	}
+	if len(to.namespace) > 0 {
+		if err := c.Set("namespace", to.namespace); err != nil {
+			return err
+		}
+	}
+	promoteFrom = &from
+	return run(c)
+}
+
+// parseKubeEnv parses an environment of the form CONTEXT[/NAMESPACE]
+func parseKubeEnv(value string) (kubeEnv, error) {
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts[0]) == 0 {
+		return kubeEnv{}, fmt.Errorf("invalid environment %q, expecting CONTEXT[/NAMESPACE]", value)
+	}
+	env := kubeEnv{context: parts[0]}
+	if len(parts) == 2 {
+		env.namespace = parts[1]
+	}
+	return env, nil
+}
+
+// String formats an environment for messages
+func (e kubeEnv) String() string {
+	if len(e.namespace) == 0 {
+		return e.context
+	}
+	return e.context + "/" + e.namespace
+}
+
+// promoteImages pins the images of each workload to the digests running in an environment
+func promoteImages(c *cli.Context, resources []*ObjectResource, from kubeEnv) error {
+	for _, r := range resources {
+		selector := podSelector(r)
+		if len(selector) == 0 {
+			continue
+		}
+		// Init containers have image ids too, even once they have completed
+		args := []string{"get", "pods", "-l", selector, "--context=" + from.context, "-o",
+			`jsonpath={range .items[*].status.initContainerStatuses[*]}{.name}{" "}{.imageID}{"\n"}{end}` +
+				`{range .items[*].status.containerStatuses[*]}{.name}{" "}{.imageID}{"\n"}{end}`}
+		if len(from.namespace) > 0 {
+			args = append(args, "--namespace="+from.namespace)
+		}
+		// The namespace of the target environment doesn't apply to the source
+		cmd, err := newKubeCmdScoped(c, args, false, false, false)
+		if err != nil {
+			return err
+		}
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return fmt.Errorf("problem finding the images of %s in %s: %s %s", resourceRef(r), from, err, strings.TrimSpace(stderr.String()))
+		}
+		digests := parseImageIDs(string(out))
+		if len(digests) == 0 {
+			return fmt.Errorf("no running pods found for %s in %s to promote", resourceRef(r), from)
+		}
+		template, err := pinImages(r.Template, r.Kind, digests)
+		if err != nil {
+			return fmt.Errorf("problem promoting %s from %s: %s", resourceRef(r), from, err)
+		}
+		r.Template = template
+		logInfo.Printf("promoting the images of %s from %s", resourceRef(r), from)
+	}
+	return nil
+}
+
+// parseImageIDs returns the image digest of each container name from the
+// container statuses e.g. "app docker-pullable://quay.io/app@sha256:..."
+func parseImageIDs(out string) map[string]string {
+	digests := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if i := strings.LastIndex(fields[1], "@"); i >= 0 {
+			digests[fields[0]] = fields[1][i+1:]
+		}
+	}
+	return digests
+}
+
+// pinImages replaces the tag of each container image in a workload's pod template
+// with the digest running for the container
+func pinImages(template []byte, kind string, digests map[string]string) ([]byte, error) {
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(template, &doc); err != nil {
+		return nil, err
+	}
+	spec := doc
+	for _, key := range podTemplatePath(kind, "spec") {
+		value, _ := mapSliceGet(spec, key)
+		if spec, _ = value.(yaml.MapSlice); spec == nil {
+			return template, nil
+		}
+	}
+	for _, field := range []string{"initContainers", "containers"} {
+		value, _ := mapSliceGet(spec, field)
+		containers, _ := value.([]interface{})
+		for i, item := range containers {
+			ctr, _ := item.(yaml.MapSlice)
+			name, _ := mapSliceGet(ctr, "name")
+			image, _ := mapSliceGet(ctr, "image")
+			digest, found := digests[fmt.Sprint(name)]
+			if !found {
+				return nil, fmt.Errorf("container %v isn't running", name)
+			}
+			repository, _, _ := splitImage(fmt.Sprint(image))
+			containers[i] = mapSliceSet(ctr, "image", repository+"@"+digest)
+		}
+	}
+	return yaml.Marshal(doc)
+}
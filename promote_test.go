@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKubeEnv(t *testing.T) {
+	cases := []struct {
+		input   string
+		want    kubeEnv
+		wantErr bool
+	}{
+		{input: "staging", want: kubeEnv{context: "staging"}},
+		{input: "prod/payments", want: kubeEnv{context: "prod", namespace: "payments"}},
+		{input: "/payments", wantErr: true},
+	}
+
+	for _, c := range cases {
+		got, err := parseKubeEnv(c.input)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("expected an error for %q", c.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got != c.want {
+			t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+		}
+	}
+}
+
+func TestParseImageIDs(t *testing.T) {
+	out := "app docker-pullable://quay.io/myapp@sha256:aaa\nproxy docker.io/envoyproxy/envoy@sha256:bbb\napp docker-pullable://quay.io/myapp@sha256:aaa\nsidecar sha256:ccc\n"
+	got := parseImageIDs(out)
+	want := map[string]string{"app": "sha256:aaa", "proxy": "sha256:bbb"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+}
+
+func TestPinImages(t *testing.T) {
+	template := "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: myapp\nspec:\n  template:\n    spec:\n      containers:\n      - name: app\n        image: registry:5000/myapp:v1.3.0\n"
+	got, err := pinImages([]byte(template), "Deployment", map[string]string{"app": "sha256:aaa"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: myapp\nspec:\n  template:\n    spec:\n      containers:\n      - name: app\n        image: registry:5000/myapp@sha256:aaa\n"
+	if string(got) != want {
+		t.Errorf("got: %#v\nwant: %#v\n", string(got), want)
+	}
+	if _, err := pinImages([]byte(template), "Deployment", map[string]string{"proxy": "sha256:bbb"}); err == nil {
+		t.Error("expected an error for a container which isn't running")
+	}
+}
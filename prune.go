@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+// parseSelector parses an equality based label selector e.g. app=foo,team=bar
+func parseSelector(selector string) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, part := range strings.Split(selector, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 || len(kv[0]) == 0 || len(kv[1]) == 0 {
+			return nil, fmt.Errorf("invalid selector %q, expecting key=value[,key=value]", selector)
+		}
+		labels[kv[0]] = kv[1]
+	}
+	return labels, nil
+}
+
+// markPrune labels the resources with the prune selector so they can be found later
+func markPrune(resources []*ObjectResource, labels map[string]string) error {
+	for _, r := range resources {
+		if err := addMetadata(r, "labels", labels); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prune deletes the resources with the prune selector which weren't deployed
+func prune(c *cli.Context, resources []*ObjectResource) error {
+	out, err := runKubeCmd(c, "get", c.String(FlagPruneKinds),
+		"-l", c.String(FlagPruneSelector),
+		"-o", "custom-columns=KIND:.kind,NAME:.metadata.name", "--no-headers")
+	if err != nil {
+		return err
+	}
+	for _, ref := range pruneCandidates(out, resources) {
+		logInfo.Printf("pruning %s which is no longer part of the release", ref)
+		if _, err := runKubeCmd(c, "delete", ref); err != nil {
+			return fmt.Errorf("problem pruning %s: %s", ref, err)
+		}
+	}
+	return nil
+}
+
+// pruneCandidates returns the kind/name of the live resources which weren't deployed
+func pruneCandidates(out string, resources []*ObjectResource) []string {
+	deployed := map[string]bool{}
+	for _, r := range resources {
+		deployed[resourceRef(r)] = true
+	}
+	var candidates []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		// Only resources in the namespace of the release are pruned
+		if isClusterScoped(fields[0]) {
+			continue
+		}
+		ref := strings.ToLower(fields[0]) + "/" + fields[1]
+		if !deployed[ref] {
+			candidates = append(candidates, ref)
+		}
+	}
+	return candidates
+}
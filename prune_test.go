@@ -0,0 +1,35 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSelector(t *testing.T) {
+	got, err := parseSelector("app=foo, team=bar")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := map[string]string{"app": "foo", "team": "bar"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+	for _, invalid := range []string{"", "app", "app!=foo,", "app="} {
+		if _, err := parseSelector(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestPruneCandidates(t *testing.T) {
+	resources := []*ObjectResource{
+		{Kind: "Deployment", ObjectMeta: ObjectMeta{Name: "api"}},
+		{Kind: "Service", ObjectMeta: ObjectMeta{Name: "api"}},
+	}
+	out := "Deployment   api\nDeployment   old-worker\nService      api\nConfigMap    api\nNamespace    old-team\n"
+	got := pruneCandidates(out, resources)
+	want := []string{"deployment/old-worker", "configmap/api"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+}
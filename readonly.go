@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// readOnlyVerbs are the kubectl commands which never change the cluster. proxy
+// isn't one, as it forwards any request (including writes) to the api server
+var readOnlyVerbs = []string{
+	"api-resources", "api-versions", "cluster-info", "describe", "diff",
+	"events", "explain", "get", "logs", "port-forward", "top", "version",
+}
+
+// checkReadOnly fails when a kubectl command could change the cluster
+func checkReadOnly(args []string) error {
+	verb := ""
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			verb = arg
+			break
+		}
+	}
+	if contains(readOnlyVerbs, verb) {
+		return nil
+	}
+	if verb == "auth" && contains(args, "can-i") {
+		return nil
+	}
+	// A server side dry run is validated by the api server without being persisted
+	if verb == "apply" && contains(args, "--dry-run=server") {
+		return nil
+	}
+	return fmt.Errorf("refusing to run 'kubectl %s' with --%s, it could change the cluster", strings.Join(args, " "), FlagReadOnly)
+}
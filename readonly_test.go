@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func TestCheckReadOnly(t *testing.T) {
+	cases := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{
+			name: "Check get is allowed",
+			args: []string{"get", "deployment/app", "-o", "yaml"},
+		},
+		{
+			name: "Check diff is allowed after flags",
+			args: []string{"--namespace=test", "diff", "-f", "-"},
+		},
+		{
+			name: "Check auth can-i is allowed",
+			args: []string{"auth", "can-i", "create", "deployments"},
+		},
+		{
+			name: "Check port-forward is allowed",
+			args: []string{"port-forward", "svc/app", "8080:80"},
+		},
+		{
+			name:    "Check proxy is refused",
+			args:    []string{"proxy", "--port=8001"},
+			wantErr: true,
+		},
+		{
+			name:    "Check apply is refused",
+			args:    []string{"apply", "-f", "-"},
+			wantErr: true,
+		},
+		{
+			name: "Check a server side dry run apply is allowed",
+			args: []string{"apply", "--dry-run=server", "-o", "yaml", "-f", "-"},
+		},
+		{
+			name:    "Check a flag value before the command is refused",
+			args:    []string{"-n", "prod", "get", "pods"},
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := checkReadOnly(c.args)
+			if (err != nil) != c.wantErr {
+				t.Errorf("got: %#v\nwant error: %#v\n", err, c.wantErr)
+			}
+		})
+	}
+}
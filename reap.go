@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+// noValue is how kubectl shows a missing custom column value
+const noValue = "<none>"
+
+// markExpiry will label resources as managed by kd and annotate when they expire
+func markExpiry(resources []*ObjectResource, ttl time.Duration) error {
+	expires := time.Now().Add(ttl).UTC().Format(time.RFC3339)
+	for _, r := range resources {
+		if err := addMetadata(r, "labels", map[string]string{LabelManaged: "true"}); err != nil {
+			return err
+		}
+		if err := addMetadata(r, "annotations", map[string]string{AnnotationExpires: expires}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reap will delete any kd managed resources which have expired
+func reap(c *cli.Context) error {
+	if c.Bool("debug") {
+		logDebug = logDebugIf
+	}
+	args := []string{
+		"get", c.String(FlagReapKinds),
+		"-l", LabelManaged + "=true",
+		"-o", "custom-columns=KIND:.kind,NAMESPACE:.metadata.namespace,NAME:.metadata.name," +
+			"EXPIRES:.metadata.annotations." + jsonPathKey(AnnotationExpires),
+		"--no-headers",
+	}
+	if !c.IsSet("namespace") {
+		args = append(args, "--all-namespaces")
+	}
+	out, err := runKubeCmd(c, args...)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 4 {
+			continue
+		}
+		kind, namespace, name, expiry := fields[0], fields[1], fields[2], fields[3]
+		if expiry == noValue {
+			logDebug.Printf("skipping %s/%s as it has no expiry", kind, name)
+			continue
+		}
+		expires, err := time.Parse(time.RFC3339, expiry)
+		if err != nil {
+			logError.Printf("invalid expiry %q for %s/%s: %s", expiry, kind, name, err)
+			continue
+		}
+		if now.Before(expires) {
+			logDebug.Printf("skipping %s/%s as it expires at %s", kind, name, expiry)
+			continue
+		}
+		if dryRun {
+			logInfo.Printf("dry run, skipping reap of expired %s/%s", strings.ToLower(kind), name)
+			continue
+		}
+		logInfo.Printf("reaping %s/%s which expired at %s", strings.ToLower(kind), name, expiry)
+		deleteArgs := []string{"delete", kind + "/" + name}
+		if namespace != noValue {
+			deleteArgs = append(deleteArgs, "--namespace="+namespace)
+		}
+		if _, err := runKubeCmd(c, deleteArgs...); err != nil {
+			return fmt.Errorf("problem reaping %s/%s: %s", kind, name, err)
+		}
+	}
+	return scanner.Err()
+}
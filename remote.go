@@ -0,0 +1,92 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// remoteManifests are the manifests fetched for --file urls, by url
+var remoteManifests = map[string][]byte{}
+
+// isRemoteManifest checks if a --file is a url
+func isRemoteManifest(fn string) bool {
+	return strings.HasPrefix(fn, "https://") || strings.HasPrefix(fn, "http://")
+}
+
+// fetchRemoteManifest fetches a manifest from a url so it's rendered like a file,
+// returning the url it's known by (without any checksum)
+func fetchRemoteManifest(rawURL string, headers []string) (string, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	name, data, err := fetchManifest(client, rawURL, headers)
+	if err != nil {
+		return "", err
+	}
+	remoteManifests[name] = data
+	return name, nil
+}
+
+// fetchManifest gets a manifest over https with the headers, verifying it against a
+// sha256 checksum given as the url fragment e.g. https://example.com/app.yaml#sha256=...
+func fetchManifest(client *http.Client, rawURL string, headers []string) (string, []byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid manifest url %q: %s", rawURL, err)
+	}
+	if u.Scheme != "https" {
+		return "", nil, fmt.Errorf("manifest url %q must use https", rawURL)
+	}
+	checksum := u.Fragment
+	u.Fragment = ""
+	name := u.String()
+
+	req, err := http.NewRequest("GET", name, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, header := range headers {
+		kv := strings.SplitN(header, ":", 2)
+		if len(kv) != 2 || len(strings.TrimSpace(kv[0])) == 0 {
+			return "", nil, fmt.Errorf("invalid %s %q, expecting NAME: VALUE", FlagURLHeader, header)
+		}
+		req.Header.Add(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+	}
+	logDebug.Printf("fetching manifest %s", name)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", nil, fmt.Errorf("problem fetching manifest %s: %s", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return "", nil, fmt.Errorf("problem fetching manifest %s: status %s", name, resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, fmt.Errorf("problem fetching manifest %s: %s", name, err)
+	}
+	if len(checksum) > 0 {
+		if err := verifyChecksum(data, checksum); err != nil {
+			return "", nil, fmt.Errorf("manifest %s %s", name, err)
+		}
+	}
+	logInfo.Printf("fetched manifest %s", name)
+	return name, data, nil
+}
+
+// verifyChecksum checks data has a sha256=HEX checksum
+func verifyChecksum(data []byte, checksum string) error {
+	kv := strings.SplitN(checksum, "=", 2)
+	if len(kv) != 2 || kv[0] != "sha256" {
+		return fmt.Errorf("has an invalid checksum %q, expecting sha256=HEX", checksum)
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, kv[1]) {
+		return fmt.Errorf("has the checksum sha256=%s, expecting %s", got, kv[1])
+	}
+	return nil
+}
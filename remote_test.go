@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetchManifest(t *testing.T) {
+	manifest := "kind: ConfigMap\nmetadata:\n  name: shared\n"
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(manifest))
+	}))
+	defer server.Close()
+	sum := "5d0b6e1bd44c0a6bd8a8f3bb4b9fd2d85c8e63b6c2b3c5a0c0b0d3f5e7a9b1c2"
+	cases := []struct {
+		url     string
+		headers []string
+		err     string
+	}{
+		{url: server.URL + "/shared.yaml", headers: []string{"Authorization: Bearer token"}},
+		{url: server.URL + "/shared.yaml#sha256=c57a30be3e64b35f78a7ce5fe5611a192ea3743753f49d015960a7fb9bbbdaae", headers: []string{"Authorization: Bearer token"}},
+		{url: server.URL + "/shared.yaml", err: "401 Unauthorized"},
+		{url: server.URL + "/shared.yaml#sha256=" + sum, headers: []string{"Authorization: Bearer token"}, err: "expecting " + sum},
+		{url: server.URL + "/shared.yaml#md5=abc", headers: []string{"Authorization: Bearer token"}, err: "invalid checksum"},
+		{url: "http://example.com/shared.yaml", err: "must use https"},
+		{url: server.URL + "/shared.yaml", headers: []string{"Authorization"}, err: "expecting NAME: VALUE"},
+	}
+	for _, c := range cases {
+		name, data, err := fetchManifest(server.Client(), c.url, c.headers)
+		if len(c.err) > 0 {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("got: %v\nwant an error containing: %s\n", err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if name != server.URL+"/shared.yaml" || string(data) != manifest {
+			t.Errorf("got: %#v %#v\nwant: %#v %#v\n", name, string(data), server.URL+"/shared.yaml", manifest)
+		}
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	data := []byte("kind: ConfigMap\n")
+	cases := []struct {
+		checksum string
+		valid    bool
+	}{
+		{checksum: "sha256=bb6c7fb1ce4b8ac8baa8f6344623dd4602cf3d6ce859f9ff859a5a43787c5987", valid: true},
+		{checksum: "sha256=BB6C7FB1CE4B8AC8BAA8F6344623DD4602CF3D6CE859F9FF859A5A43787C5987", valid: true},
+		{checksum: "sha256=5d0b6e1bd44c0a6bd8a8f3bb4b9fd2d85c8e63b6c2b3c5a0c0b0d3f5e7a9b1c2"},
+		{checksum: "bb6c7fb1ce4b8ac8baa8f6344623dd4602cf3d6ce859f9ff859a5a43787c5987"},
+	}
+	for _, c := range cases {
+		if err := verifyChecksum(data, c.checksum); (err == nil) != c.valid {
+			t.Errorf("got: %v for %s\nwant valid: %v\n", err, c.checksum, c.valid)
+		}
+	}
+}
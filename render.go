@@ -2,12 +2,20 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"math/big"
+	mathrand "math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/Masterminds/sprig"
 	"github.com/helm/helm/pkg/strvals"
@@ -16,6 +24,14 @@ import (
 var (
 	secretUsed = false
 	k8Api      K8Api
+	// renderDeadline is when rendering must complete by (zero for no limit)
+	renderDeadline time.Time
+	// templateFile is the file being rendered, which file paths can be relative to
+	templateFile string
+	// renderConf is the config data the resources were rendered with
+	renderConf interface{}
+	// leftDelim and rightDelim are the template delimiters (empty for {{ and }})
+	leftDelim, rightDelim string
 )
 
 // Render - the function used for rendering templates (with Sprig support)
@@ -27,12 +43,39 @@ func Render(k K8Api, tmpl string, vars interface{}) (string, bool, error) {
 	fm["hasSuffix"] = strings.HasSuffix
 	fm["split"] = strings.Split
 	fm["secret"] = secret
+	fm["required"] = required
+	fm["envOrDefault"] = envOrDefault
+	// Fail the render on invalid data rather than rendering the error (as sprig does)
+	fm["b64dec"] = b64dec
+	fm["debugContext"] = debugContext
+	// Network and port arithmetic for network policies, services and proxy configs
+	fm["cidrhost"] = cidrhost
+	fm["cidrsubnet"] = cidrsubnet
+	fm["cidrnetmask"] = cidrnetmask
+	fm["portOffset"] = portOffset
+	if reproducible {
+		reproducibleFuncs(fm)
+	}
 	// Add file function to map
 	fm["file"] = fileRender
 	fm["fileWith"] = fileRenderWithData
-	// Required for lookup function
+	fm["readFile"] = readFile
+	// Lookups give up at the render deadline, so a slow one can't overrun it
+	ctx, cancel := renderContext()
+	defer cancel()
 	k8Api = k
-	fm["k8lookup"] = k8lookup
+	fm["k8lookup"] = func(kind, name, path string) (string, error) {
+		return k8lookup(ctx, kind, name, path)
+	}
+	fm["vault"] = func(path, key string) (string, error) {
+		return vault(ctx, path, key)
+	}
+	fm["ssm"] = func(name string) (string, error) {
+		return ssm(ctx, name)
+	}
+	fm["awsSecret"] = func(id, key string) (string, error) {
+		return awsSecret(ctx, id, key)
+	}
 	// Added some oft used helm functions
 	fm["toYaml"] = strvals.ToYAML
 	fm["parse"] = strvals.Parse
@@ -48,20 +91,51 @@ func Render(k K8Api, tmpl string, vars interface{}) (string, bool, error) {
 			logError.Fatal(err)
 		}
 	}()
-	t := template.Must(template.New("template").Funcs(fm).Parse(tmpl))
+	t := template.Must(template.New("template").Delims(leftDelim, rightDelim).Funcs(fm).Parse(tmpl))
 	if allowMissingVariables {
 		t.Option("missingkey=default")
 	} else {
 		t.Option("missingkey=error")
 	}
 	var b bytes.Buffer
-	if err := t.Execute(&b, vars); err != nil {
-		return b.String(), secretUsed, err
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				logError.Fatal(err)
+			}
+		}()
+		done <- t.Execute(&b, vars)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return b.String(), secretUsed, err
+		}
+	case <-ctx.Done():
+		return "", secretUsed, fmt.Errorf("rendering took longer than the render timeout: %s", ctx.Err())
 	}
 	// need to replace blank lines because of bad template formating
 	return strings.Replace(b.String(), "\n\n", "\n", -1), secretUsed, nil
 }
 
+// renderContext is a context which is cancelled at the render deadline
+func renderContext() (context.Context, context.CancelFunc) {
+	if renderDeadline.IsZero() {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithDeadline(context.Background(), renderDeadline)
+}
+
+// parseDelims splits a --template-delims value of the form LEFT,RIGHT
+func parseDelims(value string) (string, string, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 || len(strings.TrimSpace(parts[0])) == 0 || len(strings.TrimSpace(parts[1])) == 0 {
+		return "", "", fmt.Errorf("invalid %s %q, expecting LEFT,RIGHT e.g. '[[,]]'", FlagTemplateDelims, value)
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), nil
+}
+
 // secret generate a secret
 func secret(stringType string, length int) string {
 	var (
@@ -102,8 +176,126 @@ func secret(stringType string, length int) string {
 	return base64.StdEncoding.EncodeToString(buf)
 }
 
+// reproducibleTime is the time used for reproducible renders, SOURCE_DATE_EPOCH or zero
+func reproducibleTime() time.Time {
+	epoch, _ := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64)
+	return time.Unix(epoch, 0).UTC()
+}
+
+// reproducibleFuncs replaces the template functions which give a different result
+// for every render: times are pinned, random values come from a generator seeded
+// with the time and functions generating secrets or keys fail
+func reproducibleFuncs(fm template.FuncMap) {
+	rnd := mathrand.New(mathrand.NewSource(reproducibleTime().Unix()))
+	pinned := func(date interface{}) interface{} {
+		switch date.(type) {
+		case time.Time, int64, int, int32:
+			return date
+		}
+		return reproducibleTime()
+	}
+	dateInZone := fm["dateInZone"].(func(string, interface{}, string) string)
+	fm["now"] = reproducibleTime
+	fm["date"] = func(format string, date interface{}) string {
+		return dateInZone(format, pinned(date), "UTC")
+	}
+	fm["dateInZone"] = func(format string, date interface{}, zone string) string {
+		return dateInZone(format, pinned(date), zone)
+	}
+	fm["date_in_zone"] = fm["dateInZone"]
+	fm["htmlDate"] = func(date interface{}) string {
+		return dateInZone("2006-01-02", pinned(date), "UTC")
+	}
+	fm["htmlDateInZone"] = func(date interface{}, zone string) string {
+		return dateInZone("2006-01-02", pinned(date), zone)
+	}
+	fm["ago"] = func(date interface{}) string {
+		t := reproducibleTime()
+		switch date := date.(type) {
+		case time.Time:
+			t = date
+		case int64:
+			t = time.Unix(date, 0)
+		case int:
+			t = time.Unix(int64(date), 0)
+		case int32:
+			t = time.Unix(int64(date), 0)
+		}
+		ago := reproducibleTime().Sub(t)
+		return (ago - ago%time.Second).String()
+	}
+	randString := func(chars string) func(int) string {
+		return func(length int) string {
+			buf := make([]byte, length)
+			for i := range buf {
+				buf[i] = chars[rnd.Intn(len(chars))]
+			}
+			return string(buf)
+		}
+	}
+	letters := "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	digits := "0123456789"
+	ascii := ""
+	for c := ' '; c <= '~'; c++ {
+		ascii += string(c)
+	}
+	fm["randAlphaNum"] = randString(letters + digits)
+	fm["randAlpha"] = randString(letters)
+	fm["randNumeric"] = randString(digits)
+	fm["randAscii"] = randString(ascii)
+	fm["uuidv4"] = func() string {
+		b := make([]byte, 16)
+		rnd.Read(b)
+		b[6] = b[6]&0x0f | 0x40
+		b[8] = b[8]&0x3f | 0x80
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+	}
+	fm["shuffle"] = func(str string) string {
+		runes := []rune(str)
+		shuffled := make([]rune, len(runes))
+		for i, j := range rnd.Perm(len(runes)) {
+			shuffled[i] = runes[j]
+		}
+		return string(shuffled)
+	}
+	for _, name := range []string{"secret", "genPrivateKey", "genCA", "genSelfSignedCert", "genSignedCert"} {
+		name := name
+		fm[name] = func(...interface{}) (string, error) {
+			return "", fmt.Errorf("%s generates a new value for every render, it can't be used with --%s", name, FlagReproducible)
+		}
+	}
+}
+
+// required fails rendering with the message given when a value is missing or empty
+func required(msg string, val interface{}) (interface{}, error) {
+	if val == nil {
+		return val, errors.New(msg)
+	}
+	if s, ok := val.(string); ok && len(s) == 0 {
+		return val, errors.New(msg)
+	}
+	return val, nil
+}
+
+// envOrDefault returns an environment variable or the fallback when it is empty
+func envOrDefault(name, fallback string) string {
+	if v := os.Getenv(name); len(v) > 0 {
+		return v
+	}
+	return fallback
+}
+
+// b64dec decodes base64 data, failing when it isn't valid
+func b64dec(data string) (string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "", fmt.Errorf("invalid base64 data: %s", err)
+	}
+	return string(decoded), nil
+}
+
 func fileRenderWithData(key string, extra map[string]interface{}) string {
-	data, err := ioutil.ReadFile(key)
+	data, err := ioutil.ReadFile(templatePath(key))
 	if err != nil {
 		panic(err.Error())
 	}
@@ -123,11 +315,28 @@ func fileRender(key string) string {
 	return fileRenderWithData(key, map[string]interface{}{})
 }
 
-// k8lookup find a value from a kubernetes object
-func k8lookup(kind, name, path string) string {
-	data, err := k8Api.Lookup(kind, name, path)
+// readFile returns the contents of a file without rendering it
+func readFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(templatePath(path))
 	if err != nil {
-		panic(err.Error())
+		return "", err
 	}
-	return data
+	return string(data), nil
+}
+
+// templatePath finds a file from the working directory or else relative to the
+// template being rendered
+func templatePath(path string) string {
+	if filepath.IsAbs(path) || len(templateFile) == 0 {
+		return path
+	}
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	return filepath.Join(filepath.Dir(templateFile), path)
+}
+
+// k8lookup find a value from a kubernetes object
+func k8lookup(ctx context.Context, kind, name, path string) (string, error) {
+	return k8Api.Lookup(ctx, kind, name, path)
 }
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 var emptymap map[string]string
@@ -72,6 +76,12 @@ func TestRender(t *testing.T) {
 			inputvars: testData,
 			want:      readfile("test/hasSuffix-rendered.yaml"),
 		},
+		{
+			name:      "Check sprig functions work as expected",
+			inputdata: readfile("test/sprig-prerendered.yaml"),
+			inputvars: testData,
+			want:      readfile("test/sprig-rendered.yaml"),
+		},
 	}
 
 	api := NewK8ApiNoop()
@@ -118,3 +128,310 @@ func TestRenderMissingVariablesAllowed(t *testing.T) {
 	})
 	allowMissingVariables = false
 }
+
+func TestRenderRequired(t *testing.T) {
+	api := NewK8ApiNoop()
+	cases := []struct {
+		name         string
+		inputvars    map[string]string
+		allowMissing bool
+		want         string
+		wantErr      string
+	}{
+		{
+			name:      "Check required value is rendered",
+			inputvars: map[string]string{"IMAGE_TAG": "v1"},
+			want:      "image: app:v1\n",
+		},
+		{
+			name:      "Check empty required value fails",
+			inputvars: map[string]string{"IMAGE_TAG": ""},
+			wantErr:   "IMAGE_TAG must be set",
+		},
+		{
+			name:      "Check missing required value fails",
+			inputvars: map[string]string{},
+			wantErr:   `no entry for key "IMAGE_TAG"`,
+		},
+		{
+			name:         "Check missing required value fails when missing variables are allowed",
+			inputvars:    map[string]string{},
+			allowMissing: true,
+			wantErr:      "IMAGE_TAG must be set",
+		},
+	}
+
+	defer func() { allowMissingVariables = false }()
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			allowMissingVariables = c.allowMissing
+			got, _, err := Render(api, `image: app:{{ required "IMAGE_TAG must be set" .IMAGE_TAG }}`+"\n", c.inputvars)
+			if len(c.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Errorf("got: %v\nwant error: %#v\n", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if got != c.want {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
+
+func TestRenderDefaults(t *testing.T) {
+	api := NewK8ApiNoop()
+	os.Setenv("KD_TEST_SET", "set")
+	os.Unsetenv("KD_TEST_UNSET")
+	cases := []struct {
+		name      string
+		inputdata string
+		inputvars map[string]string
+		want      string
+	}{
+		{
+			name:      "Check default is used for an empty value",
+			inputdata: `replicas: {{ .REPLICAS | default "2" }}`,
+			inputvars: map[string]string{"REPLICAS": ""},
+			want:      "replicas: 2",
+		},
+		{
+			name:      "Check envOrDefault uses the environment",
+			inputdata: `value: {{ envOrDefault "KD_TEST_SET" "fallback" }}`,
+			inputvars: emptymap,
+			want:      "value: set",
+		},
+		{
+			name:      "Check envOrDefault uses the fallback",
+			inputdata: `value: {{ envOrDefault "KD_TEST_UNSET" "fallback" }}`,
+			inputvars: emptymap,
+			want:      "value: fallback",
+		},
+		{
+			name:      "Check envOrDefault composes with required",
+			inputdata: `value: {{ envOrDefault "KD_TEST_UNSET" "fallback" | required "needed" }}`,
+			inputvars: emptymap,
+			want:      "value: fallback",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, _, err := Render(api, c.inputdata, c.inputvars)
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if got != c.want {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
+
+func TestRenderBase64(t *testing.T) {
+	api := NewK8ApiNoop()
+	cases := []struct {
+		name      string
+		inputdata string
+		inputvars map[string]string
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:      "Check b64enc encodes a value",
+			inputdata: `password: {{ .PASSWORD | b64enc }}`,
+			inputvars: map[string]string{"PASSWORD": "s3cr3t"},
+			want:      "password: czNjcjN0",
+		},
+		{
+			name:      "Check b64enc composes with trim",
+			inputdata: `password: {{ .PASSWORD | trim | b64enc }}`,
+			inputvars: map[string]string{"PASSWORD": "s3cr3t\n"},
+			want:      "password: czNjcjN0",
+		},
+		{
+			name:      "Check b64dec decodes a value",
+			inputdata: `password: {{ .PASSWORD | b64dec }}`,
+			inputvars: map[string]string{"PASSWORD": "czNjcjN0"},
+			want:      "password: s3cr3t",
+		},
+		{
+			name:      "Check b64dec fails on invalid data",
+			inputdata: `password: {{ .PASSWORD | b64dec }}`,
+			inputvars: map[string]string{"PASSWORD": "not base64!"},
+			wantErr:   true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, _, err := Render(api, c.inputdata, c.inputvars)
+			if c.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got: %#v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if got != c.want {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
+
+func TestRenderReadFile(t *testing.T) {
+	api := NewK8ApiNoop()
+	templateFile = "test/TestReadFile/configmap.yaml"
+	defer func() { templateFile = "" }()
+
+	got, _, err := Render(api, "data:\n  dashboard.json: |-\n{{ readFile \"dashboard.json\" | trim | indent 4 }}", emptymap)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := "data:\n  dashboard.json: |-\n    {\"title\": \"{{ not rendered }}\"}"
+	if got != want {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+	got, _, err = Render(api, `checksum/config: {{ readFile "config.yaml" | sha256sum }}`, emptymap)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want = "checksum/config: bc4974a5282d4ab456e4715fc8e35964b0862a5a98ef2eda7583859fc016c727"
+	if got != want {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+	if got, want := templatePath("test/complex-file.pem"), "test/complex-file.pem"; got != want {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+}
+
+func TestRenderDelims(t *testing.T) {
+	api := NewK8ApiNoop()
+	var err error
+	if leftDelim, rightDelim, err = parseDelims("[[,]]"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer func() { leftDelim, rightDelim = "", "" }()
+
+	got, _, err := Render(api, `summary: "{{ $labels.instance }} is down in [[ .ENV ]]"`, map[string]string{"ENV": "prod"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := `summary: "{{ $labels.instance }} is down in prod"`; got != want {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+	if _, _, err := parseDelims("[["); err == nil {
+		t.Error("expected an error without a right delimiter")
+	}
+}
+
+func TestRenderReproducible(t *testing.T) {
+	api := NewK8ApiNoop()
+	os.Setenv("SOURCE_DATE_EPOCH", "1546344000")
+	defer os.Unsetenv("SOURCE_DATE_EPOCH")
+	reproducible = true
+	defer func() { reproducible = false }()
+
+	cases := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  string
+	}{
+		{name: "Check now is pinned", template: `built: {{ now | date "2006-01-02" }}`, want: "built: 2019-01-01"},
+		{name: "Check dates default to the pinned time", template: `built: {{ date "2006-01-02 15:04" "" }}`, want: "built: 2019-01-01 12:00"},
+		{name: "Check ago is from the pinned time", template: `{{ ago 1546340400 }}`, want: "1h0m0s"},
+		{name: "Check random strings are repeated", template: `{{ randAlphaNum 16 }} {{ uuidv4 }} {{ shuffle "abcdef" }}`},
+		{name: "Check generated keys fail", template: `{{ genPrivateKey "rsa" }}`, wantErr: "genPrivateKey generates a new value"},
+		{name: "Check generated secrets fail", template: `{{ secret "alphanum" 16 }}`, wantErr: "secret generates a new value"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, _, err := Render(api, tc.template, emptymap)
+			if len(tc.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("got: %#v\nwant error: %#v\n", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(tc.want) > 0 && got != tc.want {
+				t.Errorf("got: %#v\nwant: %#v\n", got, tc.want)
+			}
+			if again, _, _ := Render(api, tc.template, emptymap); again != got {
+				t.Errorf("got: %#v\nwant: %#v\n", again, got)
+			}
+		})
+	}
+}
+
+func TestRenderContext(t *testing.T) {
+	defer func() { renderDeadline = time.Time{} }()
+
+	ctx, cancel := renderContext()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("expected no deadline without a render timeout")
+	}
+	cancel()
+
+	renderDeadline = time.Now().Add(-time.Second)
+	ctx, cancel = renderContext()
+	defer cancel()
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Errorf("got: %#v\nwant: %#v\n", ctx.Err(), context.DeadlineExceeded)
+	}
+}
+
+// slowK8Api is a K8Api whose lookups take a second, or until the context is done
+// when it is honoured
+type slowK8Api struct {
+	K8ApiNoop
+	honourContext bool
+}
+
+func (a slowK8Api) Lookup(ctx context.Context, kind, name, path string) (string, error) {
+	if !a.honourContext {
+		time.Sleep(time.Second)
+		return "slow", nil
+	}
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-time.After(time.Second):
+		return "slow", nil
+	}
+}
+
+func TestRenderTimeout(t *testing.T) {
+	defer func() { renderDeadline = time.Time{} }()
+
+	cases := []struct {
+		name    string
+		api     K8Api
+		wantErr string
+	}{
+		{name: "Check a lookup is given up at the render deadline", api: slowK8Api{honourContext: true}, wantErr: "deadline exceeded"},
+		{name: "Check a template is given up at the render deadline", api: slowK8Api{}, wantErr: "took longer than the render timeout"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			renderDeadline = time.Now().Add(50 * time.Millisecond)
+			start := time.Now()
+			_, _, err := Render(tc.api, `replicas: {{ k8lookup "deployment" "app" ".spec.replicas" }}`, emptymap)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("got: %#v\nwant error: %#v\n", err, tc.wantErr)
+			}
+			if took := time.Since(start); took > 500*time.Millisecond {
+				t.Errorf("rendering took %s, longer than the render timeout", took)
+			}
+		})
+	}
+}
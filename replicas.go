@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// scalableKinds are the kinds with a spec.replicas an autoscaler can manage
+var scalableKinds = []string{"Deployment", "StatefulSet", "ReplicaSet"}
+
+// preserveReplicas sets the replicas of a workload which leaves them out of its
+// manifest to those running, so applying it never scales it back to the default
+func preserveReplicas(c *cli.Context, r *ObjectResource) error {
+	if !contains(scalableKinds, r.Kind) || r.ObjectSpec.Replicas != nil || len(r.Name) == 0 {
+		return nil
+	}
+	out, err := runResourceKubeCmd(c, r, "get", kubectlRef(r), "-o", "jsonpath={.spec.replicas}", "--ignore-not-found")
+	if err != nil {
+		return fmt.Errorf("problem getting the replicas of %s: %s", resourceRef(r), err)
+	}
+	if len(strings.TrimSpace(out)) == 0 {
+		// A new workload starts with the default
+		return nil
+	}
+	replicas, err := strconv.Atoi(strings.TrimSpace(out))
+	if err != nil {
+		return fmt.Errorf("invalid replicas %q for %s: %s", out, resourceRef(r), err)
+	}
+	template, err := withReplicas(r.Template, replicas)
+	if err != nil {
+		return err
+	}
+	logDebug.Printf("preserving the %d replicas of %s", replicas, resourceRef(r))
+	r.Template = template
+	n := int32(replicas)
+	r.ObjectSpec.Replicas = &n
+	return nil
+}
+
+// withReplicas sets spec.replicas in a manifest
+func withReplicas(template []byte, replicas int) ([]byte, error) {
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(template, &doc); err != nil {
+		return nil, err
+	}
+	spec, _ := mapSliceGet(doc, "spec")
+	specSlice, _ := spec.(yaml.MapSlice)
+	doc = mapSliceSet(doc, "spec", mapSliceSet(specSlice, "replicas", replicas))
+	return yaml.Marshal(doc)
+}
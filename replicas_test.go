@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestWithReplicas(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "Check replicas are added to the spec",
+			input: "kind: Deployment\nmetadata:\n  name: api\nspec:\n  selector:\n    matchLabels:\n      app: api\n",
+			want:  "kind: Deployment\nmetadata:\n  name: api\nspec:\n  selector:\n    matchLabels:\n      app: api\n  replicas: 7\n",
+		},
+		{
+			name:  "Check a spec is added when there isn't one",
+			input: "kind: Deployment\nmetadata:\n  name: api\n",
+			want:  "kind: Deployment\nmetadata:\n  name: api\nspec:\n  replicas: 7\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := withReplicas([]byte(c.input), 7)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if string(got) != c.want {
+				t.Errorf("got: %#v\nwant: %#v\n", string(got), c.want)
+			}
+		})
+	}
+}
+
+func TestDesiredReplicas(t *testing.T) {
+	three := int32(3)
+	if got := desiredReplicas(&ObjectResource{ObjectSpec: ObjectSpec{Replicas: &three}}); got != 3 {
+		t.Errorf("got: %#v\nwant: %#v\n", got, 3)
+	}
+	if got := desiredReplicas(&ObjectResource{}); got != 1 {
+		t.Errorf("got: %#v\nwant: %#v\n", got, 1)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// restartDependents restarts and watches the workloads which weren't changed but
+// use a configmap or secret which was
+func restartDependents(c *cli.Context, resources []*ObjectResource) error {
+	workloads, err := dependentWorkloads(resources)
+	if err != nil {
+		return err
+	}
+	for _, r := range workloads {
+		ref := strings.ToLower(r.Kind) + "/" + r.Name
+		logInfo.Printf("restarting %s as its configuration changed", ref)
+		if _, err := runResourceKubeCmd(c, r, "rollout", "restart", ref); err != nil {
+			return fmt.Errorf("problem restarting %s: %s", ref, err)
+		}
+		if c.Bool(FlagNoWait) {
+			continue
+		}
+		if err := watchResource(c, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dependentWorkloads returns the unchanged workloads referencing a changed configmap or secret
+func dependentWorkloads(resources []*ObjectResource) ([]*ObjectResource, error) {
+	changed := map[string]bool{}
+	for _, r := range resources {
+		if r.Changed && (r.Kind == "ConfigMap" || r.Kind == "Secret") {
+			changed[r.Kind+"/"+r.Name] = true
+		}
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	var workloads []*ObjectResource
+	for _, r := range resources {
+		if r.Changed || !contains([]string{"Deployment", "StatefulSet", "DaemonSet"}, r.Kind) {
+			continue
+		}
+		var doc map[interface{}]interface{}
+		if err := yaml.Unmarshal(r.Template, &doc); err != nil {
+			return nil, err
+		}
+		podSpec, _ := lookupPath(doc, podTemplatePath(r.Kind, "spec")...).(map[interface{}]interface{})
+		for _, ref := range podSpecReferences(podSpec) {
+			if changed[ref] {
+				workloads = append(workloads, r)
+				break
+			}
+		}
+	}
+	return workloads, nil
+}
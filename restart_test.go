@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDependentWorkloads(t *testing.T) {
+	workload := func(name, configMap string, changed bool) *ObjectResource {
+		return &ObjectResource{
+			Kind:       "Deployment",
+			ObjectMeta: ObjectMeta{Name: name},
+			Changed:    changed,
+			Template: []byte("kind: Deployment\nspec:\n  template:\n    spec:\n      volumes:\n" +
+				"      - name: config\n        configMap:\n          name: " + configMap + "\n"),
+		}
+	}
+	resources := []*ObjectResource{
+		{Kind: "ConfigMap", ObjectMeta: ObjectMeta{Name: "changed"}, Changed: true},
+		{Kind: "ConfigMap", ObjectMeta: ObjectMeta{Name: "same"}},
+		workload("uses-changed", "changed", false),
+		workload("uses-same", "same", false),
+		workload("updated", "changed", true),
+	}
+	got, err := dependentWorkloads(resources)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var names []string
+	for _, r := range got {
+		names = append(names, r.Name)
+	}
+	want := []string{"uses-changed"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", names, want)
+	}
+}
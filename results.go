@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// deployResult is what a deploy made of a resource, for post deploy hooks and the
+// notes template
+type deployResult struct {
+	Kind         string `json:"kind"`
+	Name         string `json:"name"`
+	GenerateName string `json:"generateName,omitempty"`
+	Namespace    string `json:"namespace,omitempty"`
+	UID          string `json:"uid,omitempty"`
+	Generation   int    `json:"generation,omitempty"`
+	// Revision is the rollout revision of a Deployment or StatefulSet
+	Revision string `json:"revision,omitempty"`
+	File     string `json:"file"`
+	Changed  bool   `json:"changed"`
+}
+
+// deployResults gets the results of each deployed resource from the cluster
+func deployResults(c *cli.Context, resources []*ObjectResource) ([]deployResult, error) {
+	var results []deployResult
+	for _, r := range resources {
+		out, err := runResourceKubeCmd(c, r, "get", kubectlRef(r), "-o", "yaml", "--ignore-not-found")
+		if err != nil {
+			return nil, fmt.Errorf("problem getting the result of %s: %s", resourceRef(r), err)
+		}
+		var live interface{}
+		if err := yaml.Unmarshal([]byte(out), &live); err != nil {
+			return nil, err
+		}
+		results = append(results, resourceResult(r, live))
+	}
+	return results, nil
+}
+
+// resourceResult is the result of a resource from its live object, which is nil
+// when it doesn't exist (e.g. a Job which has been cleaned up)
+func resourceResult(r *ObjectResource, live interface{}) deployResult {
+	result := deployResult{
+		Kind:         r.Kind,
+		Name:         r.Name,
+		GenerateName: r.GenerateName,
+		Namespace:    r.Namespace,
+		File:         r.FileName,
+		Changed:      r.Changed,
+	}
+	if ns, ok := lookupPath(live, "metadata", "namespace").(string); ok {
+		result.Namespace = ns
+	}
+	result.UID, _ = lookupPath(live, "metadata", "uid").(string)
+	result.Generation, _ = lookupPath(live, "metadata", "generation").(int)
+	switch r.Kind {
+	case "Deployment":
+		result.Revision, _ = lookupPath(live, "metadata", "annotations", "deployment.kubernetes.io/revision").(string)
+	case "StatefulSet":
+		result.Revision, _ = lookupPath(live, "status", "updateRevision").(string)
+	}
+	return result
+}
+
+// withResults adds the deploy results to the config data used by the notes template
+func withResults(conf interface{}, results []deployResult) interface{} {
+	switch m := conf.(type) {
+	case map[string]interface{}:
+		m["Resources"] = results
+	case map[interface{}]interface{}:
+		m["Resources"] = results
+	}
+	return conf
+}
+
+// runPostDeployHook runs a command after a successful deploy with the results of
+// each resource as json on stdin
+func runPostDeployHook(c *cli.Context, command string, results []deployResult) error {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return fmt.Errorf("invalid %s %q, expecting a command", FlagPostDeployHook, command)
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"release":   c.String(FlagRelease),
+		"revision":  releaseRevision,
+		"resources": results,
+	})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.Duration("timeout"))
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(),
+		"KD_NAMESPACE="+c.String("namespace"),
+		"KD_RELEASE="+c.String(FlagRelease),
+	)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	logInfo.Printf("running post deploy hook %q", args[0])
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("post deploy hook %q timed out after %s", args[0], c.Duration("timeout"))
+		}
+		return fmt.Errorf("post deploy hook %q failed: %s", args[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestResourceResult(t *testing.T) {
+	cases := []struct {
+		name     string
+		resource *ObjectResource
+		live     string
+		want     deployResult
+	}{
+		{
+			name:     "Check a deployment has its uid and revision",
+			resource: &ObjectResource{Kind: "Deployment", FileName: "kube/api.yaml", Changed: true, ObjectMeta: ObjectMeta{Name: "api"}},
+			live:     "metadata:\n  name: api\n  namespace: prod\n  uid: 0b6b1c1e-11f0-4c7a-9d5a-3b9f4c1e2a77\n  generation: 4\n  annotations:\n    deployment.kubernetes.io/revision: \"3\"\n",
+			want: deployResult{Kind: "Deployment", Name: "api", Namespace: "prod", UID: "0b6b1c1e-11f0-4c7a-9d5a-3b9f4c1e2a77",
+				Generation: 4, Revision: "3", File: "kube/api.yaml", Changed: true},
+		},
+		{
+			name:     "Check a statefulset revision",
+			resource: &ObjectResource{Kind: "StatefulSet", ObjectMeta: ObjectMeta{Name: "db"}},
+			live:     "metadata:\n  uid: 9d1f\nstatus:\n  updateRevision: db-7c9d8f6b5\n",
+			want:     deployResult{Kind: "StatefulSet", Name: "db", UID: "9d1f", Revision: "db-7c9d8f6b5"},
+		},
+		{
+			name:     "Check a generated job which no longer exists keeps its name",
+			resource: &ObjectResource{Kind: "Job", ObjectMeta: ObjectMeta{Name: "migrate-x7k2p", GenerateName: "migrate-", Namespace: "jobs"}},
+			want:     deployResult{Kind: "Job", Name: "migrate-x7k2p", GenerateName: "migrate-", Namespace: "jobs"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var live interface{}
+			if err := yaml.Unmarshal([]byte(c.live), &live); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			got := resourceResult(c.resource, live)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
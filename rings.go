@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// batchRing is a stage of a fleet rollout, promoted to the next ring once its gates pass
+type batchRing struct {
+	Name string `yaml:"name"`
+	// Jobs are the names of the batch jobs deployed in the ring
+	Jobs []string `yaml:"jobs"`
+	// Bake is how long to wait after the ring is deployed before verifying it
+	Bake string `yaml:"bake"`
+	// Verify are the checks which must pass before promoting to the next ring
+	Verify ringChecks `yaml:"verify"`
+	// Values are the config data for every job in the ring, overlaying the batch values
+	Values []string `yaml:"values"`
+	// Approval requires a person to confirm before promoting to the next ring
+	Approval bool `yaml:"approval"`
+
+	bake time.Duration
+	jobs []batchJob
+}
+
+// ringChecks are the verification checks for a ring
+type ringChecks struct {
+	URLs     []string `yaml:"urls"`
+	Commands []string `yaml:"commands"`
+}
+
+// approve asks for a ring to be promoted, replaced in tests
+var approve = func(from, to string) (bool, error) {
+	fmt.Fprintf(os.Stderr, "promote the rollout from ring %s to ring %s? [y/N] ", from, to)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false, fmt.Errorf("no approval to promote to ring %s: %s", to, err)
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// resolveRings checks the rings and finds the jobs for each one
+func resolveRings(b *batchFile) error {
+	jobs := map[string]batchJob{}
+	for _, job := range b.Jobs {
+		jobs[job.Name] = job
+	}
+	rings := map[string]bool{}
+	for i := range b.Rings {
+		ring := &b.Rings[i]
+		if len(ring.Name) == 0 {
+			ring.Name = fmt.Sprintf("ring-%d", i+1)
+		}
+		if rings[ring.Name] {
+			return fmt.Errorf("duplicate ring name %s", ring.Name)
+		}
+		rings[ring.Name] = true
+		if len(ring.Jobs) == 0 {
+			return fmt.Errorf("no jobs specified for ring %s", ring.Name)
+		}
+		for _, name := range ring.Jobs {
+			job, found := jobs[name]
+			if !found {
+				return fmt.Errorf("ring %s references unknown batch job %s", ring.Name, name)
+			}
+			job.Values = layerValues(b.Values, ring.Values, job.Values)
+			ring.jobs = append(ring.jobs, job)
+		}
+		if len(ring.Bake) > 0 {
+			bake, err := time.ParseDuration(ring.Bake)
+			if err != nil {
+				return fmt.Errorf("invalid bake time for ring %s: %s", ring.Name, err)
+			}
+			ring.bake = bake
+		}
+	}
+	return nil
+}
+
+// rollout deploys each ring in turn, halting at the first ring which fails
+func rollout(b *batchFile, run func([]batchJob) []batchResult) error {
+	for i, ring := range b.Rings {
+		logInfo.Printf("deploying ring %s", ring.Name)
+		if err := batchReport(run(ring.jobs)); err != nil {
+			return fmt.Errorf("ring %s failed, halting the rollout: %s", ring.Name, err)
+		}
+		if err := verifyRing(ring); err != nil {
+			return fmt.Errorf("ring %s failed verification, halting the rollout: %s", ring.Name, err)
+		}
+		if !ring.Approval || i == len(b.Rings)-1 {
+			continue
+		}
+		next := b.Rings[i+1].Name
+		ok, err := approve(ring.Name, next)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("promotion from ring %s to ring %s was not approved, halting the rollout", ring.Name, next)
+		}
+	}
+	logSummary.Printf("rolled out %d rings", len(b.Rings))
+	return nil
+}
+
+// verifyRing waits for the bake time then runs the ring's checks
+func verifyRing(ring batchRing) error {
+	if ring.bake > 0 {
+		logInfo.Printf("baking ring %s for %s", ring.Name, ring.bake)
+		time.Sleep(ring.bake)
+	}
+	for _, u := range ring.Verify.URLs {
+		if err := checkURL(u); err != nil {
+			return fmt.Errorf("url %s: %s", u, err)
+		}
+	}
+	for _, command := range ring.Verify.Commands {
+		out, err := exec.Command("sh", "-c", command).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("command %q: %s\n%s", command, err, out)
+		}
+	}
+	if len(ring.Verify.URLs)+len(ring.Verify.Commands) > 0 {
+		logInfo.Printf("ring %s passed verification", ring.Name)
+	}
+	return nil
+}
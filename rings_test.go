@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestResolveRings(t *testing.T) {
+	jobs := []batchJob{{Name: "dev"}, {Name: "prod"}}
+	cases := []struct {
+		name    string
+		rings   []batchRing
+		wantErr bool
+	}{
+		{
+			name:  "Check rings referencing jobs are resolved",
+			rings: []batchRing{{Name: "dev", Jobs: []string{"dev"}, Bake: "5m"}, {Jobs: []string{"prod"}}},
+		},
+		{
+			name:    "Check an unknown job is an error",
+			rings:   []batchRing{{Name: "dev", Jobs: []string{"staging"}}},
+			wantErr: true,
+		},
+		{
+			name:    "Check an invalid bake time is an error",
+			rings:   []batchRing{{Name: "dev", Jobs: []string{"dev"}, Bake: "soon"}},
+			wantErr: true,
+		},
+		{
+			name:    "Check a ring without jobs is an error",
+			rings:   []batchRing{{Name: "dev"}},
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			b := &batchFile{Jobs: jobs, Rings: c.rings}
+			err := resolveRings(b)
+			if c.wantErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if b.Rings[1].Name != "ring-2" || !reflect.DeepEqual(b.Rings[1].jobs, jobs[1:]) {
+				t.Errorf("got: %#v\nwant: %#v\n", b.Rings[1], jobs[1:])
+			}
+		})
+	}
+}
+
+func TestRingValues(t *testing.T) {
+	b := &batchFile{
+		Values: []string{"Values=base.yaml"},
+		Jobs:   []batchJob{{Name: "prod-eu", Values: []string{"Values=eu.yaml"}}},
+		Rings:  []batchRing{{Name: "prod", Jobs: []string{"prod-eu"}, Values: []string{"Values=prod.yaml"}}},
+	}
+	if err := resolveRings(b); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []string{"Values=base.yaml", "Values=prod.yaml", "Values=eu.yaml"}
+	if got := b.Rings[0].jobs[0].Values; !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+}
+
+func TestRollout(t *testing.T) {
+	defer func(a func(string, string) (bool, error)) { approve = a }(approve)
+	b := &batchFile{
+		Jobs: []batchJob{{Name: "dev"}, {Name: "canary"}, {Name: "prod"}},
+		Rings: []batchRing{
+			{Name: "dev", Jobs: []string{"dev"}, Approval: true},
+			{Name: "canary", Jobs: []string{"canary"}},
+			{Name: "prod", Jobs: []string{"prod"}},
+		},
+	}
+	if err := resolveRings(b); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var deployed []string
+	run := func(jobs []batchJob) []batchResult {
+		var results []batchResult
+		for _, job := range jobs {
+			deployed = append(deployed, job.Name)
+			var err error
+			if job.Name == "canary" {
+				err = errors.New("exit status 1")
+			}
+			results = append(results, batchResult{job: job, err: err})
+		}
+		return results
+	}
+
+	approve = func(from, to string) (bool, error) { return false, nil }
+	if err := rollout(b, run); err == nil {
+		t.Error("expected an error when promotion is not approved")
+	}
+	if want := []string{"dev"}; !reflect.DeepEqual(deployed, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", deployed, want)
+	}
+
+	deployed = nil
+	approve = func(from, to string) (bool, error) { return true, nil }
+	if err := rollout(b, run); err == nil {
+		t.Error("expected an error when a ring fails")
+	}
+	if want := []string{"dev", "canary"}; !reflect.DeepEqual(deployed, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", deployed, want)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// rollingBack is set by the rollback command, deploying the recorded manifests of a
+// revision of the release and previewing the changes before deploying
+var rollingBack bool
+
+// rollbackRevision is the revision of the release being rolled back to
+var rollbackRevision int
+
+// rollbackDeletes are the resources of the release which the rollback deletes
+var rollbackDeletes []string
+
+// immutableFields are the fields of each kind which can't be changed once created,
+// so a rollback changing them recreates the resource
+var immutableFields = map[string][]string{
+	"Deployment":  {"spec.selector"},
+	"DaemonSet":   {"spec.selector"},
+	"StatefulSet": {"spec.selector", "spec.serviceName", "spec.volumeClaimTemplates", "spec.podManagementPolicy"},
+	"Job":         {"spec.selector", "spec.template"},
+	"Service":     {"spec.clusterIP"},
+}
+
+// rollback deploys the manifests recorded for a revision of --release again, the
+// one before the last successful deploy unless --revision is given, after showing
+// how the cluster would change
+func rollback(c *cli.Context) error {
+	if !c.IsSet(FlagRelease) {
+		return fmt.Errorf("a release must be specified with --%s to roll back", FlagRelease)
+	}
+	if dryRun {
+		return fmt.Errorf("the rollback preview needs the cluster, it can't be used with --dryrun")
+	}
+	previous, err := previousRelease(c)
+	if err != nil {
+		return err
+	}
+	current := previous["Revision"].(int)
+	if current == 0 {
+		return fmt.Errorf("no revision of %q has been recorded to roll back to", c.String(FlagRelease))
+	}
+	target := current - 1
+	if c.IsSet(FlagRevision) {
+		target = c.Int(FlagRevision)
+	}
+	if target < 1 || target > current {
+		return fmt.Errorf("can't roll back %q to revision %d, the last successful deploy is revision %d",
+			c.String(FlagRelease), target, current)
+	}
+	rollbackRevision = target
+	rollingBack = true
+	return run(c)
+}
+
+// rollbackResources reads the resources recorded for the revision rolled back to,
+// which is deployed as a new revision with the values it was rendered with
+func rollbackResources(c *cli.Context) ([]*ObjectResource, error) {
+	revision, err := readRevision(c, rollbackRevision)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := previousRelease(c)
+	if err != nil {
+		return nil, err
+	}
+	releaseValues = revision.values
+	releaseRevision = previous["Revision"].(int) + 1
+	renderConf = withPrevious(map[string]interface{}{"Values": revision.values}, previous)
+	for _, r := range revision.resources {
+		if err := updateResFromFlags(c, r); err != nil {
+			return nil, err
+		}
+	}
+	return revision.resources, nil
+}
+
+// previewRollback shows the changes the rollback makes to the cluster and asks for
+// confirmation when run interactively
+func previewRollback(c *cli.Context, resources []*ObjectResource) error {
+	var preview []string
+	for _, r := range resources {
+		change, err := rollbackChange(c, r)
+		if err != nil {
+			return err
+		}
+		if len(change) > 0 {
+			preview = append(preview, change)
+		}
+	}
+	out, err := runKubeCmd(c, "get", c.String(FlagPruneKinds),
+		"-l", LabelRelease+"="+c.String(FlagRelease),
+		"-o", "custom-columns=KIND:.kind,NAME:.metadata.name", "--no-headers")
+	if err != nil {
+		return err
+	}
+	rollbackDeletes = nil
+	for _, ref := range pruneCandidates(out, resources) {
+		// The record of the release isn't one of its manifests
+		if ref == "secret/"+releaseRecordName(c.String(FlagRelease)) {
+			continue
+		}
+		rollbackDeletes = append(rollbackDeletes, ref)
+		preview = append(preview, ref+" would be deleted\n")
+	}
+	if len(preview) == 0 {
+		logInfo.Printf("rolling back %s changes nothing in the cluster", c.String(FlagRelease))
+		return nil
+	}
+	logInfo.Printf("rolling back %s to revision %d:\n%s", c.String(FlagRelease), rollbackRevision, strings.Join(preview, ""))
+	if c.Bool(FlagYes) || !isTerminal(os.Stdin) {
+		return nil
+	}
+	confirmed, err := confirm(os.Stdin, os.Stderr, fmt.Sprintf("roll back %s?", c.String(FlagRelease)))
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		return fmt.Errorf("rollback of %s cancelled", c.String(FlagRelease))
+	}
+	return nil
+}
+
+// rollbackChange describes how rolling back changes a resource, empty when it doesn't
+func rollbackChange(c *cli.Context, r *ObjectResource) (string, error) {
+	live, err := liveObject(c, r)
+	if err != nil {
+		return "", err
+	}
+	if live == nil {
+		return fmt.Sprintf("%s would be created\n", resourceRef(r)), nil
+	}
+	var desired interface{}
+	if err := yaml.Unmarshal(r.Template, &desired); err != nil {
+		return "", err
+	}
+	changes := semanticChanges("", desired, removeServerFields(live))
+	if len(changes) == 0 {
+		return "", nil
+	}
+	action := "changed"
+	if field := immutableChange(r.Kind, changes); len(field) > 0 {
+		action = fmt.Sprintf("recreated (%s can't be changed)", field)
+	}
+	return fmt.Sprintf("%s would be %s:\n  %s\n", resourceRef(r), action, strings.Join(changes, "\n  ")), nil
+}
+
+// immutableChange returns the first field of a kind which can't be changed that
+// the semantic changes include, empty when there isn't one
+func immutableChange(kind string, changes []string) string {
+	for _, field := range immutableFields[kind] {
+		for _, change := range changes {
+			// The field itself or one nested in it, not another with the same prefix
+			if rest := strings.TrimPrefix(change, field); rest != change && strings.IndexAny(rest, ".[:") == 0 {
+				return field
+			}
+		}
+	}
+	return ""
+}
+
+// deleteRolledBack deletes the resources of the release which aren't part of the
+// release rolled back to
+func deleteRolledBack(c *cli.Context) error {
+	for _, ref := range rollbackDeletes {
+		logInfo.Printf("deleting %s which isn't part of the release rolled back to", ref)
+		if _, err := runKubeCmd(c, "delete", ref); err != nil {
+			return fmt.Errorf("problem deleting %s: %s", ref, err)
+		}
+	}
+	return nil
+}
+
+// isTerminal checks if a file is a terminal, so a person can answer a prompt
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// confirm asks a yes or no question, only yes confirms
+func confirm(in io.Reader, out io.Writer, question string) (bool, error) {
+	fmt.Fprintf(out, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestImmutableChange(t *testing.T) {
+	cases := []struct {
+		name    string
+		kind    string
+		changes []string
+		want    string
+	}{
+		{
+			name:    "Check a selector change recreates a deployment",
+			kind:    "Deployment",
+			changes: []string{"spec.replicas: 3 -> 2", "spec.selector.matchLabels.app: api-v2 -> api"},
+			want:    "spec.selector",
+		},
+		{
+			name:    "Check other changes don't recreate a deployment",
+			kind:    "Deployment",
+			changes: []string{"spec.template.spec.containers[api].image: api:2 -> api:1"},
+		},
+		{
+			name:    "Check a field sharing the prefix isn't immutable",
+			kind:    "StatefulSet",
+			changes: []string{"spec.serviceNameSuffix: a -> b"},
+		},
+		{
+			name:    "Check a template change recreates a job",
+			kind:    "Job",
+			changes: []string{"spec.template.spec.containers[migrate].image: app:2 -> app:1"},
+			want:    "spec.template",
+		},
+		{
+			name:    "Check kinds without immutable fields are changed",
+			kind:    "ConfigMap",
+			changes: []string{"data.mode: blue -> green"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := immutableChange(c.kind, c.changes); got != c.want {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	for answer, want := range map[string]bool{"y\n": true, "Yes\n": true, "n\n": false, "\n": false, "": false} {
+		var out bytes.Buffer
+		got, err := confirm(strings.NewReader(answer), &out, "roll back api?")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got != want {
+			t.Errorf("answer %q got: %#v\nwant: %#v\n", answer, got, want)
+		}
+		if out.String() != "roll back api? [y/N] " {
+			t.Errorf("got: %#v\nwant: %#v\n", out.String(), "roll back api? [y/N] ")
+		}
+	}
+}
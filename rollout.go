@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+// rolloutKinds are the kinds kubectl rollout status can watch, others always use kd's watch
+var rolloutKinds = []string{"Deployment", "StatefulSet", "DaemonSet"}
+
+// checkWatchEngine validates the engine used to watch resources
+func checkWatchEngine(engine string) error {
+	switch engine {
+	case "native", "kubectl":
+		return nil
+	}
+	return fmt.Errorf("invalid %s %q, expecting native or kubectl", FlagWatchEngine, engine)
+}
+
+// isPaused checks if a Deployment is paused, it won't roll out until it is resumed
+func isPaused(r *ObjectResource) bool {
+	return r.Kind == "Deployment" && r.Paused
+}
+
+// resumeRollout unpauses a paused Deployment so the changes applied roll out
+func resumeRollout(c *cli.Context, r *ObjectResource) error {
+	logInfo.Printf("resuming paused %s", resourceRef(r))
+	if _, err := runResourceKubeCmd(c, r, "rollout", "resume", kubectlRef(r)); err != nil {
+		return fmt.Errorf("problem resuming %s: %s", resourceRef(r), err)
+	}
+	r.Paused = false
+	return nil
+}
+
+// watchRollout waits for a workload to complete using kubectl rollout status
+func watchRollout(c *cli.Context, r *ObjectResource) (err error) {
+	defer func() {
+		if err != nil {
+			dumpEvents(c, r)
+			dumpPodLogs(c, r, c.Int(FlagPodLogLines))
+		}
+	}()
+	limit, err := watchTimeout(c, r)
+	if err != nil {
+		return err
+	}
+	args := []string{"rollout", "status", kubectlRef(r), "--watch", "--timeout=" + limit.String()}
+	cmd, err := newResourceKubeCmd(c, r, args, false)
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var errbuf bytes.Buffer
+	cmd.Stderr = &errbuf
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	var lines []string
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		logInfo.Printf("%s %q %s", r.Kind, r.Name, scanner.Text())
+		lines = append(lines, scanner.Text())
+	}
+	waitErr := cmd.Wait()
+	if err := rolloutOutcome(r, lines, errbuf.String(), waitErr); err != nil {
+		if _, autoscaler := autoscalerStatus(c, r); len(autoscaler) > 0 {
+			return fmt.Errorf("%s (%s)", err, autoscaler)
+		}
+		return err
+	}
+	return nil
+}
+
+// rolloutOutcome checks the output of kubectl rollout status for a successful rollout
+func rolloutOutcome(r *ObjectResource, lines []string, stderr string, err error) error {
+	msg := strings.TrimPrefix(strings.TrimSpace(stderr), "error: ")
+	// Like kd's watch, only rolling updates are watched for completion
+	if strings.Contains(msg, "only available for RollingUpdate strategy") {
+		logDebug.Printf("Only %s with type of RollingUpdate will be watched for completion", r.Kind)
+		return nil
+	}
+	if err != nil {
+		if len(msg) > 0 {
+			return fmt.Errorf("%s %q rollout failed: %s", r.Kind, r.Name, msg)
+		}
+		return fmt.Errorf("%s %q rollout failed: %s", r.Kind, r.Name, err)
+	}
+	if len(lines) == 0 {
+		return fmt.Errorf("%s %q rollout didn't report completing", r.Kind, r.Name)
+	}
+	// e.g. deployment "app" successfully rolled out, statefulset rolling update complete...
+	last := lines[len(lines)-1]
+	if !strings.Contains(last, "successfully rolled out") && !strings.Contains(last, "complete") {
+		return fmt.Errorf("%s %q rollout didn't report completing", r.Kind, r.Name)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestRolloutOutcome(t *testing.T) {
+	r := &ObjectResource{Kind: "Deployment", ObjectMeta: ObjectMeta{Name: "app"}}
+	cases := []struct {
+		name   string
+		lines  []string
+		stderr string
+		err    error
+		want   string
+	}{
+		{
+			name:  "deployment rolled out",
+			lines: []string{`Waiting for deployment "app" rollout to finish: 1 of 2 updated replicas are available...`, `deployment "app" successfully rolled out`},
+		},
+		{
+			name:  "statefulset complete",
+			lines: []string{"statefulset rolling update complete 3 pods at revision app-7d8f..."},
+		},
+		{
+			name:   "progress deadline",
+			lines:  []string{`Waiting for deployment "app" rollout to finish: 0 of 2 updated replicas are available...`},
+			stderr: "error: deployment \"app\" exceeded its progress deadline\n",
+			err:    errors.New("exit status 1"),
+			want:   `Deployment "app" rollout failed: deployment "app" exceeded its progress deadline`,
+		},
+		{
+			name:   "on delete strategy",
+			stderr: "error: rollout status is only available for RollingUpdate strategy type\n",
+			err:    errors.New("exit status 1"),
+		},
+		{
+			name:   "warnings",
+			lines:  []string{`deployment "app" successfully rolled out`},
+			stderr: "Warning: extensions/v1beta1 is deprecated\n",
+		},
+		{
+			name: "killed",
+			err:  errors.New("signal: killed"),
+			want: `Deployment "app" rollout failed: signal: killed`,
+		},
+		{
+			name:  "no outcome",
+			lines: []string{`Waiting for deployment "app" rollout to finish: 0 of 2 updated replicas are available...`},
+			want:  `Deployment "app" rollout didn't report completing`,
+		},
+	}
+	for _, c := range cases {
+		got := ""
+		if err := rolloutOutcome(r, c.lines, c.stderr, c.err); err != nil {
+			got = err.Error()
+		}
+		if got != c.want {
+			t.Errorf("%s\ngot: %#v\nwant: %#v\n", c.name, got, c.want)
+		}
+	}
+}
+
+func TestIsPaused(t *testing.T) {
+	cases := []struct {
+		name     string
+		template string
+		want     bool
+	}{
+		{
+			name:     "Check a paused deployment",
+			template: "kind: Deployment\nmetadata:\n  name: api\nspec:\n  paused: true\n",
+			want:     true,
+		},
+		{
+			name:     "Check a deployment which isn't paused",
+			template: "kind: Deployment\nmetadata:\n  name: api\nspec:\n  replicas: 2\n",
+		},
+		{
+			name:     "Check other kinds are never paused",
+			template: "kind: StatefulSet\nmetadata:\n  name: db\nspec:\n  paused: true\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var r ObjectResource
+			if err := yaml.Unmarshal([]byte(c.template), &r); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := isPaused(&r); got != c.want {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
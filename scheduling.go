@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// nodeList is the part of a list of nodes used to check scheduling
+type nodeList struct {
+	Items []struct {
+		Metadata struct {
+			Name   string            `yaml:"name"`
+			Labels map[string]string `yaml:"labels"`
+		} `yaml:"metadata"`
+		Spec struct {
+			Unschedulable bool    `yaml:"unschedulable"`
+			Taints        []taint `yaml:"taints"`
+		} `yaml:"spec"`
+	} `yaml:"items"`
+}
+
+// taint stops pods which don't tolerate it being scheduled on a node
+type taint struct {
+	Key    string `yaml:"key"`
+	Value  string `yaml:"value"`
+	Effect string `yaml:"effect"`
+}
+
+// toleration allows a pod to be scheduled on a node with a matching taint
+type toleration struct {
+	Key      string `yaml:"key"`
+	Operator string `yaml:"operator"`
+	Value    string `yaml:"value"`
+	Effect   string `yaml:"effect"`
+}
+
+// nodeRequirement is a node selector requirement of a node affinity term
+type nodeRequirement struct {
+	Key      string   `yaml:"key"`
+	Operator string   `yaml:"operator"`
+	Values   []string `yaml:"values"`
+}
+
+// podScheduling is the part of a pod spec which constrains the nodes it can run on
+type podScheduling struct {
+	NodeSelector map[string]string `yaml:"nodeSelector"`
+	Tolerations  []toleration      `yaml:"tolerations"`
+	Affinity     struct {
+		NodeAffinity struct {
+			Required struct {
+				NodeSelectorTerms []struct {
+					MatchExpressions []nodeRequirement `yaml:"matchExpressions"`
+				} `yaml:"nodeSelectorTerms"`
+			} `yaml:"requiredDuringSchedulingIgnoredDuringExecution"`
+		} `yaml:"nodeAffinity"`
+	} `yaml:"affinity"`
+}
+
+// validateScheduling fails when a workload's pods can't be scheduled on any live node
+func validateScheduling(c *cli.Context, resources []*ObjectResource) error {
+	out, err := runKubeCmd(c, "get", "nodes", "-o", "yaml")
+	if err != nil {
+		return fmt.Errorf("problem getting the nodes: %s", err)
+	}
+	var nodes nodeList
+	if err := yaml.Unmarshal([]byte(out), &nodes); err != nil {
+		return err
+	}
+	problems, err := schedulingProblems(resources, nodes)
+	if err != nil {
+		return err
+	}
+	// Not an error as nodes may yet be added, e.g. to a node pool scaled to zero
+	for _, problem := range problems {
+		logWarn.Printf("pods can't be scheduled on any node: %s", problem)
+	}
+	return nil
+}
+
+// schedulingProblems describes each workload whose pods don't match any schedulable node
+func schedulingProblems(resources []*ObjectResource, nodes nodeList) ([]string, error) {
+	var problems []string
+	for _, r := range resources {
+		var doc map[interface{}]interface{}
+		if err := yaml.Unmarshal(r.Template, &doc); err != nil {
+			return nil, err
+		}
+		podSpec := lookupPath(doc, podTemplatePath(r.Kind, "spec")...)
+		if podSpec == nil {
+			continue
+		}
+		b, err := yaml.Marshal(podSpec)
+		if err != nil {
+			return nil, err
+		}
+		var sched podScheduling
+		if err := yaml.Unmarshal(b, &sched); err != nil {
+			return nil, err
+		}
+		if reason, ok := anyNodeMatches(sched, nodes); !ok {
+			problems = append(problems, fmt.Sprintf("%s/%s (from file:%q) %s", r.Kind, r.Name, r.FileName, reason))
+		}
+	}
+	return problems, nil
+}
+
+// anyNodeMatches checks if pods can be scheduled on a node, or why they can't
+func anyNodeMatches(sched podScheduling, nodes nodeList) (string, bool) {
+	selectorMatched, affinityMatched := false, false
+	for _, node := range nodes.Items {
+		if node.Spec.Unschedulable || !labelsMatch(sched.NodeSelector, node.Metadata.Labels) {
+			continue
+		}
+		selectorMatched = true
+		if !affinityMatches(sched, node.Metadata.Labels) {
+			continue
+		}
+		affinityMatched = true
+		tolerated := true
+		for _, t := range node.Spec.Taints {
+			if (t.Effect == "NoSchedule" || t.Effect == "NoExecute") && !tolerates(sched.Tolerations, t) {
+				tolerated = false
+				break
+			}
+		}
+		if tolerated {
+			return "", true
+		}
+	}
+	switch {
+	case !selectorMatched:
+		return "has a nodeSelector which matches no schedulable nodes", false
+	case !affinityMatched:
+		return "has a required node affinity which matches no schedulable nodes", false
+	}
+	return "doesn't tolerate the taints of the nodes it can run on", false
+}
+
+// labelsMatch checks a node has all the labels of a selector
+func labelsMatch(selector, labels map[string]string) bool {
+	for k, v := range selector {
+		if value, ok := labels[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// affinityMatches checks if any required node affinity term matches the labels
+func affinityMatches(sched podScheduling, labels map[string]string) bool {
+	terms := sched.Affinity.NodeAffinity.Required.NodeSelectorTerms
+	if len(terms) == 0 {
+		return true
+	}
+	for _, term := range terms {
+		matches := true
+		for _, req := range term.MatchExpressions {
+			if !requirementMatches(req, labels) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+// requirementMatches checks a node selector requirement against the labels of a node
+func requirementMatches(req nodeRequirement, labels map[string]string) bool {
+	value, found := labels[req.Key]
+	switch req.Operator {
+	case "In":
+		return found && contains(req.Values, value)
+	case "NotIn":
+		return !found || !contains(req.Values, value)
+	case "Exists":
+		return found
+	case "DoesNotExist":
+		return !found
+	case "Gt", "Lt":
+		if !found || len(req.Values) != 1 {
+			return false
+		}
+		have, err1 := strconv.ParseInt(value, 10, 64)
+		want, err2 := strconv.ParseInt(req.Values[0], 10, 64)
+		if err1 != nil || err2 != nil {
+			return false
+		}
+		if req.Operator == "Gt" {
+			return have > want
+		}
+		return have < want
+	}
+	return false
+}
+
+// tolerates checks if any of the tolerations match a taint
+func tolerates(tolerations []toleration, t taint) bool {
+	for _, tol := range tolerations {
+		if len(tol.Effect) > 0 && tol.Effect != t.Effect {
+			continue
+		}
+		if tol.Operator == "Exists" {
+			if len(tol.Key) == 0 || tol.Key == t.Key {
+				return true
+			}
+			continue
+		}
+		if tol.Key == t.Key && tol.Value == t.Value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestSchedulingProblems(t *testing.T) {
+	data, err := ioutil.ReadFile("test/TestValidateScheduling/nodes.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var nodes nodeList
+	if err := yaml.Unmarshal(data, &nodes); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	fn := "test/TestValidateScheduling/workloads.yaml"
+	workloads, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var resources []*ObjectResource
+	for _, d := range splitYamlDocs(string(workloads)) {
+		r := &ObjectResource{FileName: fn, Template: []byte(d)}
+		if err := yaml.Unmarshal(r.Template, r); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		resources = append(resources, r)
+	}
+
+	got, err := schedulingProblems(resources, nodes)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []string{
+		`Deployment/untolerated (from file:"test/TestValidateScheduling/workloads.yaml") doesn't tolerate the taints of the nodes it can run on`,
+		`Deployment/arm-only (from file:"test/TestValidateScheduling/workloads.yaml") has a required node affinity which matches no schedulable nodes`,
+		`Job/missing-pool (from file:"test/TestValidateScheduling/workloads.yaml") has a nodeSelector which matches no schedulable nodes`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+}
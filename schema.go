@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// DefaultSchemaLocation holds the json schemas for each kubernetes version
+const DefaultSchemaLocation = "https://raw.githubusercontent.com/yannh/kubernetes-json-schema/master"
+
+// schemaLoader fetches the schemas for kinds, keeping them for later resources
+type schemaLoader struct {
+	location string
+	version  string
+	schemas  map[string]map[string]interface{}
+}
+
+// validateSchemas checks every rendered resource against the kubernetes json schemas
+func validateSchemas(c *cli.Context, resources []*ObjectResource) error {
+	loader := &schemaLoader{
+		location: c.String(FlagSchemaLocation),
+		version:  c.String(FlagKubernetesVersion),
+		schemas:  map[string]map[string]interface{}{},
+	}
+	var problems []string
+	for _, r := range resources {
+		var doc interface{}
+		if err := yaml.Unmarshal(r.Template, &doc); err != nil {
+			return err
+		}
+		apiVersion, _ := lookupPath(doc, "apiVersion").(string)
+		schema, err := loader.load(apiVersion, r.Kind)
+		if err != nil {
+			return err
+		}
+		if schema == nil {
+			logWarn.Printf("no schema found for %s %s, skipping validation of %s", apiVersion, r.Kind, resourceRef(r))
+			continue
+		}
+		for _, p := range schemaProblems(schema, doc, "") {
+			problems = append(problems, fmt.Sprintf("%s (from file:%q) %s", resourceRef(r), r.FileName, p))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("resources don't match the kubernetes %s schemas:\n  %s", loader.version, strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// load returns the schema for a kind (nil when there isn't one e.g. custom resources)
+func (l *schemaLoader) load(apiVersion, kind string) (map[string]interface{}, error) {
+	location := schemaPath(l.location, l.version, apiVersion, kind)
+	if schema, found := l.schemas[location]; found {
+		return schema, nil
+	}
+	var data []byte
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		client := http.Client{Timeout: 30 * time.Second}
+		resp, err := client.Get(location)
+		if err != nil {
+			return nil, fmt.Errorf("problem fetching schema %s: %s", location, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			l.schemas[location] = nil
+			return nil, nil
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("problem fetching schema %s: %s", location, resp.Status)
+		}
+		if data, err = ioutil.ReadAll(resp.Body); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if data, err = ioutil.ReadFile(location); err != nil {
+			if os.IsNotExist(err) {
+				l.schemas[location] = nil
+				return nil, nil
+			}
+			return nil, err
+		}
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("problem reading schema %s: %s", location, err)
+	}
+	l.schemas[location] = schema
+	return schema, nil
+}
+
+// schemaPath is the location of the strict schema for a kind, named as in kubeconform
+// e.g. v1.18.0-standalone-strict/deployment-apps-v1.json
+func schemaPath(location, version, apiVersion, kind string) string {
+	if version != "master" && !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	name := strings.ToLower(kind)
+	parts := strings.SplitN(apiVersion, "/", 2)
+	if len(parts) == 2 {
+		name += "-" + strings.Split(parts[0], ".")[0] + "-" + parts[1]
+	} else {
+		name += "-" + parts[0]
+	}
+	return strings.TrimSuffix(location, "/") + "/" + path.Join(version+"-standalone-strict", strings.ToLower(name)+".json")
+}
+
+// schemaProblems describes everywhere a yaml value doesn't match a json schema,
+// supporting the parts of json schema used by the kubernetes schemas
+func schemaProblems(schema map[string]interface{}, value interface{}, at string) []string {
+	if len(schema) == 0 {
+		return nil
+	}
+	for _, key := range []string{"anyOf", "oneOf"} {
+		if options, ok := schema[key].([]interface{}); ok {
+			matched := false
+			for _, option := range options {
+				s, _ := option.(map[string]interface{})
+				if len(schemaProblems(s, value, at)) == 0 {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return []string{fmt.Sprintf("%s: %v doesn't match any of the allowed types", fieldName(at), value)}
+			}
+		}
+	}
+	if types := schemaTypes(schema["type"]); len(types) > 0 && !matchesType(types, value) {
+		return []string{fmt.Sprintf("%s: expected %s but found %s", fieldName(at), strings.Join(types, " or "), valueType(value))}
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if fmt.Sprint(e) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return []string{fmt.Sprintf("%s: %v is not one of %v", fieldName(at), value, enum)}
+		}
+	}
+
+	var problems []string
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, field := range required {
+				if _, found := v[field]; !found {
+					problems = append(problems, fmt.Sprintf("%s: missing required field %v", fieldName(at), field))
+				}
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, fmt.Sprint(k))
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			field := strings.TrimPrefix(at+"."+k, ".")
+			item := v[k]
+			if s, ok := properties[k].(map[string]interface{}); ok {
+				problems = append(problems, schemaProblems(s, item, field)...)
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					problems = append(problems, fmt.Sprintf("%s: unknown field", field))
+				}
+			case map[string]interface{}:
+				problems = append(problems, schemaProblems(additional, item, field)...)
+			}
+		}
+	case []interface{}:
+		if s, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				problems = append(problems, schemaProblems(s, item, fmt.Sprintf("%s[%d]", at, i))...)
+			}
+		}
+	}
+	return problems
+}
+
+// schemaTypes returns the types allowed by a schema
+func schemaTypes(t interface{}) []string {
+	switch t := t.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		var types []string
+		for _, item := range t {
+			types = append(types, fmt.Sprint(item))
+		}
+		return types
+	}
+	return nil
+}
+
+// matchesType checks if a value is one of the json schema types
+func matchesType(types []string, value interface{}) bool {
+	for _, t := range types {
+		actual := valueType(value)
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// valueType is the json schema type of a yaml value
+func valueType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case int, int64, uint64:
+		return "integer"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[interface{}]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// fieldName is the name of a field for messages, the document itself when empty
+func fieldName(at string) string {
+	if len(at) == 0 {
+		return "document"
+	}
+	return at
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestSchemaPath(t *testing.T) {
+	cases := []struct {
+		apiVersion string
+		kind       string
+		version    string
+		want       string
+	}{
+		{"v1", "ConfigMap", "1.18.0", "base/v1.18.0-standalone-strict/configmap-v1.json"},
+		{"apps/v1", "Deployment", "master", "base/master-standalone-strict/deployment-apps-v1.json"},
+		{"networking.k8s.io/v1beta1", "Ingress", "v1.18.0", "base/v1.18.0-standalone-strict/ingress-networking-v1beta1.json"},
+	}
+
+	for _, c := range cases {
+		if got := schemaPath("base/", c.version, c.apiVersion, c.kind); got != c.want {
+			t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+		}
+	}
+}
+
+func TestSchemaProblems(t *testing.T) {
+	loader := &schemaLoader{location: "test/TestValidateSchemas", version: "1.18.0", schemas: map[string]map[string]interface{}{}}
+	schema, err := loader.load("v1", "ConfigMap")
+	if err != nil || schema == nil {
+		t.Fatalf("expected a schema, got error: %v", err)
+	}
+	if missing, err := loader.load("example.com/v1", "Widget"); err != nil || missing != nil {
+		t.Errorf("expected no schema for a custom resource, got: %v %v", missing, err)
+	}
+
+	cases := []struct {
+		name string
+		doc  string
+		want []string
+	}{
+		{
+			name: "Check a valid document has no problems",
+			doc:  "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n  labels:\n    app: web\ndata:\n  a: b\nports: [http, 80]\n",
+		},
+		{
+			name: "Check unknown fields and wrong types are found",
+			doc:  "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  nmae: app\ndata: [a]\nports: [true]\n",
+			want: []string{
+				"data: expected object but found array",
+				"metadata.nmae: unknown field",
+				"ports[0]: true doesn't match any of the allowed types",
+			},
+		},
+		{
+			name: "Check required fields are found",
+			doc:  "kind: ConfigMap\n",
+			want: []string{"document: missing required field apiVersion"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var doc interface{}
+			if err := yaml.Unmarshal([]byte(c.doc), &doc); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			got := schemaProblems(schema, doc, "")
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
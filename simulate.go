@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+var (
+	// statusAPI creates the API the status of resources being watched is fetched with
+	statusAPI = NewK8ApiKubectl
+
+	// simulating is set when watching recorded statuses rather than a cluster
+	simulating bool
+)
+
+// simulate watches the rendered resources using recorded statuses instead of a
+// cluster, reporting if each would complete, fail or time out
+func simulate(c *cli.Context) error {
+	if c.Bool("debug") {
+		logDebug = logDebugIf
+	}
+	if !c.IsSet(FlagStatusFixtures) {
+		return fmt.Errorf("a directory of recorded statuses must be specified with --%s", FlagStatusFixtures)
+	}
+	dryRun = true
+	simulating = true
+	resources, err := renderResources(c)
+	if err != nil {
+		return err
+	}
+	// Each recorded status is a check interval, which is simulated without waiting
+	fixtures := NewK8ApiFixtures(c.String(FlagStatusFixtures), c.Duration("check-interval"),
+		func(r *ObjectResource) (time.Duration, error) { return watchTimeout(c, r) })
+	statusAPI = func(*cli.Context) K8Api { return fixtures }
+	for flag, value := range map[string]string{"check-interval": "1ms", FlagDeployDelay: "0s", FlagHealthCheckRetries: "1"} {
+		if err := c.Set(flag, value); err != nil {
+			return err
+		}
+	}
+
+	var report bytes.Buffer
+	failed := 0
+	for _, r := range resources {
+		if !isWatchableResouce(r) {
+			continue
+		}
+		if found, err := fixtures.Exists(r); err != nil {
+			return err
+		} else if !found {
+			logWarn.Printf("no statuses recorded for %s in %s, skipping", resourceRef(r), fixtureFileName(r))
+			continue
+		}
+		outcome := "complete"
+		if err := watchResource(c, r); err != nil {
+			outcome = "failed: " + strings.TrimSpace(err.Error())
+			failed++
+		}
+		fmt.Fprintf(&report, "\n  %-40s %s", resourceRef(r), outcome)
+	}
+	logSummary.Printf("simulation results:%s", report.String())
+	if failed > 0 {
+		return fmt.Errorf("%d resources would fail", failed)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+)
+
+// skippedResources records the resources a run didn't deploy and why
+var skippedResources skipList
+
+// skipList is the list of skipped resources, safe to use from parallel deploys
+type skipList struct {
+	lock    sync.Mutex
+	skipped []skippedResource
+}
+
+// skippedResource is a resource, or a file of resources, which wasn't deployed with the reason
+type skippedResource struct {
+	ref    string
+	reason string
+	file   bool
+}
+
+// skip logs and records that a resource wasn't deployed
+func (s *skipList) skip(r *ObjectResource, reason string) {
+	logInfo.Printf("skipping %s, %s", resourceRef(r), reason)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.skipped = append(s.skipped, skippedResource{ref: resourceRef(r), reason: reason})
+}
+
+// skipFile logs and records that a file (or directory) of resources wasn't rendered
+func (s *skipList) skipFile(path, reason string) {
+	logDebug.Printf("skipping file:%q, %s", path, reason)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.skipped = append(s.skipped, skippedResource{ref: "file:" + path, reason: reason, file: true})
+}
+
+// count is the number of resources and files skipped
+func (s *skipList) count() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.skipped)
+}
+
+// resourceCount is the number of rendered resources skipped, which weren't deployed
+func (s *skipList) resourceCount() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	count := 0
+	for _, r := range s.skipped {
+		if !r.file {
+			count++
+		}
+	}
+	return count
+}
+
+// report lists each skipped resource with the reason, one per line
+func (s *skipList) report() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var report bytes.Buffer
+	for _, r := range s.skipped {
+		fmt.Fprintf(&report, "\n  %-40s %s", r.ref, r.reason)
+	}
+	return report.String()
+}
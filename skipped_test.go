@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestSkipListReport(t *testing.T) {
+	var s skipList
+	s.skip(&ObjectResource{Kind: "Secret", ObjectMeta: ObjectMeta{Name: "db"}}, "marked as create only and already exists")
+	s.skip(&ObjectResource{Kind: "Ingress", ObjectMeta: ObjectMeta{Name: "app"}}, "completed by an earlier run")
+
+	want := "\n  secret/db                                marked as create only and already exists" +
+		"\n  ingress/app                              completed by an earlier run"
+	if got := s.report(); got != want || s.count() != 2 {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+}
+
+func TestSkipListFiles(t *testing.T) {
+	var s skipList
+	s.skip(&ObjectResource{Kind: "Secret", ObjectMeta: ObjectMeta{Name: "db"}}, "marked as create only and already exists")
+	s.skipFile("kube/README.md", "isn't a manifest (.yaml, .yml, .json or .jsonnet)")
+
+	want := "\n  secret/db                                marked as create only and already exists" +
+		"\n  file:kube/README.md                      isn't a manifest (.yaml, .yml, .json or .jsonnet)"
+	if got := s.report(); got != want {
+		t.Errorf("got: %#v\nwant: %#v\n", got, want)
+	}
+	if s.count() != 2 || s.resourceCount() != 1 {
+		t.Errorf("got: %d skipped, %d resources\nwant: 2 skipped, 1 resource\n", s.count(), s.resourceCount())
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/joho/godotenv"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// sopsFormat is the sops input/output type of a config file
+type sopsFormat string
+
+const (
+	sopsYaml   sopsFormat = "yaml"
+	sopsDotenv sopsFormat = "dotenv"
+)
+
+// readConfigFile reads a config file, decrypting it with sops if it's encrypted
+func readConfigFile(fn string, format sopsFormat) ([]byte, error) {
+	data, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	if !isSopsEncrypted(data, format) {
+		return data, nil
+	}
+	logDebug.Printf("decrypting %s with sops", fn)
+	var stderr bytes.Buffer
+	cmd := exec.Command("sops", "--decrypt", "--input-type", string(format), "--output-type", string(format), fn)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("problem decrypting %s with sops: %s %s", fn, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// isSopsEncrypted checks for the metadata sops adds to the files it encrypts
+func isSopsEncrypted(data []byte, format sopsFormat) bool {
+	switch format {
+	case sopsDotenv:
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.HasPrefix(strings.TrimSpace(line), "sops_mac=") {
+				return true
+			}
+		}
+	case sopsYaml:
+		var doc map[interface{}]interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return false
+		}
+		_, found := lookupPath(doc, "sops", "mac").(string)
+		return found
+	}
+	return false
+}
+
+// loadDotenv sets the variables from (possibly sops encrypted) .env files which
+// aren't already set in the environment, later files overriding earlier ones
+func loadDotenv(files []string) error {
+	merged := map[string]string{}
+	for _, fn := range files {
+		data, err := readConfigFile(fn, sopsDotenv)
+		if err != nil {
+			return err
+		}
+		env, err := godotenv.Unmarshal(string(data))
+		if err != nil {
+			return fmt.Errorf("%s: %s", fn, err)
+		}
+		for k, v := range env {
+			merged[k] = v
+		}
+	}
+	for k, v := range merged {
+		if _, set := os.LookupEnv(k); !set {
+			os.Setenv(k, v)
+		}
+	}
+	return nil
+}
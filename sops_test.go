@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestIsSopsEncrypted(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  string
+		format sopsFormat
+		want   bool
+	}{
+		{
+			name:   "Check an encrypted yaml file is detected",
+			input:  "password: ENC[AES256_GCM,data:abc,type:str]\nsops:\n  mac: ENC[AES256_GCM,data:def,type:str]\n  version: 3.5.0\n",
+			format: sopsYaml,
+			want:   true,
+		},
+		{
+			name:   "Check a plain yaml file is not encrypted",
+			input:  "password: secret\nsops: a value\n",
+			format: sopsYaml,
+		},
+		{
+			name:   "Check an encrypted dotenv file is detected",
+			input:  "PASSWORD=ENC[AES256_GCM,data:abc,type:str]\nsops_mac=ENC[AES256_GCM,data:def,type:str]\nsops_version=3.5.0\n",
+			format: sopsDotenv,
+			want:   true,
+		},
+		{
+			name:   "Check a plain dotenv file is not encrypted",
+			input:  "PASSWORD=secret\n",
+			format: sopsDotenv,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := isSopsEncrypted([]byte(c.input), c.format); got != c.want {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
+
+func TestLoadDotenv(t *testing.T) {
+	os.Setenv("KD_TEST_SOPS_SET", "from-env")
+	defer os.Unsetenv("KD_TEST_SOPS_SET")
+	defer os.Unsetenv("KD_TEST_SOPS_PLAIN")
+	defer os.Unsetenv("KD_TEST_SOPS_PROD")
+	if err := loadDotenv([]string{"test/TestSops/plain.env", "test/TestSops/prod.env"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := os.Getenv("KD_TEST_SOPS_PLAIN"); got != "prod" {
+		t.Errorf("got: %#v\nwant: %#v\n", got, "prod")
+	}
+	if got := os.Getenv("KD_TEST_SOPS_PROD"); got != "only-prod" {
+		t.Errorf("got: %#v\nwant: %#v\n", got, "only-prod")
+	}
+	if got := os.Getenv("KD_TEST_SOPS_SET"); got != "from-env" {
+		t.Errorf("got: %#v\nwant: %#v\n", got, "from-env")
+	}
+}
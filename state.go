@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var (
+	// deployState records the progress of the current release (nil when not tracked)
+	deployState *releaseState
+
+	// resuming is set when continuing a release from the state file
+	resuming bool
+)
+
+// releaseState is the progress of a release, persisted so it can be resumed
+type releaseState struct {
+	// Completed maps each completed resource to a hash of the template applied
+	Completed map[string]string `yaml:"completed"`
+
+	path string
+	lock sync.Mutex
+}
+
+// resume will continue an interrupted release using the state file
+func resume(c *cli.Context) error {
+	if !c.IsSet(FlagStateFile) {
+		return fmt.Errorf("a state file must be specified with --%s to resume a release", FlagStateFile)
+	}
+	resuming = true
+	return run(c)
+}
+
+// loadState reads the state of a release to resume or starts a new one
+func loadState(path string, resume bool) (*releaseState, error) {
+	s := &releaseState{Completed: map[string]string{}, path: path}
+	if !resume {
+		return s, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no state found at %s to resume from", path)
+		}
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("problem reading state file %s: %s", path, err)
+	}
+	if s.Completed == nil {
+		s.Completed = map[string]string{}
+	}
+	return s, nil
+}
+
+// done checks if a resource was already completed with the same template
+func (s *releaseState) done(r *ObjectResource) bool {
+	if s == nil {
+		return false
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.Completed[stateKey(r)] == templateHash(r)
+}
+
+// record saves a resource as completed
+func (s *releaseState) record(r *ObjectResource, key string) error {
+	if s == nil {
+		return nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.Completed[key] = templateHash(r)
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.path, data, 0600)
+}
+
+// finish removes the state once the whole release has completed
+func (s *releaseState) finish() error {
+	if s == nil {
+		return nil
+	}
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// deployTracked deploys a resource unless it was completed by an earlier run
+func deployTracked(c *cli.Context, r *ObjectResource) error {
+	// Generated names are only known after deploying
+	key := stateKey(r)
+	if deployState.done(r) {
+		skippedResources.skip(r, "completed by an earlier run")
+		return nil
+	}
+	if err := deploy(c, r); err != nil {
+		return err
+	}
+	// Resources watched after everything is applied are recorded by watchPending
+	if deferRecord(r, key) {
+		return nil
+	}
+	return deployState.record(r, key)
+}
+
+// stateKey identifies a resource in the state file
+func stateKey(r *ObjectResource) string {
+	name := r.Name
+	if len(name) == 0 {
+		name = r.GenerateName
+	}
+	return strings.ToLower(r.Kind) + "/" + r.Namespace + "/" + name
+}
+
+// templateHash is a hash of the template applied for a resource
+func templateHash(r *ObjectResource) string {
+	return fmt.Sprintf("%x", sha256.Sum256(r.Template))
+}
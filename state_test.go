@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReleaseState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kd-state")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.yaml")
+
+	if _, err := loadState(path, true); err == nil {
+		t.Error("expected an error resuming without a state file")
+	}
+	s, err := loadState(path, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	cm := &ObjectResource{Kind: "ConfigMap", ObjectMeta: ObjectMeta{Name: "app"}, Template: []byte("kind: ConfigMap\n")}
+	job := &ObjectResource{Kind: "Job", ObjectMeta: ObjectMeta{GenerateName: "migrate-"}, Template: []byte("kind: Job\n")}
+	if err := s.record(cm, stateKey(cm)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := s.record(job, stateKey(job)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	resumed, err := loadState(path, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !resumed.done(cm) || !resumed.done(job) {
+		t.Errorf("got: %#v\nwant: %#v\n", resumed.Completed, s.Completed)
+	}
+	changed := &ObjectResource{Kind: "ConfigMap", ObjectMeta: ObjectMeta{Name: "app"}, Template: []byte("kind: ConfigMap\ndata: {}\n")}
+	if resumed.done(changed) {
+		t.Error("expected a changed template not to be done")
+	}
+
+	if err := resumed.finish(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the state file to be removed, got: %v", err)
+	}
+}
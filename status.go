@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// builtinGroups are the api groups of the resources built in to kubernetes, the
+// resources of any other group are custom resources, e.g. from a CRD
+var builtinGroups = []string{
+	"", "admissionregistration.k8s.io", "apiextensions.k8s.io", "apiregistration.k8s.io",
+	"apps", "authentication.k8s.io", "authorization.k8s.io", "autoscaling", "batch",
+	"certificates.k8s.io", "coordination.k8s.io", "discovery.k8s.io", "events.k8s.io",
+	"extensions", "flowcontrol.apiserver.k8s.io", "internal.apiserver.k8s.io",
+	"networking.k8s.io", "node.k8s.io", "policy", "rbac.authorization.k8s.io",
+	"resource.k8s.io", "scheduling.k8s.io", "settings.k8s.io", "storage.k8s.io",
+	"storagemigration.k8s.io",
+}
+
+// isCustomResource checks if a resource is of an api group kubernetes doesn't have
+// built in, so a kind such as Deployment from another group isn't mistaken for it
+func isCustomResource(r *ObjectResource) bool {
+	return !contains(builtinGroups, apiGroup(r.APIVersion))
+}
+
+// Generation is an observed generation, which some custom resources report as a string
+type Generation int64
+
+// UnmarshalYAML reads a generation given as a number or a string, a generation
+// which can't be read is treated as not reported rather than failing the watch
+func (g *Generation) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value int64
+	if err := unmarshal(&value); err == nil {
+		*g = Generation(value)
+		return nil
+	}
+	var s string
+	if err := unmarshal(&s); err == nil {
+		value, _ = strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	}
+	*g = Generation(value)
+	return nil
+}
+
+// apiGroup is the group of an api version, empty for the core group
+func apiGroup(apiVersion string) string {
+	if i := strings.Index(apiVersion, "/"); i >= 0 {
+		return apiVersion[:i]
+	}
+	return ""
+}
+
+// genericStatus checks if a resource is ready using the standard status conventions
+// (observedGeneration and the Ready / Available conditions). Resources without
+// these are ready as soon as they are applied. It returns what is being waited
+// for when not ready, or an error if the resource has stalled.
+func genericStatus(r *ObjectResource) (bool, string, error) {
+	if r.ObservedGeneration > 0 && int64(r.ObservedGeneration) < r.Generation {
+		return false, fmt.Sprintf("waiting for generation %d to be observed", r.Generation), nil
+	}
+	ready := true
+	waiting := ""
+	for _, cond := range r.Conditions {
+		switch cond.Type {
+		case "Stalled":
+			if cond.Status == "True" {
+				return false, "", fmt.Errorf("%s %q has stalled: %s %s", r.Kind, r.Name, cond.Reason, cond.Message)
+			}
+		case "Reconciling":
+			if cond.Status == "True" {
+				ready = false
+				waiting = fmt.Sprintf("waiting for reconciliation: %s %s", cond.Reason, cond.Message)
+			}
+		case "Ready", "Available":
+			if cond.Status != "True" {
+				ready = false
+				waiting = fmt.Sprintf("waiting for %s: %s %s", cond.Type, cond.Reason, cond.Message)
+			}
+		}
+	}
+	return ready, waiting, nil
+}
+
+// watchTimeout is how long to wait for a resource, from its timeout annotation,
+// the hook timeout for jobs or the timeout for everything else
+func watchTimeout(c *cli.Context, r *ObjectResource) (time.Duration, error) {
+	return resourceTimeout(r, c.Duration("timeout"), c.Duration(FlagHookTimeout))
+}
+
+// resourceTimeout chooses the timeout for a resource (a zero hook timeout is unset),
+// allowing for the time its pods take to become available unless it is annotated
+func resourceTimeout(r *ObjectResource, timeout, hookTimeout time.Duration) (time.Duration, error) {
+	if _, ok := r.Annotations[AnnotationTimeout]; ok {
+		return baseTimeout(r, timeout, hookTimeout)
+	}
+	d, err := baseTimeout(r, timeout, hookTimeout)
+	return d + availabilityDelay(r), err
+}
+
+// baseTimeout is the timeout annotation of a resource, the hook timeout for jobs
+// or the timeout for everything else
+func baseTimeout(r *ObjectResource, timeout, hookTimeout time.Duration) (time.Duration, error) {
+	if v, ok := r.Annotations[AnnotationTimeout]; ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s annotation %q for %s %q: %s", AnnotationTimeout, v, r.Kind, r.Name, err)
+		}
+		return d, nil
+	}
+	if r.Kind == "Job" && hookTimeout > 0 {
+		return hookTimeout, nil
+	}
+	return timeout, nil
+}
+
+// availabilityDelay is the time a workload's pods deliberately take to become
+// available, its minReadySeconds and longest readiness probe initial delay
+func availabilityDelay(r *ObjectResource) time.Duration {
+	if !contains(rolloutKinds, r.Kind) {
+		return 0
+	}
+	var doc map[interface{}]interface{}
+	if err := yaml.Unmarshal(r.Template, &doc); err != nil {
+		return 0
+	}
+	seconds, _ := lookupPath(doc, "spec", "minReadySeconds").(int)
+	longest := 0
+	for _, ctr := range listAt(doc, podTemplatePath(r.Kind, "spec", "containers")...) {
+		if delay, ok := lookupPath(ctr, "readinessProbe", "initialDelaySeconds").(int); ok && delay > longest {
+			longest = delay
+		}
+	}
+	return time.Duration(seconds+longest) * time.Second
+}
+
+// desiredReplicas is the number of pods a workload should have, the api server
+// defaults it to 1 when it isn't set
+func desiredReplicas(r *ObjectResource) int32 {
+	if r.ObjectSpec.Replicas == nil {
+		return 1
+	}
+	return *r.ObjectSpec.Replicas
+}
+
+// availabilityProgress describes a workload whose pods are ready but not yet
+// available because of its minReadySeconds, empty otherwise
+func availabilityProgress(r *ObjectResource) string {
+	if r.ObjectSpec.MinReadySeconds == 0 {
+		return ""
+	}
+	waiting := false
+	switch r.Kind {
+	case "Deployment":
+		waiting = r.DeploymentStatus.UpdatedReplicas == r.DeploymentStatus.Replicas &&
+			r.DeploymentStatus.ReadyReplicas == r.DeploymentStatus.Replicas &&
+			r.DeploymentStatus.AvailableReplicas < r.DeploymentStatus.Replicas
+	case "DaemonSet":
+		waiting = r.DeploymentStatus.UpdatedNumberScheduled == r.DeploymentStatus.DesiredNumberScheduled &&
+			r.DeploymentStatus.NumberReady == r.DeploymentStatus.DesiredNumberScheduled &&
+			r.DeploymentStatus.NumberAvailable < r.DeploymentStatus.DesiredNumberScheduled
+	}
+	if !waiting {
+		return ""
+	}
+	return fmt.Sprintf("pods started, waiting out minReadySeconds of %ds", r.ObjectSpec.MinReadySeconds)
+}
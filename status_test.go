@@ -0,0 +1,204 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestGenericStatus(t *testing.T) {
+	cases := []struct {
+		name      string
+		status    DeploymentStatus
+		gen       int64
+		wantReady bool
+		wantErr   bool
+	}{
+		{
+			name:      "Check a resource without status is ready",
+			wantReady: true,
+		},
+		{
+			name:   "Check an unobserved generation is not ready",
+			gen:    3,
+			status: DeploymentStatus{ObservedGeneration: 2, Conditions: []Condition{{Type: "Ready", Status: "True"}}},
+		},
+		{
+			name:      "Check a ready condition is ready",
+			gen:       3,
+			status:    DeploymentStatus{ObservedGeneration: 3, Conditions: []Condition{{Type: "Ready", Status: "True"}}},
+			wantReady: true,
+		},
+		{
+			name:   "Check an unavailable resource is not ready",
+			status: DeploymentStatus{Conditions: []Condition{{Type: "Available", Status: "False", Reason: "Progressing"}}},
+		},
+		{
+			name:   "Check a reconciling resource is not ready",
+			status: DeploymentStatus{Conditions: []Condition{{Type: "Ready", Status: "True"}, {Type: "Reconciling", Status: "True"}}},
+		},
+		{
+			name:    "Check a stalled resource is an error",
+			status:  DeploymentStatus{Conditions: []Condition{{Type: "Stalled", Status: "True", Reason: "InvalidSpec"}}},
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := &ObjectResource{Kind: "Rollout", DeploymentStatus: c.status}
+			r.Generation = c.gen
+			ready, _, err := genericStatus(r)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ready != c.wantReady {
+				t.Errorf("got: %#v\nwant: %#v\n", ready, c.wantReady)
+			}
+		})
+	}
+}
+
+func TestResourceTimeout(t *testing.T) {
+	cases := []struct {
+		name        string
+		r           *ObjectResource
+		hookTimeout time.Duration
+		want        time.Duration
+		wantErr     bool
+	}{
+		{
+			name: "Check workloads use the timeout",
+			r:    &ObjectResource{Kind: "Deployment"},
+			want: 3 * time.Minute,
+		},
+		{
+			name: "Check jobs use the timeout without a hook timeout",
+			r:    &ObjectResource{Kind: "Job"},
+			want: 3 * time.Minute,
+		},
+		{
+			name:        "Check jobs use the hook timeout",
+			r:           &ObjectResource{Kind: "Job"},
+			hookTimeout: 20 * time.Minute,
+			want:        20 * time.Minute,
+		},
+		{
+			name:        "Check the annotation overrides the hook timeout",
+			r:           &ObjectResource{Kind: "Job", ObjectMeta: ObjectMeta{Annotations: map[string]string{AnnotationTimeout: "1h"}}},
+			hookTimeout: 20 * time.Minute,
+			want:        time.Hour,
+		},
+		{
+			name: "Check workloads wait out minReadySeconds and the readiness probe delay",
+			r: &ObjectResource{Kind: "Deployment", Template: []byte("kind: Deployment\nspec:\n  minReadySeconds: 30\n" +
+				"  template:\n    spec:\n      containers:\n      - name: app\n        readinessProbe:\n          initialDelaySeconds: 90\n")},
+			want: 5 * time.Minute,
+		},
+		{
+			name:    "Check an invalid annotation is an error",
+			r:       &ObjectResource{Kind: "Deployment", ObjectMeta: ObjectMeta{Annotations: map[string]string{AnnotationTimeout: "soon"}}},
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := resourceTimeout(c.r, 3*time.Minute, c.hookTimeout)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != c.want {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
+
+func TestAvailabilityProgress(t *testing.T) {
+	cases := []struct {
+		name string
+		r    *ObjectResource
+		want string
+	}{
+		{
+			name: "Check pods ready but not available wait out minReadySeconds",
+			r: &ObjectResource{Kind: "Deployment", ObjectSpec: ObjectSpec{MinReadySeconds: 30},
+				DeploymentStatus: DeploymentStatus{Replicas: 3, UpdatedReplicas: 3, ReadyReplicas: 3, AvailableReplicas: 1}},
+			want: "pods started, waiting out minReadySeconds of 30s",
+		},
+		{
+			name: "Check pods which aren't ready are still waited for",
+			r: &ObjectResource{Kind: "Deployment", ObjectSpec: ObjectSpec{MinReadySeconds: 30},
+				DeploymentStatus: DeploymentStatus{Replicas: 3, UpdatedReplicas: 3, ReadyReplicas: 2, AvailableReplicas: 1}},
+		},
+		{
+			name: "Check daemonsets wait out minReadySeconds",
+			r: &ObjectResource{Kind: "DaemonSet", ObjectSpec: ObjectSpec{MinReadySeconds: 10},
+				DeploymentStatus: DeploymentStatus{DesiredNumberScheduled: 2, UpdatedNumberScheduled: 2, NumberReady: 2}},
+			want: "pods started, waiting out minReadySeconds of 10s",
+		},
+		{
+			name: "Check workloads without minReadySeconds",
+			r:    &ObjectResource{Kind: "Deployment", DeploymentStatus: DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, ReadyReplicas: 1}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := availabilityProgress(c.r); got != c.want {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
+
+func TestIsCustomResource(t *testing.T) {
+	cases := []struct {
+		name       string
+		apiVersion string
+		kind       string
+		want       bool
+	}{
+		{name: "Check core resources aren't custom", apiVersion: "v1", kind: "ConfigMap"},
+		{name: "Check built in groups aren't custom", apiVersion: "apps/v1", kind: "Deployment"},
+		{name: "Check built in kinds without an installation order aren't custom", apiVersion: "networking.k8s.io/v1", kind: "IngressClass"},
+		{name: "Check other groups are custom", apiVersion: "argoproj.io/v1alpha1", kind: "Rollout", want: true},
+		{name: "Check a custom kind named as a built in kind is custom", apiVersion: "example.com/v1", kind: "Deployment", want: true},
+		{name: "Check groups under k8s.io which aren't built in are custom", apiVersion: "gateway.networking.k8s.io/v1", kind: "Gateway", want: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := &ObjectResource{APIVersion: c.apiVersion, Kind: c.kind}
+			if got := isCustomResource(r); got != c.want {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
+
+func TestGenerationUnmarshal(t *testing.T) {
+	cases := []struct {
+		name   string
+		status string
+		want   Generation
+	}{
+		{name: "Check a number is read", status: "observedGeneration: 3", want: 3},
+		{name: "Check a string is read", status: `observedGeneration: "3"`, want: 3},
+		{name: "Check an invalid generation is not reported", status: `observedGeneration: "latest"`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var status DeploymentStatus
+			if err := yaml.Unmarshal([]byte(c.status), &status); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if status.ObservedGeneration != c.want {
+				t.Errorf("got: %#v\nwant: %#v\n", status.ObservedGeneration, c.want)
+			}
+		})
+	}
+}
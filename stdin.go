@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// stdinFile is the --file name which reads manifests from stdin
+const stdinFile = "-"
+
+// errEmptyStdin is the error when -f - is used without piping any manifests to kd
+var errEmptyStdin = errors.New("no manifests were read from stdin for --file -")
+
+var (
+	// stdinOnce reads stdin the first time the manifests are rendered, it can't be read again
+	stdinOnce sync.Once
+	stdinData []byte
+	stdinErr  error
+)
+
+// readManifest reads a manifest file, one fetched from a url, or the manifests piped
+// to kd for -
+func readManifest(fn string) ([]byte, error) {
+	if data, found := remoteManifests[fn]; found {
+		return data, nil
+	}
+	if fn != stdinFile {
+		return ioutil.ReadFile(fn)
+	}
+	stdinOnce.Do(func() {
+		logDebug.Printf("reading manifests from stdin")
+		stdinData, stdinErr = readStdin(os.Stdin)
+	})
+	return stdinData, stdinErr
+}
+
+// readStdin reads all the manifests from a reader
+func readStdin(r io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errEmptyStdin
+	}
+	return data, nil
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadStdin(t *testing.T) {
+	manifests := "kind: ConfigMap\n---\nkind: Secret\n"
+	got, err := readStdin(strings.NewReader(manifests))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(got) != manifests {
+		t.Errorf("got: %#v\nwant: %#v\n", string(got), manifests)
+	}
+	if _, err := readStdin(strings.NewReader("")); err != errEmptyStdin {
+		t.Errorf("got: %#v\nwant: %#v\n", err, errEmptyStdin)
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// quantitySuffixes are the multipliers of the kubernetes quantity suffixes
+var quantitySuffixes = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"Ti", 1 << 40}, {"Pi", 1 << 50},
+	{"k", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12}, {"P", 1e15},
+}
+
+// storageClaim is storage requested by a PersistentVolumeClaim or StatefulSet
+type storageClaim struct {
+	source string
+	class  string
+	// bytes is the size of each of the claims named
+	bytes  int64
+	claims []string
+}
+
+// validateStorage checks the storage classes and quota for the storage requested
+func validateStorage(c *cli.Context, resources []*ObjectResource) error {
+	claims, err := storageClaims(resources)
+	if err != nil || len(claims) == 0 {
+		return err
+	}
+	out, err := runKubeCmd(c, "get", "storageclasses", "--no-headers",
+		"-o", "custom-columns=NAME:.metadata.name,MODE:.volumeBindingMode")
+	if err != nil {
+		return fmt.Errorf("problem getting the storage classes: %s", err)
+	}
+	classes := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			classes[fields[0]] = fields[1]
+		}
+	}
+	for _, r := range resources {
+		if r.Kind == "StorageClass" {
+			classes[r.Name] = "Immediate"
+		}
+	}
+	quota, err := runKubeCmd(c, "get", "resourcequotas", "--no-headers", "-o",
+		"custom-columns=HARD:.status.hard."+jsonPathKey("requests.storage")+",USED:.status.used."+jsonPathKey("requests.storage"))
+	if err != nil {
+		return fmt.Errorf("problem getting the resource quotas: %s", err)
+	}
+	remaining, err := storageRemaining(quota)
+	if err != nil {
+		return err
+	}
+	out, err = runKubeCmd(c, "get", "persistentvolumeclaims", "--no-headers",
+		"-o", "custom-columns=NAME:.metadata.name,SIZE:.spec.resources.requests.storage")
+	if err != nil {
+		return fmt.Errorf("problem getting the persistent volume claims: %s", err)
+	}
+	existing, err := existingClaims(out)
+	if err != nil {
+		return err
+	}
+	problems, warnings := storageProblems(claims, classes, remaining, existing)
+	for _, w := range warnings {
+		logWarn.Print(w)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid storage:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// storageClaims returns the storage requested by PersistentVolumeClaims and StatefulSets
+func storageClaims(resources []*ObjectResource) ([]storageClaim, error) {
+	var claims []storageClaim
+	for _, r := range resources {
+		var doc map[interface{}]interface{}
+		if err := yaml.Unmarshal(r.Template, &doc); err != nil {
+			return nil, err
+		}
+		// The spec of each claim and the names of the claims created from it
+		var specs []interface{}
+		var names [][]string
+		switch r.Kind {
+		case "PersistentVolumeClaim":
+			specs = append(specs, lookupPath(doc, "spec"))
+			names = append(names, []string{r.Name})
+		case "StatefulSet":
+			replicas := 1
+			if n, ok := lookupPath(doc, "spec", "replicas").(int); ok {
+				replicas = n
+			}
+			for _, t := range listAt(doc, "spec", "volumeClaimTemplates") {
+				specs = append(specs, lookupPath(t, "spec"))
+				// StatefulSet claims are named TEMPLATE-STATEFULSET-ORDINAL
+				var claimNames []string
+				for i := 0; i < replicas; i++ {
+					claimNames = append(claimNames, fmt.Sprintf("%v-%s-%d", lookupPath(t, "metadata", "name"), r.Name, i))
+				}
+				names = append(names, claimNames)
+			}
+		default:
+			continue
+		}
+		for i, spec := range specs {
+			class, _ := lookupPath(spec, "storageClassName").(string)
+			size := fmt.Sprint(lookupPath(spec, "resources", "requests", "storage"))
+			bytes, err := parseQuantity(size)
+			if err != nil {
+				return nil, fmt.Errorf("%s/%s (from file:%q) has an invalid storage request: %s", r.Kind, r.Name, r.FileName, err)
+			}
+			claims = append(claims, storageClaim{
+				source: fmt.Sprintf("%s/%s (from file:%q)", r.Kind, r.Name, r.FileName),
+				class:  class,
+				bytes:  bytes,
+				claims: names[i],
+			})
+		}
+	}
+	return claims, nil
+}
+
+// storageRemaining returns the least storage left by the quotas, or -1 without a quota
+func storageRemaining(out string) (int64, error) {
+	remaining := int64(-1)
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] == noValue {
+			continue
+		}
+		hard, err := parseQuantity(fields[0])
+		if err != nil {
+			return 0, err
+		}
+		used := int64(0)
+		if fields[1] != noValue {
+			if used, err = parseQuantity(fields[1]); err != nil {
+				return 0, err
+			}
+		}
+		if remaining < 0 || hard-used < remaining {
+			remaining = hard - used
+		}
+	}
+	return remaining, nil
+}
+
+// existingClaims returns the size of each existing persistent volume claim
+func existingClaims(out string) (map[string]int64, error) {
+	existing := map[string]int64{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[1] == noValue {
+			continue
+		}
+		size, err := parseQuantity(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		existing[fields[0]] = size
+	}
+	return existing, nil
+}
+
+// storageProblems checks the claims against the storage classes (name to binding
+// mode) and the storage remaining, returning any problems and warnings. Only the
+// storage added is counted against the quota: new claims and the increase of
+// resized ones (the existing claims by name to size).
+func storageProblems(claims []storageClaim, classes map[string]string, remaining int64, existing map[string]int64) ([]string, []string) {
+	var problems, warnings []string
+	total := int64(0)
+	for _, claim := range claims {
+		for _, name := range claim.claims {
+			if added := claim.bytes - existing[name]; added > 0 {
+				total += added
+			}
+		}
+		if len(claim.class) == 0 {
+			continue
+		}
+		mode, found := classes[claim.class]
+		if !found {
+			problems = append(problems, fmt.Sprintf("%s uses missing StorageClass/%s", claim.source, claim.class))
+			continue
+		}
+		if mode == "WaitForFirstConsumer" {
+			warnings = append(warnings, fmt.Sprintf(
+				"%s uses StorageClass/%s which waits for the first pod before provisioning, so the rollout includes provisioning time",
+				claim.source, claim.class))
+		}
+	}
+	if remaining >= 0 && total > remaining {
+		problems = append(problems, fmt.Sprintf("%d bytes of additional storage requested but only %d bytes are left in the quota", total, remaining))
+	}
+	return problems, warnings
+}
+
+// parseQuantity parses a kubernetes quantity e.g. 10Gi as a number of bytes
+func parseQuantity(q string) (int64, error) {
+	multiplier := int64(1)
+	number := q
+	for _, s := range quantitySuffixes {
+		if strings.HasSuffix(q, s.suffix) {
+			multiplier = s.multiplier
+			number = strings.TrimSuffix(q, s.suffix)
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quantity %q", q)
+	}
+	return int64(n * float64(multiplier)), nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseQuantity(t *testing.T) {
+	cases := map[string]int64{
+		"10Gi":  10 << 30,
+		"500Mi": 500 << 20,
+		"1.5G":  1500000000,
+		"1024":  1024,
+	}
+	for q, want := range cases {
+		got, err := parseQuantity(q)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got != want {
+			t.Errorf("got: %#v\nwant: %#v\n", got, want)
+		}
+	}
+	if _, err := parseQuantity("lots"); err == nil {
+		t.Error("expected an error for an invalid quantity")
+	}
+}
+
+func TestStorageProblems(t *testing.T) {
+	resources := []*ObjectResource{
+		{Kind: "PersistentVolumeClaim", ObjectMeta: ObjectMeta{Name: "data"}, FileName: "pvc.yaml",
+			Template: []byte("kind: PersistentVolumeClaim\nspec:\n  storageClassName: fast\n  resources:\n    requests:\n      storage: 10Gi\n")},
+		{Kind: "StatefulSet", ObjectMeta: ObjectMeta{Name: "db"}, FileName: "db.yaml",
+			Template: []byte("kind: StatefulSet\nspec:\n  replicas: 3\n  volumeClaimTemplates:\n  - spec:\n      storageClassName: local\n" +
+				"      resources:\n        requests:\n          storage: 20Gi\n")},
+	}
+	claims, err := storageClaims(resources)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	remaining, err := storageRemaining("50Gi   0\n<none>   <none>\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	problems, warnings := storageProblems(claims, map[string]string{"local": "WaitForFirstConsumer"}, remaining, map[string]int64{})
+	wantProblems := []string{
+		`PersistentVolumeClaim/data (from file:"pvc.yaml") uses missing StorageClass/fast`,
+		"75161927680 bytes of additional storage requested but only 53687091200 bytes are left in the quota",
+	}
+	if !reflect.DeepEqual(problems, wantProblems) {
+		t.Errorf("got: %#v\nwant: %#v\n", problems, wantProblems)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("got: %#v\nwant: a WaitForFirstConsumer warning\n", warnings)
+	}
+}
+
+func TestStorageProblemsExistingClaims(t *testing.T) {
+	resources := []*ObjectResource{
+		{Kind: "PersistentVolumeClaim", ObjectMeta: ObjectMeta{Name: "data"}, FileName: "pvc.yaml",
+			Template: []byte("kind: PersistentVolumeClaim\nspec:\n  resources:\n    requests:\n      storage: 15Gi\n")},
+		{Kind: "StatefulSet", ObjectMeta: ObjectMeta{Name: "db"}, FileName: "db.yaml",
+			Template: []byte("kind: StatefulSet\nspec:\n  replicas: 3\n  volumeClaimTemplates:\n  - metadata:\n      name: pgdata\n" +
+				"    spec:\n      resources:\n        requests:\n          storage: 20Gi\n")},
+	}
+	claims, err := storageClaims(resources)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	existing, err := existingClaims("data        10Gi\npgdata-db-0   20Gi\npgdata-db-1   20Gi\nother   <none>\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	cases := []struct {
+		name      string
+		remaining int64
+		want      []string
+	}{
+		{
+			name:      "Check only the resize and new claims are counted",
+			remaining: 25 << 30,
+		},
+		{
+			name:      "Check the storage added is more than the quota left",
+			remaining: 20 << 30,
+			want:      []string{"26843545600 bytes of additional storage requested but only 21474836480 bytes are left in the quota"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			problems, _ := storageProblems(claims, map[string]string{}, c.remaining, existing)
+			if !reflect.DeepEqual(problems, c.want) {
+				t.Errorf("got: %#v\nwant: %#v\n", problems, c.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,33 @@
 package main
 
+const (
+	// LabelManaged is the label set on resources and namespaces managed by kd
+	LabelManaged = "kd.uswitch.io/managed"
+	// LabelRelease is the label recording which kd release a resource belongs to
+	LabelRelease = "kd.uswitch.io/release"
+	// LabelEnv is the label recording which kd env a resource was deployed to
+	LabelEnv = "kd.uswitch.io/env"
+	// LabelReleaseHistory is the label recording which release a revision's manifests are of
+	LabelReleaseHistory = "kd.uswitch.io/release-history"
+	// LabelRevision is the label recording the revision of a release's manifests
+	LabelRevision = "kd.uswitch.io/revision"
+	// AnnotationAdopted is the annotation recording when an existing resource was adopted
+	AnnotationAdopted = "kd.uswitch.io/adopted"
+	// AnnotationExpires is the annotation recording when a resource should be removed
+	AnnotationExpires = "kd.uswitch.io/expires"
+	// AnnotationTimeout overrides how long to wait for a resource to complete
+	AnnotationTimeout = "kd.uswitch.io/timeout"
+	// AnnotationDependsOn lists the kind/name of resources which must be ready first
+	AnnotationDependsOn = "kd.uswitch.io/depends-on"
+	// AnnotationProbeHTTP is the PORT/PATH of an http health endpoint kd checks on each pod
+	AnnotationProbeHTTP = "kd.uswitch.io/probe-http"
+	// AnnotationProbeGRPC is the PORT[/SERVICE] of a grpc health endpoint kd checks on each pod
+	AnnotationProbeGRPC = "kd.uswitch.io/probe-grpc"
+)
+
 // ObjectResource is minimal kubernetes resource representation
 type ObjectResource struct {
+	APIVersion       string `yaml:"apiVersion,omitempty"`
 	Kind             string `yaml:"kind"`
 	ObjectMeta       `yaml:"metadata,omitempty"`
 	Template         []byte `yaml:"-"`
@@ -9,6 +35,7 @@ type ObjectResource struct {
 	DeploymentStatus `yaml:"status,omitempty"`
 	ObjectSpec       `yaml:"spec"`
 	CreateOnly       bool `yaml:"-"`
+	Changed          bool `yaml:"-"`
 }
 
 // ObjectMeta is a resource metadata that all persisted resources must have
@@ -27,12 +54,20 @@ type ObjectMeta struct {
 
 	// GenerateName causes kubernetes to generate a random resource name for you on create, it takes the given string and suffixes a random string to it
 	GenerateName string `yaml:"generateName,omitempty"`
+
+	// A sequence number representing a specific generation of the desired state.
+	// Populated by the system.
+	Generation int64 `yaml:"generation,omitempty"`
+
+	// Annotations is an unstructured key value map stored with a resource that may be
+	// set by external tools to store and retrieve arbitrary metadata.
+	Annotations map[string]string `yaml:"annotations,omitempty"`
 }
 
 // DeploymentStatus is the most recently observed status of the Deployment / Statefulset / DaemonSets.
 type DeploymentStatus struct {
 	// The generation observed by the deployment controller.
-	ObservedGeneration int64 `yaml:"observedGeneration,omitempty"`
+	ObservedGeneration Generation `yaml:"observedGeneration,omitempty"`
 
 	// Total number of non-terminated pods targeted by this deployment (their labels match the selector).
 	Replicas int32 `yaml:"replicas,omitempty"`
@@ -80,6 +115,24 @@ type DeploymentStatus struct {
 
 	// Job Succeeded status
 	Succeeded int32 `yaml:"succeeded,omitempty"`
+
+	// Conditions are the latest available observations of a resource's current state.
+	Conditions []Condition `yaml:"conditions,omitempty"`
+}
+
+// Condition is an observation of the state of a resource, following the kubernetes conventions
+type Condition struct {
+	// Type of the condition e.g. Ready or Available
+	Type string `yaml:"type"`
+
+	// Status of the condition, one of True, False or Unknown
+	Status string `yaml:"status"`
+
+	// Reason is a machine readable explanation for the condition's last transition
+	Reason string `yaml:"reason,omitempty"`
+
+	// Message is a human readable explanation for the condition's last transition
+	Message string `yaml:"message,omitempty"`
 }
 
 // ObjectSpec - fields used for setting StatefulSet update behaviour
@@ -87,8 +140,24 @@ type ObjectSpec struct {
 	// UpdateStrategy indicates the StatefulSetUpdateStrategy that will be employed to update Pods in the StatefulSet when a revision is made to Template.
 	UpdateStrategy `yaml:"updateStrategy,omitempty"`
 
-	// Replicas indicates how many intended pods are required for a StatefulSet
-	Replicas int32 `yaml:"replicas,omitempty"`
+	// Replicas indicates how many intended pods are required for a StatefulSet, nil
+	// when a manifest leaves it to the cluster (e.g. an autoscaler)
+	Replicas *int32 `yaml:"replicas,omitempty"`
+
+	// Selector is a label query over the pods managed by a workload
+	Selector LabelSelector `yaml:"selector,omitempty"`
+
+	// MinReadySeconds is how long a new pod must be ready before it is available
+	MinReadySeconds int32 `yaml:"minReadySeconds,omitempty"`
+
+	// Paused stops a Deployment rolling out changes to its pod template until it is resumed
+	Paused bool `yaml:"paused,omitempty"`
+}
+
+// LabelSelector is a label query over a set of resources
+type LabelSelector struct {
+	// MatchLabels is a map of {key,value} pairs which must all match
+	MatchLabels map[string]string `yaml:"matchLabels,omitempty"`
 }
 
 // UpdateStrategy indicates the StatefulSetUpdateStrategy that will be employed to update Pods in the StatefulSet when a revision is made to Template.
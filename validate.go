@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// referenceLookup finds referenced resources which are not part of the release
+type referenceLookup struct {
+	// exists checks if a resource of a kind and name exists
+	exists func(kind, name string) bool
+	// podsMatch checks if any pods match a label selector
+	podsMatch func(selector string) bool
+}
+
+// podTemplateKinds are the kinds with a pod template at spec.template
+var podTemplateKinds = []string{"Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "Job"}
+
+// validateReferences checks the references between the rendered resources
+func validateReferences(c *cli.Context, resources []*ObjectResource) error {
+	lookup := referenceLookup{
+		exists: func(kind, name string) bool {
+			if dryRun {
+				return false
+			}
+			found, err := NewK8ApiKubectl(c).Exists(&ObjectResource{Kind: kind, ObjectMeta: ObjectMeta{Name: name}})
+			return err == nil && found
+		},
+		podsMatch: func(selector string) bool {
+			if dryRun {
+				return false
+			}
+			out, err := runKubeCmd(c, "get", "pods", "-l", selector, "-o", "name")
+			return err == nil && len(strings.TrimSpace(out)) > 0
+		},
+	}
+	problems, err := referenceProblems(resources, lookup)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid references between resources:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// checkDuplicates fails when two resources have the same kind, namespace and name
+func checkDuplicates(resources []*ObjectResource, namespace string) error {
+	seen := map[string]*ObjectResource{}
+	for _, r := range resources {
+		// Generated names can't clash
+		if len(r.Name) == 0 {
+			continue
+		}
+		ns := r.Namespace
+		if isClusterScoped(r.Kind) {
+			ns = ""
+		} else if len(ns) == 0 {
+			ns = namespace
+		}
+		key := strings.ToLower(r.Kind) + "/" + ns + "/" + r.Name
+		if first, found := seen[key]; found {
+			return fmt.Errorf("duplicate resource %s/%s (namespace:%q) found in file:%q and file:%q",
+				r.Kind, r.Name, ns, first.FileName, r.FileName)
+		}
+		seen[key] = r
+	}
+	return nil
+}
+
+// checkStrictYaml fails on duplicate keys and documents which aren't a map of fields
+func checkStrictYaml(data []byte) error {
+	var doc interface{}
+	if err := yaml.UnmarshalStrict(data, &doc); err != nil {
+		return err
+	}
+	switch doc.(type) {
+	case nil, map[interface{}]interface{}:
+		return nil
+	}
+	return fmt.Errorf("expected a map of fields but found %s", valueType(doc))
+}
+
+// referenceProblems returns a description of every reference which can't be found
+func referenceProblems(resources []*ObjectResource, lookup referenceLookup) ([]string, error) {
+	docs := make([]map[interface{}]interface{}, len(resources))
+	defined := map[string]bool{}
+	var podLabels []map[interface{}]interface{}
+	for i, r := range resources {
+		if err := yaml.Unmarshal(r.Template, &docs[i]); err != nil {
+			return nil, err
+		}
+		defined[r.Kind+"/"+r.Name] = true
+		if labels, ok := lookupPath(docs[i], podTemplatePath(r.Kind, "metadata", "labels")...).(map[interface{}]interface{}); ok {
+			podLabels = append(podLabels, labels)
+		}
+	}
+	found := func(kind, name string) bool {
+		return defined[kind+"/"+name] || lookup.exists(kind, name)
+	}
+
+	var problems []string
+	for i, r := range resources {
+		doc := docs[i]
+		source := fmt.Sprintf("%s/%s (from file:%q)", r.Kind, r.Name, r.FileName)
+		switch r.Kind {
+		case "Service":
+			selector, _ := lookupPath(doc, "spec", "selector").(map[interface{}]interface{})
+			if len(selector) == 0 || selectorMatches(selector, podLabels) {
+				continue
+			}
+			s := selectorString(selector)
+			if !lookup.podsMatch(s) {
+				problems = append(problems, fmt.Sprintf("%s selector %s matches no workloads", source, s))
+			}
+		case "Ingress":
+			for _, name := range ingressServices(doc) {
+				if !found("Service", name) {
+					problems = append(problems, fmt.Sprintf("%s backend references missing Service/%s", source, name))
+				}
+			}
+		default:
+			podSpec, _ := lookupPath(doc, podTemplatePath(r.Kind, "spec")...).(map[interface{}]interface{})
+			for _, ref := range podSpecReferences(podSpec) {
+				parts := strings.SplitN(ref, "/", 2)
+				if !found(parts[0], parts[1]) {
+					problems = append(problems, fmt.Sprintf("%s references missing %s", source, ref))
+				}
+			}
+		}
+	}
+	return problems, nil
+}
+
+// podTemplatePath returns the path to a field of the pod template for a kind
+func podTemplatePath(kind string, field ...string) []string {
+	switch {
+	case kind == "Pod":
+		return field
+	case kind == "CronJob":
+		return append([]string{"spec", "jobTemplate", "spec", "template"}, field...)
+	case contains(podTemplateKinds, kind):
+		return append([]string{"spec", "template"}, field...)
+	}
+	// Not a kind with pods
+	return []string{"-"}
+}
+
+// podSpecReferences returns the kind/name of ConfigMaps and Secrets a pod spec needs
+func podSpecReferences(podSpec map[interface{}]interface{}) []string {
+	refs := map[string]bool{}
+	add := func(kind string, ref interface{}, nameKey string) {
+		m, ok := ref.(map[interface{}]interface{})
+		if !ok || m["optional"] == true {
+			return
+		}
+		if name, ok := m[nameKey].(string); ok && len(name) > 0 {
+			refs[kind+"/"+name] = true
+		}
+	}
+	for _, field := range []string{"initContainers", "containers"} {
+		containers, _ := podSpec[field].([]interface{})
+		for _, ctr := range containers {
+			for _, envFrom := range listAt(ctr, "envFrom") {
+				add("ConfigMap", lookupPath(envFrom, "configMapRef"), "name")
+				add("Secret", lookupPath(envFrom, "secretRef"), "name")
+			}
+			for _, env := range listAt(ctr, "env") {
+				add("ConfigMap", lookupPath(env, "valueFrom", "configMapKeyRef"), "name")
+				add("Secret", lookupPath(env, "valueFrom", "secretKeyRef"), "name")
+			}
+		}
+	}
+	for _, volume := range listAt(podSpec, "volumes") {
+		add("ConfigMap", lookupPath(volume, "configMap"), "name")
+		add("Secret", lookupPath(volume, "secret"), "secretName")
+		for _, source := range listAt(volume, "projected", "sources") {
+			add("ConfigMap", lookupPath(source, "configMap"), "name")
+			add("Secret", lookupPath(source, "secret"), "name")
+		}
+	}
+	list := make([]string, 0, len(refs))
+	for ref := range refs {
+		list = append(list, ref)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// ingressServices returns the names of the services an ingress sends traffic to
+func ingressServices(doc interface{}) []string {
+	var backends []interface{}
+	backends = append(backends, lookupPath(doc, "spec", "backend"), lookupPath(doc, "spec", "defaultBackend"))
+	for _, rule := range listAt(doc, "spec", "rules") {
+		for _, path := range listAt(rule, "http", "paths") {
+			backends = append(backends, lookupPath(path, "backend"))
+		}
+	}
+	seen := map[string]bool{}
+	var names []string
+	for _, b := range backends {
+		// extensions/v1beta1 uses serviceName, networking.k8s.io/v1 uses service.name
+		name, ok := lookupPath(b, "serviceName").(string)
+		if !ok {
+			name, ok = lookupPath(b, "service", "name").(string)
+		}
+		if ok && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// selectorMatches checks if a selector matches any of the sets of labels
+func selectorMatches(selector map[interface{}]interface{}, labelSets []map[interface{}]interface{}) bool {
+	for _, labels := range labelSets {
+		matches := true
+		for k, v := range selector {
+			if fmt.Sprint(labels[k]) != fmt.Sprint(v) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+// selectorString formats a selector for use with kubectl -l
+func selectorString(selector map[interface{}]interface{}) string {
+	var parts []string
+	for k, v := range selector {
+		parts = append(parts, fmt.Sprintf("%v=%v", k, v))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// lookupPath returns the value at a path in a yaml document (nil if missing)
+func lookupPath(doc interface{}, path ...string) interface{} {
+	for _, p := range path {
+		m, ok := doc.(map[interface{}]interface{})
+		if !ok {
+			return nil
+		}
+		doc = m[p]
+	}
+	return doc
+}
+
+// listAt returns the list at a path in a yaml document
+func listAt(doc interface{}, path ...string) []interface{} {
+	list, _ := lookupPath(doc, path...).([]interface{})
+	return list
+}
+
+// contains checks if a list of strings contains a value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
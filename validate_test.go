@@ -0,0 +1,150 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestReferenceProblems(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "Check valid references pass",
+			input: "test/TestValidateReferences/valid.yaml",
+		},
+		{
+			name:  "Check missing references are reported",
+			input: "test/TestValidateReferences/invalid.yaml",
+			want: []string{
+				`Deployment/app (from file:"test/TestValidateReferences/invalid.yaml") references missing ConfigMap/app-config`,
+				`Deployment/app (from file:"test/TestValidateReferences/invalid.yaml") references missing Secret/app-secret`,
+				`Service/app (from file:"test/TestValidateReferences/invalid.yaml") selector name=ap matches no workloads`,
+				`Ingress/app (from file:"test/TestValidateReferences/invalid.yaml") backend references missing Service/app-svc`,
+			},
+		},
+	}
+
+	notFound := referenceLookup{
+		exists:    func(kind, name string) bool { return false },
+		podsMatch: func(selector string) bool { return false },
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var resources []*ObjectResource
+			for _, d := range splitYamlDocs(readfile(c.input)) {
+				r := &ObjectResource{FileName: c.input, Template: []byte(d)}
+				if err := yaml.Unmarshal(r.Template, r); err != nil {
+					t.Fatal(err)
+				}
+				resources = append(resources, r)
+			}
+			got, err := referenceProblems(resources, notFound)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
+
+func TestCheckDuplicates(t *testing.T) {
+	resource := func(kind, namespace, name, file string) *ObjectResource {
+		return &ObjectResource{
+			Kind:       kind,
+			FileName:   file,
+			ObjectMeta: ObjectMeta{Name: name, Namespace: namespace},
+		}
+	}
+	cases := []struct {
+		name      string
+		resources []*ObjectResource
+		wantErr   string
+	}{
+		{
+			name: "Check different kinds and namespaces are allowed",
+			resources: []*ObjectResource{
+				resource("Service", "", "app", "a.yaml"),
+				resource("Deployment", "", "app", "a.yaml"),
+				resource("Deployment", "other", "app", "b.yaml"),
+				resource("Job", "", "", "b.yaml"),
+				resource("Job", "", "", "b.yaml"),
+			},
+		},
+		{
+			name: "Check duplicates in the default namespace are detected",
+			resources: []*ObjectResource{
+				resource("Deployment", "", "app", "a.yaml"),
+				resource("deployment", "testing", "app", "b.yaml"),
+			},
+			wantErr: `duplicate resource deployment/app (namespace:"testing") found in file:"a.yaml" and file:"b.yaml"`,
+		},
+		{
+			name: "Check cluster scoped resources ignore the namespace",
+			resources: []*ObjectResource{
+				resource("ClusterRole", "", "reader", "a.yaml"),
+				resource("ClusterRole", "other", "reader", "b.yaml"),
+			},
+			wantErr: `duplicate resource ClusterRole/reader (namespace:"") found in file:"a.yaml" and file:"b.yaml"`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := checkDuplicates(c.resources, "testing")
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if got != c.wantErr {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckStrictYaml(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{
+			name:  "Check a valid document is allowed",
+			input: "kind: ConfigMap\nmetadata:\n  name: app\n",
+		},
+		{
+			name:  "Check an empty document is allowed",
+			input: "# nothing rendered\n",
+		},
+		{
+			name:    "Check duplicate keys are detected",
+			input:   "kind: ConfigMap\nmetadata:\n  name: app\n  name: other\n",
+			wantErr: "yaml: unmarshal errors:\n  line 4: key \"name\" already set in map",
+		},
+		{
+			name:    "Check a document which isn't a map is detected",
+			input:   "- kind: ConfigMap\n",
+			wantErr: "expected a map of fields but found array",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := checkStrictYaml([]byte(c.input))
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if got != c.wantErr {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.wantErr)
+			}
+		})
+	}
+}
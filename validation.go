@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/urfave/cli"
+)
+
+// validationModes are the values of kubectl --validate from kubectl 1.25
+var validationModes = []string{"strict", "warn", "ignore"}
+
+// minValidationMinor is the first kubectl 1.x release with the validation modes
+const minValidationMinor = 25
+
+// minKustomizeMinor is the first kubectl 1.x release with the kustomize subcommand
+const minKustomizeMinor = 14
+
+// minServerSideMinor is the first kubectl 1.x release with server side apply
+const minServerSideMinor = 16
+
+// minServerDryRunMinor is the first kubectl 1.x release with apply --dry-run=server
+const minServerDryRunMinor = 18
+
+var (
+	// kubectlMinor is the minor version of the kubectl client, found once
+	kubectlMinor     int
+	kubectlMinorErr  error
+	kubectlMinorOnce sync.Once
+	// validationWarnOnce warns about validation modes kubectl doesn't support once
+	validationWarnOnce sync.Once
+)
+
+// validationArgs returns the kubectl --validate argument for the validation mode
+func validationArgs(c *cli.Context) ([]string, error) {
+	mode := c.String(FlagValidation)
+	if !contains(validationModes, mode) {
+		return nil, fmt.Errorf("invalid %s %q, expecting one of %s", FlagValidation, mode, strings.Join(validationModes, ", "))
+	}
+	minor, err := kubectlClientMinor(c)
+	validationWarnOnce.Do(func() {
+		if err != nil {
+			logDebug.Printf("unable to find the kubectl version, assuming validation modes aren't supported: %s", err)
+		} else if minor < minValidationMinor {
+			logWarn.Printf("kubectl 1.%d doesn't support --validate=%s, falling back to %s", minor, mode, validateFallback(mode))
+		}
+	})
+	if err == nil && minor >= minValidationMinor {
+		return []string{"--validate=" + mode}, nil
+	}
+	return []string{"--validate=" + validateFallback(mode)}, nil
+}
+
+// kubectlClientMinor returns the minor version of the kubectl client, running
+// kubectl version the first time
+func kubectlClientMinor(c *cli.Context) (int, error) {
+	kubectlMinorOnce.Do(func() {
+		out, err := runKubeCmd(c, "version", "--client", "-o", "json")
+		if err == nil {
+			kubectlMinor, err = parseKubectlMinor(out)
+		}
+		kubectlMinorErr = err
+	})
+	return kubectlMinor, kubectlMinorErr
+}
+
+// requireKubectl fails when a feature needs a newer kubectl than the one used,
+// assuming the feature is supported when the version can't be found
+func requireKubectl(c *cli.Context, minor int, feature string) error {
+	found, err := kubectlClientMinor(c)
+	if err != nil {
+		logDebug.Printf("unable to find the kubectl version, assuming it supports %s: %s", feature, err)
+		return nil
+	}
+	return checkKubectlMinor(found, minor, feature)
+}
+
+// checkKubectlMinor fails when the kubectl minor version found is older than minor
+func checkKubectlMinor(found, minor int, feature string) error {
+	if found < minor {
+		return fmt.Errorf("%s needs kubectl 1.%d or later, found kubectl 1.%d", feature, minor, found)
+	}
+	return nil
+}
+
+// validateFallback is the --validate value for a mode with kubectl before 1.25,
+// which only fails on invalid fields (client side) or skips validation
+func validateFallback(mode string) string {
+	if mode == "strict" {
+		return "true"
+	}
+	return "false"
+}
+
+// parseKubectlMinor returns the minor version from kubectl version --client -o json
+func parseKubectlMinor(out string) (int, error) {
+	var version struct {
+		ClientVersion struct {
+			Minor string `json:"minor"`
+		} `json:"clientVersion"`
+	}
+	if err := json.Unmarshal([]byte(out), &version); err != nil {
+		return 0, err
+	}
+	// e.g. "25+" for some distributions
+	return strconv.Atoi(strings.TrimSuffix(version.ClientVersion.Minor, "+"))
+}
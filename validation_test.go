@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseKubectlMinor(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   string
+		want    int
+		wantErr bool
+	}{
+		{
+			name:  "Check the minor version is parsed",
+			input: `{"clientVersion": {"major": "1", "minor": "26", "gitVersion": "v1.26.1"}}`,
+			want:  26,
+		},
+		{
+			name:  "Check a distribution suffix is removed",
+			input: `{"clientVersion": {"major": "1", "minor": "25+"}}`,
+			want:  25,
+		},
+		{
+			name:    "Check output without a version is an error",
+			input:   `Client Version: v1.12.3`,
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parseKubectlMinor(c.input)
+			if c.wantErr {
+				if err == nil {
+					t.Errorf("expected an error for %q", c.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != c.want {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
+
+func TestValidateFallback(t *testing.T) {
+	for mode, want := range map[string]string{"strict": "true", "warn": "false", "ignore": "false"} {
+		if got := validateFallback(mode); got != want {
+			t.Errorf("got: %#v\nwant: %#v\n", got, want)
+		}
+	}
+}
+
+func TestCheckKubectlMinor(t *testing.T) {
+	if err := checkKubectlMinor(22, minServerSideMinor, "--server-side"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	want := "--server-side needs kubectl 1.16 or later, found kubectl 1.12"
+	if err := checkKubectlMinor(12, minServerSideMinor, "--server-side"); err == nil || err.Error() != want {
+		t.Errorf("got: %#v\nwant: %#v\n", err, want)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	// vaultSecrets caches the secrets read from vault while rendering
+	vaultSecrets     = map[string]map[string]interface{}{}
+	vaultSecretsLock sync.Mutex
+)
+
+// vault returns a key of a secret from HashiCorp Vault (VAULT_ADDR and VAULT_TOKEN)
+func vault(ctx context.Context, path, key string) (string, error) {
+	secret, err := vaultSecret(ctx, strings.Trim(path, "/"))
+	if err != nil {
+		return "", err
+	}
+	value, found := secret[key]
+	if !found {
+		return "", fmt.Errorf("vault secret %s has no key %s", path, key)
+	}
+	return fmt.Sprint(value), nil
+}
+
+// vaultSecret reads a secret from vault, supporting kv version 1 and 2 secret engines
+func vaultSecret(ctx context.Context, path string) (map[string]interface{}, error) {
+	vaultSecretsLock.Lock()
+	defer vaultSecretsLock.Unlock()
+	if secret, found := vaultSecrets[path]; found {
+		return secret, nil
+	}
+	addr := os.Getenv("VAULT_ADDR")
+	if len(addr) == 0 {
+		return nil, fmt.Errorf("VAULT_ADDR must be set to read vault secret %s", path)
+	}
+	secret, status, err := vaultRead(ctx, addr, path)
+	if err == nil && status == http.StatusNotFound {
+		// kv version 2 secrets are read from mount/data/path
+		if v2 := vaultDataPath(path); v2 != path {
+			secret, status, err = vaultRead(ctx, addr, v2)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("problem reading vault secret %s: status %d", path, status)
+	}
+	vaultSecrets[path] = secret
+	return secret, nil
+}
+
+// vaultRead gets a secret from the vault api, returning its data and the response status
+func vaultRead(ctx context.Context, addr, path string) (map[string]interface{}, int, error) {
+	req, err := http.NewRequest("GET", strings.TrimSuffix(addr, "/")+"/v1/"+path, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("X-Vault-Token", os.Getenv("VAULT_TOKEN"))
+	if ns := os.Getenv("VAULT_NAMESPACE"); len(ns) > 0 {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+	// Secrets are read while rendering so must finish by the render deadline
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, 0, fmt.Errorf("problem reading vault secret %s: %s", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode, nil
+	}
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, 0, fmt.Errorf("problem reading vault secret %s: %s", path, err)
+	}
+	return vaultData(body.Data), resp.StatusCode, nil
+}
+
+// vaultData returns the keys of a secret, unwrapping kv version 2 data
+func vaultData(data map[string]interface{}) map[string]interface{} {
+	inner, ok := data["data"].(map[string]interface{})
+	if _, versioned := data["metadata"]; ok && versioned {
+		return inner
+	}
+	return data
+}
+
+// vaultDataPath is the kv version 2 api path for a secret e.g. secret/app to secret/data/app
+func vaultDataPath(path string) string {
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) < 2 || strings.HasPrefix(parts[1], "data/") {
+		return path
+	}
+	return parts[0] + "/data/" + parts[1]
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestVault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/kv/app":
+			fmt.Fprint(w, `{"data": {"password": "v1-secret"}}`)
+		case "/v1/secret/data/app":
+			fmt.Fprint(w, `{"data": {"data": {"password": "v2-secret"}, "metadata": {"version": 3}}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	defer os.Unsetenv("VAULT_ADDR")
+	defer os.Unsetenv("VAULT_TOKEN")
+	os.Setenv("VAULT_ADDR", server.URL)
+	os.Setenv("VAULT_TOKEN", "token")
+
+	cases := []struct {
+		name    string
+		path    string
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Check a kv version 1 secret is read",
+			path: "kv/app",
+			key:  "password",
+			want: "v1-secret",
+		},
+		{
+			name: "Check a kv version 2 secret is read without the data path",
+			path: "secret/app",
+			key:  "password",
+			want: "v2-secret",
+		},
+		{
+			name:    "Check a missing key is an error",
+			path:    "secret/app",
+			key:     "username",
+			wantErr: true,
+		},
+		{
+			name:    "Check a missing secret is an error",
+			path:    "secret/missing",
+			key:     "password",
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := vault(context.Background(), c.path, c.key)
+			if c.wantErr {
+				if err == nil {
+					t.Errorf("expected an error for %s %s", c.path, c.key)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != c.want {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// workspaceRoot is the directory each run creates its workspace in, see workspaceBase
+var workspaceRoot string
+
+// workspace returns the directory for the files generated by this run, creating a
+// uniquely named one the first time so concurrent runs never share files
+func workspace() (string, error) {
+	if len(tmpDir) > 0 {
+		return tmpDir, nil
+	}
+	base := workspaceBase(workspaceRoot, os.Getenv)
+	if err := os.MkdirAll(base, 0700); err != nil {
+		return "", err
+	}
+	dir, err := ioutil.TempDir(base, "kd-")
+	if err != nil {
+		return "", err
+	}
+	// Update the global var used for cleanup
+	tmpDir = dir
+	return tmpDir, nil
+}
+
+// workspaceBase chooses where workspaces are created, the --workspace directory,
+// the user's private runtime directory or TMPDIR (defaulting to /tmp)
+func workspaceBase(root string, getenv func(string) string) string {
+	if len(root) > 0 {
+		return root
+	}
+	if dir := getenv("XDG_RUNTIME_DIR"); len(dir) > 0 {
+		return dir
+	}
+	if dir := getenv("TMPDIR"); len(dir) > 0 {
+		return dir
+	}
+	return "/tmp"
+}
+
+// Delete any temparay files
+func cleanup() {
+	if len(tmpDir) > 0 {
+		logDebug.Printf("cleaning up %s", tmpDir)
+		os.RemoveAll(tmpDir)
+		tmpDir = ""
+	}
+}
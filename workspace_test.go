@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorkspaceBase(t *testing.T) {
+	cases := []struct {
+		name string
+		root string
+		env  map[string]string
+		want string
+	}{
+		{name: "Check the workspace flag is used", root: "/builds/kd", env: map[string]string{"TMPDIR": "/var/tmp"}, want: "/builds/kd"},
+		{name: "Check the runtime dir is preferred", env: map[string]string{"XDG_RUNTIME_DIR": "/run/user/1000", "TMPDIR": "/var/tmp"}, want: "/run/user/1000"},
+		{name: "Check TMPDIR is used", env: map[string]string{"TMPDIR": "/var/tmp"}, want: "/var/tmp"},
+		{name: "Check /tmp is the default", want: "/tmp"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			getenv := func(key string) string { return c.env[key] }
+			if got := workspaceBase(c.root, getenv); got != c.want {
+				t.Errorf("got: %#v\nwant: %#v\n", got, c.want)
+			}
+		})
+	}
+}
+
+func TestWorkspace(t *testing.T) {
+	root, err := ioutil.TempDir("", "kd-workspace")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer os.RemoveAll(root)
+	defer func() { workspaceRoot = "" }()
+	workspaceRoot = filepath.Join(root, "runs")
+
+	dir, err := workspace()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if filepath.Dir(dir) != workspaceRoot {
+		t.Errorf("got: %#v\nwant: a directory in %#v\n", dir, workspaceRoot)
+	}
+	if again, _ := workspace(); again != dir {
+		t.Errorf("got: %#v\nwant: %#v\n", again, dir)
+	}
+	cleanup()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("expected the workspace to be removed, got: %v", err)
+	}
+}
+
+func TestCaFilePath(t *testing.T) {
+	root, err := ioutil.TempDir("", "kd-workspace")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer os.RemoveAll(root)
+	defer func() { workspaceRoot = "" }()
+	workspaceRoot = root
+	defer cleanup()
+
+	if got, _ := caFilePath("/etc/kd/ca.pem"); got != "/etc/kd/ca.pem" {
+		t.Errorf("got: %#v\nwant: %#v\n", got, "/etc/kd/ca.pem")
+	}
+
+	// Only the certificate authority data is set
+	path, err := caFilePath("")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if dir, _ := workspace(); path != filepath.Join(dir, "kube-ca.pem") {
+		t.Errorf("got: %#v\nwant: kube-ca.pem in %#v\n", path, dir)
+	}
+	if err := createCertificateAuthority(path, "ca data"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if data, _ := ioutil.ReadFile(path); string(data) != "ca data" {
+		t.Errorf("got: %#v\nwant: %#v\n", string(data), "ca data")
+	}
+}